@@ -0,0 +1,109 @@
+package goutils
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AlertStats is a snapshot of the counters of an AlertHook, since its creation or ResetStats.
+// The parts of the alerts split by OversizeSplit count as alerts.
+type AlertStats struct {
+	// Sent is the number of alerts sent
+	Sent int64 `json:"sent"`
+	// Failed is the number of alerts whose send failed, after the retries and fallbacks
+	Failed int64 `json:"failed"`
+	// NetworkFailures is the number of the Failed alerts whose error is a net.Error, like a timeout
+	NetworkFailures int64 `json:"network_failures"`
+	// RateLimited is the number of alerts dropped by WithRateLimit
+	RateLimited int64 `json:"rate_limited"`
+	// Dropped is the number of alerts dropped because the queue was full
+	Dropped int64 `json:"dropped"`
+	// Refused is the number of alerts refused by WithBeforeSend, WithOversizePolicy or WithKeywordPolicy
+	Refused int64 `json:"refused"`
+	// Retries is the number of sends retried by WithSendRetry
+	Retries int64 `json:"retries"`
+	// Fallbacks is the number of alerts sent to the hook of WithFallback
+	Fallbacks int64 `json:"fallbacks"`
+	// LastError is the last error of a failed alert, and LastErrorTime its time, nil before any failure
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_time,omitempty"`
+}
+
+// alertCounters are the counters of an AlertHook, safe for concurrent use
+type alertCounters struct {
+	sent, failed, networkFailures, rateLimited, dropped, refused, retries, fallbacks atomic.Int64
+
+	mu            sync.Mutex
+	lastError     string
+	lastErrorTime time.Time
+}
+
+// fail counts a failed alert
+func (c *alertCounters) fail(err error) {
+	c.failed.Add(1)
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		c.networkFailures.Add(1)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastError = err.Error()
+	c.lastErrorTime = GetClock().Now()
+}
+
+// Stats returns a snapshot of the counters of the hook
+func (h *AlertHook) Stats() AlertStats {
+	c := &h.stats
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := AlertStats{
+		Sent:            c.sent.Load(),
+		Failed:          c.failed.Load(),
+		NetworkFailures: c.networkFailures.Load(),
+		RateLimited:     c.rateLimited.Load(),
+		Dropped:         c.dropped.Load(),
+		Refused:         c.refused.Load(),
+		Retries:         c.retries.Load(),
+		Fallbacks:       c.fallbacks.Load(),
+		LastError:       c.lastError,
+	}
+	if !c.lastErrorTime.IsZero() {
+		lastErrorTime := c.lastErrorTime
+		stats.LastErrorTime = &lastErrorTime
+	}
+	return stats
+}
+
+// ResetStats sets the counters of the hook to zero
+func (h *AlertHook) ResetStats() {
+	c := &h.stats
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, counter := range []*atomic.Int64{
+		&c.sent, &c.failed, &c.networkFailures, &c.rateLimited, &c.dropped, &c.refused, &c.retries, &c.fallbacks,
+	} {
+		counter.Store(0)
+	}
+	c.lastError = ""
+	c.lastErrorTime = time.Time{}
+}
+
+// StatsHandler serves the Stats of the hook as JSON, for scraping
+func (h *AlertHook) StatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(h.Stats()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package goutils_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+	"github.com/117503445/goutils/testutil"
+)
+
+func TestAlertHookStats(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	webhook := testutil.NewWebhookRecorder(t, nil)
+	offline := httptest.NewServer(http.NotFoundHandler())
+	offline.Close()
+	send := func(title, markdown string) error {
+		if markdown == "offline" {
+			resp, err := http.Get(offline.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			return err
+		}
+		return webhook.Send(title, markdown)
+	}
+	hook, err := goutils.NewAlertHook(send, zerolog.ErrorLevel, goutils.WithMinInterval(0), goutils.WithRateLimit(0),
+		goutils.WithSendRetry{goutils.WithAttempts(2), goutils.WithBackoff{Initial: time.Millisecond, Factor: 1}},
+		goutils.WithBeforeSend(func(markdown string) (string, error) {
+			if markdown == "noise" {
+				return "", errors.New("noise")
+			}
+			return markdown, nil
+		}))
+	ast.NoError(err)
+	defer hook.Close()
+	ast.Equal(goutils.AlertStats{}, hook.Stats())
+
+	// 2 failures of the API, retried once each
+	webhook.Respond(
+		testutil.WebhookResponse{Status: http.StatusInternalServerError, Body: "busy"},
+		testutil.WebhookResponse{Status: http.StatusInternalServerError, Body: "busy"},
+		testutil.WebhookResponse{Status: http.StatusInternalServerError, Body: "busy"},
+		testutil.WebhookResponse{Status: http.StatusInternalServerError, Body: "still busy"},
+	)
+	ast.Error(hook.Send(context.Background(), "disk full"))
+	ast.Error(hook.Send(context.Background(), "disk full"))
+	// 10 successes at once
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ast.NoError(hook.Send(context.Background(), "disk full"))
+		}()
+	}
+	wg.Wait()
+	// a network failure, retried once, and a refused alert
+	ast.Error(hook.Send(context.Background(), "offline"))
+	ast.Error(hook.Send(context.Background(), "noise"))
+
+	stats := hook.Stats()
+	ast.Contains(stats.LastError, "connect")
+	if ast.NotNil(stats.LastErrorTime) {
+		ast.WithinDuration(time.Now(), *stats.LastErrorTime, time.Minute)
+	}
+	stats.LastError, stats.LastErrorTime = "", nil
+	ast.Equal(goutils.AlertStats{Sent: 10, Failed: 3, NetworkFailures: 1, Refused: 1, Retries: 3}, stats)
+
+	// the handler
+	recorder := httptest.NewRecorder()
+	hook.StatsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	ast.Equal(http.StatusOK, recorder.Code)
+	ast.Equal("application/json", recorder.Header().Get("Content-Type"))
+	var served map[string]any
+	ast.NoError(json.Unmarshal(recorder.Body.Bytes(), &served))
+	ast.EqualValues(10, served["sent"])
+	ast.EqualValues(3, served["failed"])
+	ast.EqualValues(1, served["network_failures"])
+	ast.Contains(served["last_error"], "connect")
+	ast.Contains(served, "last_error_time")
+
+	recorder = httptest.NewRecorder()
+	hook.StatsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/stats", nil))
+	ast.Equal(http.StatusMethodNotAllowed, recorder.Code)
+
+	hook.ResetStats()
+	ast.Equal(goutils.AlertStats{}, hook.Stats())
+	recorder = httptest.NewRecorder()
+	hook.StatsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	ast.NotContains(recorder.Body.String(), "last_error")
+	ast.NoError(hook.Send(context.Background(), "disk full"))
+	ast.Equal(goutils.AlertStats{Sent: 1}, hook.Stats())
+}
+
+func TestAlertHookStatsDropped(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	webhook := testutil.NewWebhookRecorder(t, nil)
+	backup := testutil.NewWebhookRecorder(t, nil)
+	backupHook, err := goutils.NewAlertHook(backup.Send, zerolog.ErrorLevel, goutils.WithMinInterval(0))
+	ast.NoError(err)
+	defer backupHook.Close()
+	hook, err := goutils.NewAlertHook(webhook.Send, zerolog.ErrorLevel, goutils.WithMinInterval(0),
+		goutils.WithRateLimit(2), goutils.WithRateLimitBehavior(goutils.RateLimitDrop),
+		goutils.WithFallback{Hook: backupHook})
+	ast.NoError(err)
+
+	webhook.Respond(testutil.WebhookResponse{Status: http.StatusBadRequest, Body: "invalid"})
+	ast.NoError(hook.Send(context.Background(), "disk full"))
+	ast.NoError(hook.Send(context.Background(), "disk full"))
+	ast.ErrorIs(hook.Send(context.Background(), "disk full"), goutils.ErrRateLimited)
+	ast.Equal(goutils.AlertStats{Sent: 2, RateLimited: 1, Fallbacks: 1}, hook.Stats())
+	ast.Equal(goutils.AlertStats{Sent: 1}, backupHook.Stats())
+	hook.Close()
+
+	// the queue is full while the first alert is being sent
+	started, release := make(chan struct{}), make(chan struct{})
+	send := func(title, markdown string) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+	hook, err = goutils.NewAlertHook(send, zerolog.ErrorLevel, goutils.WithMinInterval(0), goutils.WithQueueSize(1))
+	ast.NoError(err)
+	ast.NoError(hook.Alert("disk full"))
+	<-started
+	ast.NoError(hook.Alert("disk full"))
+	ast.ErrorIs(hook.Alert("disk full"), goutils.ErrQueueFull)
+	ast.ErrorIs(hook.Alert("disk full"), goutils.ErrQueueFull)
+	close(release)
+	<-started
+	hook.Close()
+	ast.Equal(goutils.AlertStats{Sent: 2, Dropped: 2}, hook.Stats())
+}
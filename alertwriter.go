@@ -0,0 +1,208 @@
+package goutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// AlertWriterOption is an option for NewAlertWriter
+type AlertWriterOption interface {
+	applyToAlertWriter(*alertWriterOptions) error
+}
+
+type alertWriterOptions struct {
+	Window time.Duration
+	Fields []string
+}
+
+// WithBatchWindow is an alert writer option to gather the events logged within this duration after the first one
+// into one alert, 2s by default
+type WithBatchWindow time.Duration
+
+func (w WithBatchWindow) applyToAlertWriter(o *alertWriterOptions) error {
+	if w <= 0 {
+		return fmt.Errorf("invalid batch window: %v", time.Duration(w))
+	}
+	o.Window = time.Duration(w)
+	return nil
+}
+
+// WithAlertFields is an alert writer option to show only these fields of the events, besides the level, time,
+// message and caller. All the fields are shown by default.
+type WithAlertFields []string
+
+func (w WithAlertFields) applyToAlertWriter(o *alertWriterOptions) error {
+	o.Fields = w
+	return nil
+}
+
+// maxAlertBatch is the maximum number of events in an alert of AlertWriter, sent at once when reached
+const maxAlertBatch = 20
+
+// AlertWriter is a zerolog.LevelWriter turning the JSON events at or above a level into the Markdown alerts of an
+// AlertHook, for the loggers built without the hook, like in a zerolog.MultiLevelWriter with the console and file
+// writers. The events within WithBatchWindow are gathered into one alert, sent by the hook in the background with
+// its rate limit, so writing never blocks: the alerts beyond the queue of the hook are dropped.
+type AlertWriter struct {
+	hook     *AlertHook
+	minLevel zerolog.Level
+	opt      *alertWriterOptions
+
+	mu      sync.Mutex
+	pending []alertEvent
+	timer   *time.Timer
+	dropped int
+}
+
+// alertEvent is an event of an AlertWriter
+type alertEvent struct {
+	level   string
+	time    string
+	message string
+	caller  string
+	fields  map[string]any
+}
+
+// NewAlertWriter returns an AlertWriter sending the events at or above minLevel with hook.
+// Close it before the hook, to send the pending events.
+func NewAlertWriter(hook *AlertHook, minLevel zerolog.Level, opts ...AlertWriterOption) (*AlertWriter, error) {
+	opt := &alertWriterOptions{Window: 2 * time.Second}
+	for _, o := range opts {
+		if err := o.applyToAlertWriter(opt); err != nil {
+			return nil, err
+		}
+	}
+	return &AlertWriter{hook: hook, minLevel: minLevel, opt: opt}, nil
+}
+
+// Write implements io.Writer, for the events whose level is only in their JSON
+func (w *AlertWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel implements zerolog.LevelWriter. The events which are not JSON objects are ignored.
+func (w *AlertWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	var fields map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	// keep the large integers as they are
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return len(p), nil
+	}
+	event := alertEvent{fields: fields}
+	for key, value := range map[string]*string{
+		zerolog.LevelFieldName:     &event.level,
+		zerolog.TimestampFieldName: &event.time,
+		zerolog.MessageFieldName:   &event.message,
+		zerolog.CallerFieldName:    &event.caller,
+	} {
+		if v, ok := fields[key]; ok {
+			*value = fmt.Sprint(v)
+			delete(fields, key)
+		}
+	}
+	if level == zerolog.NoLevel {
+		if parsed, err := zerolog.ParseLevel(event.level); err == nil {
+			level = parsed
+		}
+	}
+	if level < w.minLevel || level == zerolog.NoLevel || level == zerolog.Disabled || event.message == hookSendFailedMsg {
+		return len(p), nil
+	}
+	if event.level == "" {
+		event.level = level.String()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, event)
+	if len(w.pending) >= maxAlertBatch {
+		w.flushLocked()
+	} else if w.timer == nil {
+		w.timer = time.AfterFunc(w.opt.Window, w.Flush)
+	}
+	return len(p), nil
+}
+
+// Flush sends the pending events at once
+func (w *AlertWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flushLocked()
+}
+
+// Close sends the pending events, the hook is not closed
+func (w *AlertWriter) Close() error {
+	w.Flush()
+	return nil
+}
+
+// flushLocked sends the pending events, w.mu must be held
+func (w *AlertWriter) flushLocked() {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if len(w.pending) == 0 {
+		return
+	}
+
+	md := NewMDBuilder().H3(w.hook.opt.Title)
+	if len(w.pending) > 1 {
+		md.Text(fmt.Sprintf("%d events", len(w.pending)))
+	}
+	for _, event := range w.pending {
+		md.Line().Bold(event.level)
+		for _, text := range []string{event.time, event.message} {
+			if text != "" {
+				md.Text(" " + text)
+			}
+		}
+		var items []string
+		if event.caller != "" {
+			items = append(items, "caller: "+event.caller)
+		}
+		keys := w.opt.Fields
+		if keys == nil {
+			keys = make([]string, 0, len(event.fields))
+			for key := range event.fields {
+				keys = append(keys, key)
+			}
+			slices.Sort(keys)
+		}
+		for _, key := range keys {
+			if value, ok := event.fields[key]; ok {
+				items = append(items, key+": "+alertFieldString(value))
+			}
+		}
+		md.List(items...)
+	}
+	if w.dropped > 0 {
+		md.Quote(fmt.Sprintf("%d alerts dropped", w.dropped))
+	}
+	w.pending = nil
+
+	if err := w.hook.Alert(md.String()); err != nil {
+		w.dropped++
+	} else {
+		w.dropped = 0
+	}
+}
+
+// alertFieldString returns a field value of a JSON event as a string, strings unquoted
+func alertFieldString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,87 @@
+package goutils_test
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+	"github.com/117503445/goutils/testutil"
+)
+
+func TestAlertWriter(t *testing.T) {
+	ast := assert.New(t)
+
+	webhook := testutil.NewWebhookRecorder(t, nil)
+	hook, err := goutils.NewAlertHook(webhook.Send, zerolog.ErrorLevel, goutils.WithMinInterval(0), goutils.WithAlertTitle("svc"))
+	ast.NoError(err)
+	w, err := goutils.NewAlertWriter(hook, zerolog.ErrorLevel, goutils.WithBatchWindow(time.Hour))
+	ast.NoError(err)
+
+	logger := zerolog.New(zerolog.MultiLevelWriter(io.Discard, w))
+	logger.Info().Msg("not alerted")
+	logger.Error().Str("disk", "/data").Int64("free", 9007199254740993).Msg("disk full")
+	logger.WithLevel(zerolog.FatalLevel).Err(io.ErrUnexpectedEOF).Msg("config broken")
+	// the level only in the JSON, and the lines which are not JSON
+	w.Write([]byte(`{"level":"error","time":"2024-09-15T22:12:19Z","caller":"main.go:12","message":"db down"}` + "\n"))
+	w.Write([]byte(`{"level":"debug","message":"not alerted"}` + "\n"))
+	w.Write([]byte("not json\n"))
+	ast.Empty(webhook.Requests())
+	ast.NoError(w.Close())
+	hook.Close()
+
+	ast.Equal([]string{`### svc
+
+3 events
+
+**error** disk full
+
+- disk: /data
+- free: 9007199254740993
+
+**fatal** config broken
+
+- error: unexpected EOF
+
+**error** 2024-09-15T22:12:19Z db down
+
+- caller: main.go:12`}, webhook.Markdowns())
+}
+
+func TestAlertWriterBatch(t *testing.T) {
+	ast := assert.New(t)
+
+	webhook := testutil.NewWebhookRecorder(t, nil)
+	hook, err := goutils.NewAlertHook(webhook.Send, zerolog.ErrorLevel, goutils.WithMinInterval(0), goutils.WithRateLimit(0))
+	ast.NoError(err)
+	w, err := goutils.NewAlertWriter(hook, zerolog.WarnLevel, goutils.WithBatchWindow(20*time.Millisecond),
+		goutils.WithAlertFields{"host"})
+	ast.NoError(err)
+	logger := zerolog.New(w)
+
+	// sent after the window
+	logger.Warn().Str("host", "web1").Str("other", "hidden").Msg("slow")
+	logger.Warn().Str("host", "web2").Msg("slow")
+	ast.Eventually(func() bool { return len(webhook.Requests()) == 1 }, time.Second, 5*time.Millisecond)
+	markdowns := webhook.Markdowns()
+	ast.Contains(markdowns[0], "2 events")
+	ast.Contains(markdowns[0], "- host: web1")
+	ast.Contains(markdowns[0], "- host: web2")
+	ast.NotContains(markdowns[0], "hidden")
+
+	// sent at once when the batch is full
+	for range 20 {
+		logger.Error().Msg("disk full")
+	}
+	ast.Eventually(func() bool { return len(webhook.Requests()) == 2 }, time.Second, 5*time.Millisecond)
+	ast.Contains(webhook.Markdowns()[1], "20 events")
+	ast.NoError(w.Close())
+	hook.Close()
+	ast.Len(webhook.Requests(), 2)
+
+	_, err = goutils.NewAlertWriter(hook, zerolog.ErrorLevel, goutils.WithBatchWindow(0))
+	ast.Error(err)
+}
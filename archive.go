@@ -0,0 +1,392 @@
+package goutils
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ArchiveOption is an option for TarDir and Untar
+type ArchiveOption interface {
+	applyToArchive(*archiveOptions) error
+}
+
+type archiveOptions struct {
+	Exclude         []string
+	StripComponents int
+}
+
+// WithExclude is an option to skip the paths matching any of the globs.
+// A glob is matched against both the slash-separated relative path and the base name, e.g. "*.log" or "build/*".
+type WithExclude []string
+
+func (w WithExclude) applyToArchive(o *archiveOptions) error {
+	o.Exclude = append(o.Exclude, w...)
+	return nil
+}
+
+// WithStripComponents is an Untar option to strip the given number of leading path components from the entry names,
+// like `tar --strip-components`. Entries with fewer components are skipped.
+type WithStripComponents int
+
+func (w WithStripComponents) applyToArchive(o *archiveOptions) error {
+	if w < 0 {
+		return fmt.Errorf("invalid strip components: %d", w)
+	}
+	o.StripComponents = int(w)
+	return nil
+}
+
+func newArchiveOptions(opts []ArchiveOption) (*archiveOptions, error) {
+	opt := &archiveOptions{}
+	for _, o := range opts {
+		if err := o.applyToArchive(opt); err != nil {
+			return nil, err
+		}
+	}
+	return opt, nil
+}
+
+// matchExclude returns true if the slash-separated relative path matches any of the globs
+func matchExclude(globs []string, rel string) bool {
+	for _, g := range globs {
+		if ok, _ := path.Match(g, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(g, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isGzipName returns true if the file name has a gzip extension
+func isGzipName(name string) bool {
+	return strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz")
+}
+
+// TarDir archives the content of srcDir into tarPath. The entry names are relative to srcDir.
+// The archive is gzip compressed if tarPath ends with ".gz" or ".tgz".
+// File modes and symlinks are preserved.
+func TarDir(srcDir, tarPath string, opts ...ArchiveOption) error {
+	opt, err := newArchiveOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(tarPath), 0755); err != nil {
+		return err
+	}
+
+	absTarPath, err := filepath.Abs(tarPath)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(tarPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var w io.Writer = file
+	var gw *gzip.Writer
+	if isGzipName(tarPath) {
+		gw = gzip.NewWriter(file)
+		w = gw
+	}
+	tw := tar.NewWriter(w)
+
+	err = filepath.Walk(srcDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(srcDir, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		// skip the archive itself when it is written into srcDir
+		if abs, err := filepath.Abs(p); err == nil && abs == absTarPath {
+			return nil
+		}
+
+		if matchExclude(opt.Exclude, rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if gw != nil {
+		if err := gw.Close(); err != nil {
+			return err
+		}
+	}
+	return file.Close()
+}
+
+// Untar extracts tarPath into dstDir. Gzip compression is detected from the magic bytes.
+//
+// Entries escaping dstDir, like "../evil", and symlinks pointing outside of dstDir are rejected with an error.
+func Untar(tarPath, dstDir string, opts ...ArchiveOption) error {
+	opt, err := newArchiveOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(tarPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	br := bufio.NewReader(file)
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	if err := os.MkdirAll(dstDir, 0755); err != nil {
+		return err
+	}
+	root, err := filepath.Abs(dstDir)
+	if err != nil {
+		return err
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return err
+	}
+
+	inRoot := func(p string) bool {
+		return p == root || strings.HasPrefix(p, root+string(filepath.Separator))
+	}
+
+	// within returns the absolute path of the slash-separated name inside root,
+	// or an error if it escapes root
+	within := func(name string) (string, error) {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if !inRoot(p) {
+			return "", fmt.Errorf("illegal path in archive: %s", name)
+		}
+		return p, nil
+	}
+
+	// resolve returns the path that a symlink in the directory dir to linkname leads to, following the symlinks
+	// extracted so far, or an error if it leaves root on the way
+	resolve := func(dir, linkname string) (string, error) {
+		p, exists := dir, true
+		for _, part := range strings.Split(filepath.ToSlash(linkname), "/") {
+			switch part {
+			case "", ".":
+				continue
+			case "..":
+				if !exists {
+					// a later entry may create it as a symlink
+					return "", fmt.Errorf("illegal symlink target: %s", linkname)
+				}
+				p = filepath.Dir(p)
+			default:
+				p = filepath.Join(p, part)
+				if !exists {
+					break
+				}
+				if _, err := os.Lstat(p); err != nil {
+					exists = false
+				} else if resolved, err := filepath.EvalSymlinks(p); err == nil {
+					p = resolved
+				} else {
+					// a dangling symlink, checked when it was extracted
+					exists = false
+				}
+			}
+			if !inRoot(p) {
+				return "", fmt.Errorf("illegal symlink target: %s", linkname)
+			}
+		}
+		return p, nil
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if path.IsAbs(hdr.Name) || strings.Contains("/"+hdr.Name+"/", "/../") {
+			return fmt.Errorf("illegal path in archive: %s", hdr.Name)
+		}
+
+		name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+		if opt.StripComponents > 0 {
+			parts := strings.Split(name, "/")
+			if len(parts) <= opt.StripComponents {
+				continue
+			}
+			name = strings.Join(parts[opt.StripComponents:], "/")
+		}
+		if name == "" || matchExclude(opt.Exclude, name) {
+			continue
+		}
+
+		target, err := within(name)
+		if err != nil {
+			return err
+		}
+		// refuse to write through a symlink leading outside of dstDir,
+		// checking the deepest existing ancestor since the rest is created below
+		ancestor := filepath.Dir(target)
+		for {
+			if _, err := os.Lstat(ancestor); err == nil {
+				break
+			}
+			ancestor = filepath.Dir(ancestor)
+		}
+		if ancestor, err = filepath.EvalSymlinks(ancestor); err != nil {
+			return err
+		}
+		if !inRoot(ancestor) {
+			return fmt.Errorf("illegal path in archive: %s", hdr.Name)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		// never write through a symlink extracted at target before
+		info, err := os.Lstat(target)
+		if err == nil && info.Mode()&os.ModeSymlink != 0 {
+			if err := os.Remove(target); err != nil {
+				return err
+			}
+		}
+
+		mode := os.FileMode(hdr.Mode).Perm()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, mode); err != nil {
+				return err
+			}
+			if err := os.Chmod(target, mode); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			if err := os.Chmod(target, mode); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if filepath.IsAbs(hdr.Linkname) {
+				return fmt.Errorf("illegal symlink in archive: %s -> %s", hdr.Name, hdr.Linkname)
+			}
+			dir, err := filepath.EvalSymlinks(filepath.Dir(target))
+			if err != nil {
+				return err
+			}
+			if _, err := resolve(dir, hdr.Linkname); err != nil {
+				return fmt.Errorf("illegal symlink in archive: %s -> %s", hdr.Name, hdr.Linkname)
+			}
+			// replacing a directory would change where the symlinks through it lead
+			if info, err := os.Lstat(target); err == nil && info.IsDir() {
+				return fmt.Errorf("illegal symlink in archive: %s -> %s replaces a directory", hdr.Name, hdr.Linkname)
+			}
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			linkName := strings.TrimPrefix(path.Clean("/"+hdr.Linkname), "/")
+			if opt.StripComponents > 0 {
+				parts := strings.Split(linkName, "/")
+				if len(parts) <= opt.StripComponents {
+					return fmt.Errorf("illegal hard link in archive: %s -> %s", hdr.Name, hdr.Linkname)
+				}
+				linkName = strings.Join(parts[opt.StripComponents:], "/")
+			}
+			linkTarget, err := within(linkName)
+			if err != nil {
+				return err
+			}
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+			if err := os.Link(linkTarget, target); err != nil {
+				return err
+			}
+		default:
+			Logger.Warn().Str("name", hdr.Name).Int("type", int(hdr.Typeflag)).Msg("Skip unsupported tar entry")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package goutils_test
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestTarDir(t *testing.T) {
+	ast := assert.New(t)
+
+	src := t.TempDir()
+	ast.NoError(goutils.WriteText(filepath.Join(src, "a.txt"), "a"))
+	ast.NoError(goutils.WriteText(filepath.Join(src, "sub", "b.txt"), "b"))
+	ast.NoError(goutils.WriteText(filepath.Join(src, "sub", "run.sh"), "#!/bin/sh\n"))
+	ast.NoError(os.Chmod(filepath.Join(src, "sub", "run.sh"), 0755))
+	ast.NoError(os.Symlink("sub/b.txt", filepath.Join(src, "link")))
+	ast.NoError(goutils.WriteText(filepath.Join(src, "debug.log"), "log"))
+
+	for _, name := range []string{"out.tar", "out.tar.gz"} {
+		tarPath := filepath.Join(t.TempDir(), name)
+		ast.NoError(goutils.TarDir(src, tarPath))
+
+		dst := t.TempDir()
+		ast.NoError(goutils.Untar(tarPath, dst))
+
+		for _, rel := range []string{"a.txt", "sub/b.txt", "sub/run.sh", "debug.log"} {
+			expected, err := goutils.ReadText(filepath.Join(src, rel))
+			ast.NoError(err)
+			actual, err := goutils.ReadText(filepath.Join(dst, rel))
+			ast.NoError(err)
+			ast.Equal(expected, actual, rel)
+		}
+
+		info, err := os.Stat(filepath.Join(dst, "sub", "run.sh"))
+		ast.NoError(err)
+		ast.Equal(os.FileMode(0755), info.Mode().Perm())
+
+		link, err := os.Readlink(filepath.Join(dst, "link"))
+		ast.NoError(err)
+		ast.Equal("sub/b.txt", link)
+	}
+
+	// exclude and strip components
+	tarPath := filepath.Join(t.TempDir(), "out.tgz")
+	ast.NoError(goutils.TarDir(src, tarPath, goutils.WithExclude{"*.log"}))
+	dst := t.TempDir()
+	ast.NoError(goutils.Untar(tarPath, dst, goutils.WithStripComponents(1)))
+	ast.True(goutils.FileExists(filepath.Join(dst, "b.txt")))
+	ast.True(goutils.FileExists(filepath.Join(dst, "run.sh")))
+	ast.False(goutils.PathExists(filepath.Join(dst, "a.txt")))
+	ast.False(goutils.PathExists(filepath.Join(dst, "debug.log")))
+}
+
+func TestUntarPathTraversal(t *testing.T) {
+	ast := assert.New(t)
+
+	writeTar := func(headers ...*tar.Header) string {
+		tarPath := filepath.Join(t.TempDir(), "evil.tar")
+		f, err := os.Create(tarPath)
+		ast.NoError(err)
+		defer f.Close()
+		tw := tar.NewWriter(f)
+		for _, hdr := range headers {
+			ast.NoError(tw.WriteHeader(hdr))
+			if hdr.Size > 0 {
+				_, err = tw.Write([]byte("evil")[:hdr.Size])
+				ast.NoError(err)
+			}
+		}
+		ast.NoError(tw.Close())
+		return tarPath
+	}
+
+	base := t.TempDir()
+	dst := filepath.Join(base, "dst")
+
+	err := goutils.Untar(writeTar(&tar.Header{Name: "../evil", Mode: 0644, Size: 4, Typeflag: tar.TypeReg}), dst)
+	ast.Error(err)
+	ast.False(goutils.PathExists(filepath.Join(base, "evil")))
+
+	err = goutils.Untar(writeTar(&tar.Header{Name: "link", Linkname: "../", Typeflag: tar.TypeSymlink}), dst)
+	ast.Error(err)
+
+	err = goutils.Untar(writeTar(&tar.Header{Name: "abs", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink}), dst)
+	ast.Error(err)
+
+	// a chain of symlinks each inside dst on its own, then a file written through them
+	dst = filepath.Join(base, "chain")
+	err = goutils.Untar(writeTar(
+		&tar.Header{Name: "d", Linkname: ".", Typeflag: tar.TypeSymlink},
+		&tar.Header{Name: "s", Linkname: "d/..", Typeflag: tar.TypeSymlink},
+		&tar.Header{Name: "t", Linkname: "s/pwned", Typeflag: tar.TypeSymlink},
+		&tar.Header{Name: "t", Mode: 0644, Size: 4, Typeflag: tar.TypeReg},
+	), dst)
+	ast.ErrorContains(err, "illegal symlink in archive: s -> d/..")
+	ast.False(goutils.PathExists(filepath.Join(base, "pwned")))
+
+	// a file replacing a symlink is written in place of the symlink, not through it
+	dst = filepath.Join(base, "replaced")
+	outside := filepath.Join(base, "outside.txt")
+	ast.NoError(goutils.WriteText(outside, "safe"))
+	ast.NoError(goutils.Untar(writeTar(
+		&tar.Header{Name: "f", Linkname: "g", Typeflag: tar.TypeSymlink},
+		&tar.Header{Name: "g", Mode: 0644, Size: 4, Typeflag: tar.TypeReg},
+		&tar.Header{Name: "f", Mode: 0644, Size: 4, Typeflag: tar.TypeReg},
+	), dst))
+	info, err := os.Lstat(filepath.Join(dst, "f"))
+	ast.NoError(err)
+	ast.True(info.Mode().IsRegular())
+
+	// a symlink through a missing directory can't climb out once the directory becomes a symlink
+	err = goutils.Untar(writeTar(&tar.Header{Name: "l", Linkname: "x/../..", Typeflag: tar.TypeSymlink}), filepath.Join(base, "later"))
+	ast.Error(err)
+	content, err := goutils.ReadText(outside)
+	ast.NoError(err)
+	ast.Equal("safe", content)
+}
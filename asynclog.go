@@ -0,0 +1,240 @@
+package goutils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// WithAsyncWriter is a log option to write to the log files, and the other outputs besides stdout, from a
+// background goroutine, so logging doesn't wait for the disk. Events are queued up to BufferSize, 1024 by default,
+// and the queued events beyond are dropped and counted in a warning. Writes are buffered and flushed every
+// FlushInterval, 1s by default. Fatal and Panic events are written synchronously after flushing the queue.
+//
+// Call FlushLogs or CloseLogs before exiting, to not lose the queued events.
+type WithAsyncWriter struct {
+	BufferSize    int
+	FlushInterval time.Duration
+}
+
+func (w WithAsyncWriter) applyTo(o *logOptions) error {
+	if w.BufferSize < 0 || w.FlushInterval < 0 {
+		return fmt.Errorf("invalid async writer: buffer size %d, flush interval %v", w.BufferSize, w.FlushInterval)
+	}
+	if w.BufferSize == 0 {
+		w.BufferSize = 1024
+	}
+	if w.FlushInterval == 0 {
+		w.FlushInterval = time.Second
+	}
+	o.Async = &w
+	return nil
+}
+
+// asyncWriter is a zerolog.LevelWriter queuing the events for a background goroutine writing them to w
+type asyncWriter struct {
+	w     io.Writer
+	queue chan []byte
+	flush chan chan struct{}
+	done  chan struct{}
+
+	// mu guards buf, written by the background goroutine and by the synchronous writes
+	mu  sync.Mutex
+	buf *bufio.Writer
+
+	closeMu sync.RWMutex
+	closed  bool
+	dropped atomic.Int64
+}
+
+func newAsyncWriter(w io.Writer, opt *WithAsyncWriter) *asyncWriter {
+	a := &asyncWriter{
+		w:     w,
+		queue: make(chan []byte, opt.BufferSize),
+		flush: make(chan chan struct{}),
+		done:  make(chan struct{}),
+		buf:   bufio.NewWriter(w),
+	}
+	go a.run(opt.FlushInterval)
+	return a
+}
+
+func (a *asyncWriter) Write(p []byte) (int, error) {
+	return a.WriteLevel(zerolog.NoLevel, p)
+}
+
+func (a *asyncWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	a.closeMu.RLock()
+	defer a.closeMu.RUnlock()
+
+	if a.closed || level == zerolog.FatalLevel || level == zerolog.PanicLevel {
+		// the process is about to exit, write synchronously after the queued events
+		if !a.closed {
+			a.waitFlush()
+		}
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if _, err := a.buf.Write(p); err != nil {
+			return 0, err
+		}
+		return len(p), a.buf.Flush()
+	}
+
+	// p is reused by zerolog after Write returns
+	event := make([]byte, len(p))
+	copy(event, p)
+	select {
+	case a.queue <- event:
+	default:
+		a.dropped.Add(1)
+	}
+	return len(p), nil
+}
+
+func (a *asyncWriter) run(interval time.Duration) {
+	defer close(a.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case event, ok := <-a.queue:
+			if !ok {
+				a.flushBuf()
+				return
+			}
+			a.write(event)
+		case <-ticker.C:
+			a.flushBuf()
+		case reply := <-a.flush:
+			for drained := false; !drained; {
+				select {
+				case event := <-a.queue:
+					a.write(event)
+				default:
+					drained = true
+				}
+			}
+			a.flushBuf()
+			close(reply)
+		}
+	}
+}
+
+func (a *asyncWriter) write(event []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if dropped := a.dropped.Swap(0); dropped > 0 {
+		fmt.Fprintf(a.buf, "{\"level\":\"warn\",\"message\":\"%d log events dropped by the async writer\"}\n", dropped)
+	}
+	// the error can't be reported to the logger writing here
+	a.buf.Write(event)
+}
+
+func (a *asyncWriter) flushBuf() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.buf.Flush()
+}
+
+// waitFlush asks the background goroutine to write the queued events and flush, and waits for it
+func (a *asyncWriter) waitFlush() {
+	reply := make(chan struct{})
+	select {
+	case a.flush <- reply:
+		<-reply
+	case <-a.done:
+	}
+}
+
+// Flush writes the queued events
+func (a *asyncWriter) Flush() error {
+	a.closeMu.RLock()
+	defer a.closeMu.RUnlock()
+
+	if !a.closed {
+		a.waitFlush()
+	}
+	return a.flushBuf()
+}
+
+// Close writes the queued events and stops the background goroutine, later events are written synchronously.
+// The underlying writer is left open for them, the log files of WithProduction are closed by CloseLogs.
+func (a *asyncWriter) Close() error {
+	a.closeMu.Lock()
+	if a.closed {
+		a.closeMu.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.queue)
+	a.closeMu.Unlock()
+
+	<-a.done
+	return nil
+}
+
+// asyncWriters are the async writers of the loggers installed by InitZeroLog, and logClosers their other
+// resources closed by CloseLogs, like the retention sweep of WithProduction
+var (
+	asyncWritersMu sync.Mutex
+	asyncWriters   []*asyncWriter
+	logClosers     []io.Closer
+)
+
+// setLogOutputs replaces the async writers and the closers of the installed loggers, the previous ones are closed
+func setLogOutputs(writers []*asyncWriter, closers []io.Closer) {
+	asyncWritersMu.Lock()
+	oldWriters, oldClosers := asyncWriters, logClosers
+	asyncWriters, logClosers = writers, closers
+	asyncWritersMu.Unlock()
+
+	for _, w := range oldWriters {
+		w.Close()
+	}
+	for _, c := range oldClosers {
+		c.Close()
+	}
+}
+
+// FlushLogs writes the events queued by WithAsyncWriter
+func FlushLogs() error {
+	asyncWritersMu.Lock()
+	defer asyncWritersMu.Unlock()
+
+	var errs []error
+	for _, w := range asyncWriters {
+		if err := w.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CloseLogs writes the events queued by WithAsyncWriter, closes the log files and stops the retention sweep of
+// WithProduction, for a graceful shutdown.
+// Events logged afterwards are written synchronously, the log files being opened again.
+func CloseLogs() error {
+	asyncWritersMu.Lock()
+	defer asyncWritersMu.Unlock()
+
+	var errs []error
+	for _, w := range asyncWriters {
+		if err := w.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, c := range logClosers {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,97 @@
+package goutils_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestInitZeroLogWithAsyncWriter(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.InitZeroLog()
+
+	// keep stdout quiet, only the files are checked
+	stdout := os.Stdout
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	ast.NoError(err)
+	defer devNull.Close()
+	os.Stdout = devNull
+	defer func() { os.Stdout = stdout }()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.jsonl")
+	_, err = goutils.InitZeroLogE(
+		goutils.WithJSONStdout{},
+		goutils.WithProduction{DirLog: dir, FileName: "app"},
+		goutils.WithAsyncWriter{BufferSize: 2000, FlushInterval: time.Hour},
+	)
+	ast.NoError(err)
+
+	for i := 0; i < 1000; i++ {
+		log.Info().Int("i", i).Msg("burst")
+	}
+	ast.NoError(goutils.FlushLogs())
+	lines, err := goutils.ReadLines(filename)
+	ast.NoError(err)
+	// the burst, after the initialization event
+	ast.Len(lines, 1001)
+
+	// a full buffer drops events, and reports the count
+	_, err = goutils.InitZeroLogE(
+		goutils.WithJSONStdout{},
+		goutils.WithProduction{DirLog: dir, FileName: "small"},
+		goutils.WithAsyncWriter{BufferSize: 1, FlushInterval: time.Hour},
+	)
+	ast.NoError(err)
+	for i := 0; i < 1000; i++ {
+		log.Info().Int("i", i).Msg("burst")
+	}
+	ast.NoError(goutils.FlushLogs())
+	log.Info().Msg("after")
+	ast.NoError(goutils.CloseLogs())
+	// the events after CloseLogs are written synchronously
+	log.Info().Msg("after close")
+	content, err := goutils.ReadText(filepath.Join(dir, "small.jsonl"))
+	ast.NoError(err)
+	ast.Contains(content, "log events dropped by the async writer")
+	ast.Contains(content, `"after"`)
+	ast.Contains(content, "after close")
+	ast.NoError(goutils.CloseLogs())
+}
+
+func TestInitZeroLogWithAsyncWriterFatal(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := os.Getenv("GOUTILS_TEST_ASYNC_FATAL_DIR")
+	if dir != "" {
+		goutils.InitZeroLog(
+			goutils.WithJSONStdout{},
+			goutils.WithProduction{DirLog: dir, FileName: "app"},
+			goutils.WithAsyncWriter{FlushInterval: time.Hour},
+		)
+		log.Info().Msg("queued")
+		log.Fatal().Msg("fatal")
+		return
+	}
+
+	dir = t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestInitZeroLogWithAsyncWriterFatal$")
+	cmd.Env = append(os.Environ(), "GOUTILS_TEST_ASYNC_FATAL_DIR="+dir)
+	out, err := cmd.CombinedOutput()
+	ast.Error(err, string(out))
+
+	content, err := goutils.ReadText(filepath.Join(dir, "app.jsonl"))
+	ast.NoError(err)
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+	ast.Len(lines, 3)
+	ast.Contains(lines[1], "queued")
+	ast.Contains(lines[2], "fatal")
+}
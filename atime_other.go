@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package goutils
+
+import (
+	"os"
+	"time"
+)
+
+// fileAtime returns the access time of the file, approximated by the modification time on this platform
+func fileAtime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
@@ -0,0 +1,100 @@
+package goutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// BroadcastOption is an option for Broadcast
+type BroadcastOption interface {
+	applyToBroadcast(*broadcastOptions) error
+}
+
+type broadcastOptions struct {
+	Quorum      int
+	Concurrency int
+}
+
+// defaultBroadcastConcurrency is the number of hooks sending at once without WithBroadcastConcurrency
+const defaultBroadcastConcurrency = 8
+
+// WithFailureQuorum is a broadcast option to return an error only when at least this many hooks fail, 1 by default
+type WithFailureQuorum int
+
+func (w WithFailureQuorum) applyToBroadcast(o *broadcastOptions) error {
+	if w <= 0 {
+		return fmt.Errorf("invalid failure quorum: %d", w)
+	}
+	o.Quorum = int(w)
+	return nil
+}
+
+// WithBroadcastConcurrency is a broadcast option to send with at most this many hooks at once, 8 by default
+type WithBroadcastConcurrency int
+
+func (w WithBroadcastConcurrency) applyToBroadcast(o *broadcastOptions) error {
+	if w <= 0 {
+		return fmt.Errorf("invalid broadcast concurrency: %d", w)
+	}
+	o.Concurrency = int(w)
+	return nil
+}
+
+// BroadcastResult is the result of the alert of Broadcast sent by Hook
+type BroadcastResult struct {
+	Hook     *AlertHook
+	Err      error
+	Duration time.Duration
+}
+
+// Broadcast sends a Markdown alert with all hooks, WithBroadcastConcurrency of them at once, like to the team channel and the management one,
+// waiting for the sends as AlertHook.Send does, and returns their results in the order of hooks.
+// Each hook applies its own rate limit, retries and fallback.
+//
+// The errors of the failed hooks are returned joined when at least the WithFailureQuorum of them fail,
+// any one by default. When ctx is done, the hooks not done yet have the error of ctx.
+func Broadcast(ctx context.Context, hooks []*AlertHook, markdown string, opts ...BroadcastOption) ([]BroadcastResult, error) {
+	opt := &broadcastOptions{Quorum: 1, Concurrency: defaultBroadcastConcurrency}
+	for _, o := range opts {
+		if err := o.applyToBroadcast(opt); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]BroadcastResult, len(hooks))
+	indexes := make([]int, len(hooks))
+	started := make([]bool, len(hooks))
+	for i, hook := range hooks {
+		results[i].Hook = hook
+		indexes[i] = i
+	}
+	ParallelMap(ctx, indexes, opt.Concurrency, func(ctx context.Context, i int) (struct{}, error) {
+		started[i] = true
+		start := GetClock().Now()
+		results[i].Err = hooks[i].Send(ctx, markdown)
+		results[i].Duration = GetClock().Since(start)
+		return struct{}{}, results[i].Err
+	}, WithCollectAll{})
+
+	for i := range results {
+		if !started[i] {
+			results[i].Err = context.Canceled
+			if err := ctx.Err(); err != nil {
+				results[i].Err = err
+			}
+		}
+	}
+
+	var errs []error
+	for i, result := range results {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("hook %d (%s): %w", i, result.Hook.opt.Title, result.Err))
+		}
+	}
+	if len(errs) < opt.Quorum {
+		return results, nil
+	}
+	return results, errors.Join(errs...)
+}
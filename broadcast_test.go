@@ -0,0 +1,111 @@
+package goutils_test
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+	"github.com/117503445/goutils/testutil"
+)
+
+func TestBroadcast(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	team := testutil.NewWebhookRecorder(t, nil)
+	down := testutil.NewWebhookRecorder(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"errcode":400101,"errmsg":"access_token not exist"}`, http.StatusBadRequest)
+	})
+	management := testutil.NewWebhookRecorder(t, nil)
+	newHook := func(title string, webhook *testutil.WebhookRecorder) *goutils.AlertHook {
+		hook, err := goutils.NewAlertHook(webhook.Send, zerolog.ErrorLevel, goutils.WithMinInterval(0), goutils.WithAlertTitle(title))
+		ast.NoError(err)
+		t.Cleanup(hook.Close)
+		return hook
+	}
+	hooks := []*goutils.AlertHook{newHook("team", team), newHook("down", down), newHook("management", management)}
+
+	results, err := goutils.Broadcast(context.Background(), hooks, "disk full")
+	ast.ErrorContains(err, "hook 1 (down): webhook: 400 Bad Request")
+	if ast.Len(results, 3) {
+		for i, result := range results {
+			ast.Same(hooks[i], result.Hook)
+			ast.Positive(result.Duration)
+		}
+		ast.NoError(results[0].Err)
+		ast.ErrorContains(results[1].Err, "access_token not exist")
+		ast.NoError(results[2].Err)
+	}
+	ast.Equal([]string{"disk full"}, team.Markdowns())
+	ast.Equal([]string{"disk full"}, management.Markdowns())
+	ast.Len(down.Requests(), 1)
+
+	// within the quorum
+	results, err = goutils.Broadcast(context.Background(), hooks, "disk full", goutils.WithFailureQuorum(2))
+	ast.NoError(err)
+	ast.Error(results[1].Err)
+
+	_, err = goutils.Broadcast(context.Background(), hooks, "disk full", goutils.WithFailureQuorum(0))
+	ast.Error(err)
+	_, err = goutils.Broadcast(context.Background(), hooks, "disk full", goutils.WithBroadcastConcurrency(0))
+	ast.Error(err)
+
+	// a closed hook
+	hooks[0].Close()
+	results, err = goutils.Broadcast(context.Background(), hooks[:1], "disk full")
+	ast.ErrorIs(err, goutils.ErrHookClosed)
+	ast.ErrorIs(results[0].Err, goutils.ErrHookClosed)
+}
+
+func TestBroadcastConcurrency(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	var inFlight, maxInFlight, sent atomic.Int32
+	send := func(title, markdown string) error {
+		n := inFlight.Add(1)
+		for {
+			if m := maxInFlight.Load(); n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		inFlight.Add(-1)
+		sent.Add(1)
+		return nil
+	}
+	var hooks []*goutils.AlertHook
+	for range 6 {
+		hook, err := goutils.NewAlertHook(send, zerolog.ErrorLevel, goutils.WithMinInterval(0))
+		ast.NoError(err)
+		t.Cleanup(hook.Close)
+		hooks = append(hooks, hook)
+	}
+
+	_, err := goutils.Broadcast(context.Background(), hooks, "disk full", goutils.WithBroadcastConcurrency(2))
+	ast.NoError(err)
+	ast.EqualValues(6, sent.Load())
+	ast.LessOrEqual(maxInFlight.Load(), int32(2))
+
+	// the hooks not started when ctx is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err := goutils.Broadcast(ctx, hooks, "disk full", goutils.WithBroadcastConcurrency(2))
+	ast.ErrorIs(err, context.Canceled)
+	if ast.Len(results, 6) {
+		for _, result := range results {
+			ast.ErrorIs(result.Err, context.Canceled)
+		}
+	}
+	ast.EqualValues(6, sent.Load())
+
+	// within the quorum, they still count
+	_, err = goutils.Broadcast(ctx, hooks, "disk full", goutils.WithFailureQuorum(6))
+	ast.ErrorIs(err, context.Canceled)
+}
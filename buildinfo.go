@@ -0,0 +1,90 @@
+package goutils
+
+import (
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// Info describes the running binary, see BuildInfo
+type Info struct {
+	ModuleVersion string // Version of the main module, like v1.2.3, "(devel)" for local builds
+	GoVersion     string
+	VCSRevision   string // Commit of the build
+	VCSTime       string // Time of the commit, in RFC 3339
+	Modified      bool   // Whether the working tree had uncommitted changes
+}
+
+// String returns the info on one line, without the empty fields, like "v1.2.3 4f8a1c2 2024-09-15T22:12:19Z modified go1.23.2"
+func (i Info) String() string {
+	var parts []string
+	for _, s := range []string{i.ModuleVersion, i.VCSRevision, i.VCSTime} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	if i.Modified {
+		parts = append(parts, "modified")
+	}
+	if i.GoVersion != "" {
+		parts = append(parts, i.GoVersion)
+	}
+	return strings.Join(parts, " ")
+}
+
+var (
+	buildInfoMu       sync.Mutex
+	injectedBuildInfo Info
+)
+
+// SetBuildInfo overrides the values detected by BuildInfo with the non-empty ones, typically injected with
+// -ldflags "-X main.version=..." in main, as
+//
+//	goutils.SetBuildInfo(version, commit, date)
+func SetBuildInfo(version, commit, date string) {
+	buildInfoMu.Lock()
+	defer buildInfoMu.Unlock()
+	injectedBuildInfo = Info{ModuleVersion: version, VCSRevision: commit, VCSTime: date}
+}
+
+// BuildInfo returns the info of the running binary from debug.ReadBuildInfo, overridden by SetBuildInfo.
+// The fields not available, like the VCS ones of go test and go run, are empty.
+func BuildInfo() Info {
+	var info Info
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.ModuleVersion = bi.Main.Version
+		info.GoVersion = bi.GoVersion
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				info.VCSRevision = s.Value
+			case "vcs.time":
+				info.VCSTime = s.Value
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+
+	buildInfoMu.Lock()
+	defer buildInfoMu.Unlock()
+	if injectedBuildInfo.ModuleVersion != "" {
+		info.ModuleVersion = injectedBuildInfo.ModuleVersion
+	}
+	if injectedBuildInfo.VCSRevision != "" {
+		info.VCSRevision = injectedBuildInfo.VCSRevision
+	}
+	if injectedBuildInfo.VCSTime != "" {
+		info.VCSTime = injectedBuildInfo.VCSTime
+	}
+	return info
+}
+
+// WithBuildInfoLog is a log option to log BuildInfo once the logger is installed
+type WithBuildInfoLog struct {
+}
+
+func (w WithBuildInfoLog) applyTo(o *logOptions) error {
+	o.BuildInfoLog = true
+	return nil
+}
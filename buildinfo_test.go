@@ -0,0 +1,49 @@
+package goutils_test
+
+import (
+	"encoding/json"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestBuildInfo(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.SetBuildInfo("", "", "")
+
+	info := goutils.BuildInfo()
+	ast.Equal(runtime.Version(), info.GoVersion)
+	ast.Contains(info.String(), runtime.Version())
+
+	goutils.SetBuildInfo("v1.2.3", "4f8a1c2", "2024-09-15T22:12:19Z")
+	info = goutils.BuildInfo()
+	ast.Equal("v1.2.3", info.ModuleVersion)
+	ast.Equal("4f8a1c2", info.VCSRevision)
+	ast.Equal("2024-09-15T22:12:19Z", info.VCSTime)
+	ast.Equal(runtime.Version(), info.GoVersion)
+
+	ast.Equal("v1.2.3 4f8a1c2 2024-09-15T22:12:19Z modified go1.23.2",
+		goutils.Info{ModuleVersion: "v1.2.3", VCSRevision: "4f8a1c2", VCSTime: "2024-09-15T22:12:19Z", Modified: true, GoVersion: "go1.23.2"}.String())
+	ast.Equal("go1.23.2", goutils.Info{GoVersion: "go1.23.2"}.String())
+}
+
+func TestInitZeroLogWithBuildInfoLog(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.InitZeroLog()
+	defer goutils.SetBuildInfo("", "", "")
+
+	goutils.SetBuildInfo("v1.2.3", "4f8a1c2", "")
+	out := captureStdout(t, func() {
+		goutils.InitZeroLog(goutils.WithJSONStdout{}, goutils.WithBuildInfoLog{})
+	}, func() {})
+
+	var event map[string]any
+	ast.NoError(json.Unmarshal([]byte(out), &event))
+	ast.Equal("Build info", event["message"])
+	ast.Equal("v1.2.3", event["version"])
+	ast.Equal("4f8a1c2", event["revision"])
+	ast.Equal(runtime.Version(), event["go"])
+}
@@ -0,0 +1,88 @@
+package goutils
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// WithDirBuild is a log option to set the directory the caller paths are made relative to,
+// by default the root of the main module when it can be found, see trimCallerPath
+type WithDirBuild string
+
+func (w WithDirBuild) applyTo(o *logOptions) error {
+	o.DirBuild = string(w)
+	return nil
+}
+
+// mainModulePath returns the path of the main module, like github.com/117503445/goutils, or "" if unknown
+func mainModulePath() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	return info.Main.Path
+}
+
+// detectDirBuild returns the root of the main module, found by walking up from the working directory
+// to the go.mod declaring the main module, or "" if not found, e.g. when deployed without the sources
+func detectDirBuild() string {
+	module := mainModulePath()
+	if module == "" {
+		return ""
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		if goModModule(filepath.Join(dir, "go.mod")) == module {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// goModModule returns the module path declared by the go.mod file, or "" if it can't be read
+func goModModule(goMod string) string {
+	f, err := os.Open(goMod)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if module, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "module "); ok {
+			module = strings.TrimSpace(module)
+			if unquoted, err := strconv.Unquote(module); err == nil {
+				module = unquoted
+			}
+			return module
+		}
+	}
+	return ""
+}
+
+// trimCallerPath returns file relative to dirBuild, or to the main module path for binaries built with -trimpath,
+// or file unchanged if it is in neither
+func trimCallerPath(file, dirBuild, module string) string {
+	if dirBuild != "" {
+		if rel, ok := strings.CutPrefix(filepath.ToSlash(file), filepath.ToSlash(dirBuild)+"/"); ok {
+			return rel
+		}
+	}
+	if module != "" {
+		if rel, ok := strings.CutPrefix(file, module+"/"); ok {
+			return rel
+		}
+	}
+	return file
+}
@@ -0,0 +1,78 @@
+package goutils
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Clock is the source of time of the package, replaceable by SetClock, e.g. by testutil.FakeClock in tests
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	// Sleep waits for d, or until ctx is done and then returns ctx.Err()
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// realClock is the Clock of the time package
+type realClock struct {
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
+
+func (realClock) Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// clockHolder wraps the Clock, as atomic.Value needs a consistent type
+type clockHolder struct {
+	Clock
+}
+
+var clock atomic.Value
+
+func init() {
+	clock.Store(clockHolder{realClock{}})
+}
+
+// SystemClock returns the Clock of the time package, the default one
+func SystemClock() Clock {
+	return realClock{}
+}
+
+// GetClock returns the Clock of the package
+func GetClock() Clock {
+	return clock.Load().(clockHolder).Clock
+}
+
+// SetClock sets the Clock used by the package, like by the TimeStr functions, Retry and TimeTrack, and
+// returns the previous one to restore it. A nil c restores SystemClock.
+func SetClock(c Clock) Clock {
+	if c == nil {
+		c = realClock{}
+	}
+	return clock.Swap(clockHolder{c}).(clockHolder).Clock
+}
+
+// WithClock is a retry option to use Clock instead of the Clock of the package
+type WithClock struct {
+	Clock Clock
+}
+
+func (w WithClock) applyTo(o *retryOptions) error {
+	o.Clock = w.Clock
+	return nil
+}
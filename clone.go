@@ -0,0 +1,109 @@
+package goutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CloneStrategy is the way CloneFile created the destination file
+type CloneStrategy string
+
+const (
+	// CloneReflink is a copy-on-write clone sharing the data blocks of the source, like FICLONE or clonefile
+	CloneReflink CloneStrategy = "reflink"
+	// CloneHardLink is a hard link to the source, only used with WithHardLink
+	CloneHardLink CloneStrategy = "hardlink"
+	// CloneCopy is a plain copy, as done by CopyFile
+	CloneCopy CloneStrategy = "copy"
+)
+
+// WithClone is a copy option to copy the files of CopyDir with CloneFile
+type WithClone struct {
+}
+
+func (w WithClone) applyTo(o *copyOptions) error {
+	o.Clone = true
+	return nil
+}
+
+// WithHardLink is a copy option to let CloneFile fall back to a hard link when reflinks are not supported.
+// The destination then shares the mode, times and content of the source, writes to either are seen by both.
+type WithHardLink struct {
+}
+
+func (w WithHardLink) applyTo(o *copyOptions) error {
+	o.HardLink = true
+	return nil
+}
+
+// CloneFile copies src to dst with a copy-on-write clone when the filesystem supports it,
+// FICLONE on Linux (btrfs, XFS) and clonefile on macOS (APFS).
+// Otherwise it falls back to a hard link if WithHardLink is set, and finally to CopyFile.
+// It returns the strategy used.
+func CloneFile(src, dst string, opts ...CopyOption) (CloneStrategy, error) {
+	opt, err := newCopyOptions(opts)
+	if err != nil {
+		return "", err
+	}
+	return cloneFile(src, dst, opt)
+}
+
+func cloneFile(src, dst string, opt *copyOptions) (CloneStrategy, error) {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return "", err
+	}
+	if !srcInfo.Mode().IsRegular() {
+		return "", fmt.Errorf("clone %s: not a regular file", src)
+	}
+
+	if dstInfo, err := os.Stat(dst); err == nil {
+		if os.SameFile(srcInfo, dstInfo) {
+			return CloneCopy, nil
+		}
+		if opt.NoOverwrite {
+			return "", fmt.Errorf("%w: %s", ErrExists, dst)
+		}
+	}
+
+	// create dst directory recursively
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", err
+	}
+
+	err = reflinkFile(src, dst, srcInfo.Mode())
+	if err == nil {
+		if opt.PreserveTimes {
+			if err := os.Chtimes(dst, fileAtime(srcInfo), srcInfo.ModTime()); err != nil {
+				return "", err
+			}
+		}
+		Logger.Debug().Str("src", src).Str("dst", dst).Str("strategy", string(CloneReflink)).Msg("Cloned file")
+		return CloneReflink, nil
+	}
+	Logger.Debug().Err(err).Str("src", src).Msg("Reflink not available")
+
+	if opt.HardLink {
+		err := os.Remove(dst)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		if err = os.Link(src, dst); err == nil {
+			Logger.Debug().Str("src", src).Str("dst", dst).Str("strategy", string(CloneHardLink)).Msg("Cloned file")
+			return CloneHardLink, nil
+		}
+		if errors.Is(err, os.ErrExist) {
+			return "", fmt.Errorf("%w: %s", ErrExists, dst)
+		}
+		Logger.Debug().Err(err).Str("src", src).Msg("Hard link not available")
+	}
+
+	if _, err := copyFileContext(context.Background(), src, dst, opt, nil); err != nil {
+		return "", err
+	}
+	Logger.Debug().Str("src", src).Str("dst", dst).Str("strategy", string(CloneCopy)).Msg("Cloned file")
+	return CloneCopy, nil
+}
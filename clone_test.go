@@ -0,0 +1,97 @@
+package goutils_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestCloneFile(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.bin")
+	ast.NoError(goutils.WriteText(src, "clone me"))
+	ast.NoError(os.Chmod(src, 0600))
+
+	strategy, err := goutils.CloneFile(src, filepath.Join(dir, "a", "copy.bin"))
+	ast.NoError(err)
+	ast.Contains([]goutils.CloneStrategy{goutils.CloneReflink, goutils.CloneCopy}, strategy)
+	content, err := goutils.ReadText(filepath.Join(dir, "a", "copy.bin"))
+	ast.NoError(err)
+	ast.Equal("clone me", content)
+	info, err := os.Stat(filepath.Join(dir, "a", "copy.bin"))
+	ast.NoError(err)
+	ast.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	// an existing destination is overwritten, unless WithNoOverwrite
+	dst := filepath.Join(dir, "existing.bin")
+	ast.NoError(goutils.WriteText(dst, "old content, longer than the source"))
+	_, err = goutils.CloneFile(src, dst)
+	ast.NoError(err)
+	content, err = goutils.ReadText(dst)
+	ast.NoError(err)
+	ast.Equal("clone me", content)
+	_, err = goutils.CloneFile(src, dst, goutils.WithNoOverwrite{})
+	ast.ErrorIs(err, goutils.ErrExists)
+
+	// the hard link fallback is only used when reflinks are not supported
+	link := filepath.Join(dir, "link.bin")
+	strategy, err = goutils.CloneFile(src, link, goutils.WithHardLink{})
+	ast.NoError(err)
+	ast.Contains([]goutils.CloneStrategy{goutils.CloneReflink, goutils.CloneHardLink, goutils.CloneCopy}, strategy)
+	content, err = goutils.ReadText(link)
+	ast.NoError(err)
+	ast.Equal("clone me", content)
+	if strategy == goutils.CloneHardLink {
+		srcInfo, err := os.Stat(src)
+		ast.NoError(err)
+		linkInfo, err := os.Stat(link)
+		ast.NoError(err)
+		ast.True(os.SameFile(srcInfo, linkInfo))
+	}
+
+	_, err = goutils.CloneFile(filepath.Join(dir, "missing"), filepath.Join(dir, "b"))
+	ast.ErrorIs(err, os.ErrNotExist)
+}
+
+func TestCloneFileReflink(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.bin")
+	ast.NoError(goutils.WriteText(src, "clone me"))
+
+	dst := filepath.Join(dir, "dst.bin")
+	strategy, err := goutils.CloneFile(src, dst)
+	ast.NoError(err)
+	if strategy != goutils.CloneReflink {
+		t.Skip("reflinks are not supported by the filesystem of", dir)
+	}
+
+	// the clone is independent of the source
+	ast.NoError(goutils.WriteText(dst, "changed"))
+	content, err := goutils.ReadText(src)
+	ast.NoError(err)
+	ast.Equal("clone me", content)
+}
+
+func TestCopyDirWithClone(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	ast.NoError(goutils.WriteText(filepath.Join(src, "a.txt"), "a"))
+	ast.NoError(goutils.WriteText(filepath.Join(src, "sub", "b.txt"), "bb"))
+
+	dst := filepath.Join(dir, "dst")
+	ast.NoError(goutils.CopyDir(src, dst, goutils.WithClone{}))
+
+	equal, diffs, err := goutils.DirsEqual(src, dst, goutils.WithIgnoreMtimes{})
+	ast.NoError(err)
+	ast.True(equal, diffs)
+}
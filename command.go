@@ -1,12 +1,14 @@
 package goutils
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
@@ -44,6 +46,7 @@ func CMD(cwd string, command string, args ...string) error {
 type PreExecHandlerContext struct {
 	Cmd string
 	Opt *ExecOptions
+	Ctx context.Context
 }
 
 type ExecutedHandlerContext struct {
@@ -51,6 +54,16 @@ type ExecutedHandlerContext struct {
 	Opt *ExecOptions
 	Res *ExecResult
 	Err error
+	Ctx context.Context
+}
+
+// commandLogger returns the logger of ctx with the command module, or CommandLogger if ctx has none
+func commandLogger(ctx context.Context) *zerolog.Logger {
+	if ctx == nil || zerolog.Ctx(ctx).GetLevel() == zerolog.Disabled {
+		return &CommandLogger
+	}
+	logger := zerolog.Ctx(ctx).With().Str("module", "goutils.command").Logger()
+	return &logger
 }
 
 type ExecOptions struct {
@@ -70,19 +83,19 @@ type ExecOptions struct {
 
 // preExecHandlerLog is the default pre-execution handler
 var preExecHandlerLog = func(ct *PreExecHandlerContext) {
-	CommandLogger.Debug().Str("cwd", ct.Opt.Cwd).Str("command", ct.Cmd).Msg("Run Command")
+	commandLogger(ct.Ctx).Debug().Str("cwd", ct.Opt.Cwd).Str("command", ct.Cmd).Msg("Run Command")
 }
 
 // executedHandlerErrorLog is the default executed handler
 var executedHandlerErrorLog = func(ct *ExecutedHandlerContext) {
 	if ct.Err != nil {
-		CommandLogger.Error().Err(ct.Err).Str("cwd", ct.Opt.Cwd).Str("command", ct.Cmd).Msg("Failed to run command")
+		commandLogger(ct.Ctx).Error().Err(ct.Err).Str("cwd", ct.Opt.Cwd).Str("command", ct.Cmd).Msg("Failed to run command")
 	}
 }
 
 var executedHandlerFatalLog = func(ct *ExecutedHandlerContext) {
 	if ct.Err != nil {
-		CommandLogger.Fatal().Err(ct.Err).Str("cwd", ct.Opt.Cwd).Str("command", ct.Cmd).Msg("Failed to run command")
+		commandLogger(ct.Ctx).Fatal().Err(ct.Err).Str("cwd", ct.Opt.Cwd).Str("command", ct.Cmd).Msg("Failed to run command")
 	}
 }
 
@@ -222,6 +235,15 @@ func (w *resultWriter) Write(p []byte) (n int, err error) {
 // - *ExecResult: the result of the command. Always not nil. Even if the command fails, the result may contain some output.
 // - error: if the command fails
 func Exec(cmd string, opts ...execOption) (*ExecResult, error) {
+	return ExecContext(context.Background(), cmd, opts...)
+}
+
+// ExecContext is like Exec, but the command is killed when ctx is done,
+// and the default handlers log with the logger of ctx, see LoggerIntoContext. A nil ctx means context.Background().
+func ExecContext(ctx context.Context, cmd string, opts ...execOption) (*ExecResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	r := &ExecResult{}
 
 	opt := ExecOpt
@@ -238,7 +260,7 @@ func Exec(cmd string, opts ...execOption) (*ExecResult, error) {
 	}
 	name := strs[0]
 
-	command := exec.Command(name, strs[1:]...)
+	command := exec.CommandContext(ctx, name, strs[1:]...)
 	command.Dir = opt.Cwd
 	command.Stdout = &resultWriter{isStdout: true, result: r}
 	command.Stderr = &resultWriter{isStderr: true, result: r}
@@ -250,7 +272,7 @@ func Exec(cmd string, opts ...execOption) (*ExecResult, error) {
 	}
 
 	if opt.PreExecHandler != nil {
-		opt.PreExecHandler(&PreExecHandlerContext{Cmd: cmd, Opt: opt})
+		opt.PreExecHandler(&PreExecHandlerContext{Cmd: cmd, Opt: opt, Ctx: ctx})
 	}
 
 	err := command.Run()
@@ -285,7 +307,7 @@ func Exec(cmd string, opts ...execOption) (*ExecResult, error) {
 	}
 
 	if opt.ExecutedHandler != nil {
-		opt.ExecutedHandler(&ExecutedHandlerContext{Cmd: cmd, Opt: opt, Res: r, Err: err})
+		opt.ExecutedHandler(&ExecutedHandlerContext{Cmd: cmd, Opt: opt, Res: r, Err: err, Ctx: ctx})
 	}
 
 	return r, err
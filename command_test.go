@@ -1,12 +1,17 @@
 package goutils_test
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"testing"
 
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/117503445/goutils"
+	"github.com/117503445/goutils/testutil"
 )
 
 func TestCMD(t *testing.T) {
@@ -24,14 +29,62 @@ func TestCMD(t *testing.T) {
 }
 
 func TestExec(t *testing.T) {
-	goutils.InitZeroLog(goutils.WithNoColor{})
+	logs := testutil.CaptureLogs(t)
 
 	ast := assert.New(t)
 	r, err := goutils.Exec("ls -l", goutils.WithDumpOutput{})
 	ast.NoError(err)
 	log.Debug().Str("output", r.Output).Msg("Exec")
+	ast.True(logs.Contains("debug", `"command":"ls -l"`))
+	ast.True(logs.Contains("debug", "output dumped to file"))
 
 	r, err = goutils.Exec("ls -l", goutils.WithCwd("/"))
 	ast.NoError(err)
 	log.Debug().Str("output", r.Output).Msg("Exec")
+	ast.True(logs.Contains("debug", `"cwd":"/"`))
+	ast.Zero(logs.Count("error"))
+}
+
+func TestExecContextLogger(t *testing.T) {
+	ast := assert.New(t)
+
+	var buf bytes.Buffer
+	ctx := goutils.LoggerIntoContext(context.Background(), zerolog.New(&buf).With().Str("marker", "req-42").Logger())
+
+	_, err := goutils.ExecContext(ctx, "ls -l", goutils.WithPreExecLog{})
+	ast.NoError(err)
+	ast.Contains(buf.String(), `"marker":"req-42"`)
+	ast.Contains(buf.String(), `"module":"goutils.command"`)
+	ast.Contains(buf.String(), "Run Command")
+
+	buf.Reset()
+	_, err = goutils.ExecContext(ctx, "ls /missing-dir", goutils.WithExecutedHandlerErrorLog{})
+	ast.Error(err)
+	ast.Contains(buf.String(), `"marker":"req-42"`)
+	ast.Contains(buf.String(), "Failed to run command")
+
+	// a cancelled context kills the command
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = goutils.ExecContext(cancelled, "sleep 10", goutils.WithExecutedHandlerSlient{})
+	ast.Error(err)
+
+	// a nil context is allowed
+	var nilCtx context.Context
+	result, err := goutils.ExecContext(nilCtx, "echo nil", goutils.WithExecutedHandlerSlient{})
+	ast.NoError(err)
+	ast.Equal("nil\n", result.Output)
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	ast := assert.New(t)
+
+	ast.Equal(&goutils.Logger, goutils.LoggerFromContext(context.Background()))
+	// a nil context must be safe
+	ast.Equal(&goutils.Logger, goutils.LoggerFromContext(nil))
+
+	logger := zerolog.New(io.Discard)
+	// a nil context must be safe
+	ctx := goutils.LoggerIntoContext(nil, logger)
+	ast.NotEqual(&goutils.Logger, goutils.LoggerFromContext(ctx))
 }
@@ -1,21 +1,382 @@
 package goutils
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	mathrand "math/rand/v2"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// The layouts of the time strings, to parse them with time.Parse.
+// They sort chronologically and are safe in file names.
+const (
+	TimeLayoutSec      = "20060102.150405"
+	TimeLayoutMilliSec = "20060102.150405.000"
+	TimeLayoutNano     = "20060102.150405.000000000"
+)
+
 // TimeStrSec returns the time format string, like 20240915.221219
 func TimeStrSec() string {
-	return time.Now().Format("20060102.150405")
+	return GetClock().Now().Format(TimeLayoutSec)
 }
 
 // TimeStrMilliSec returns the time format string with millisecond, like 20240915.221219.123
 func TimeStrMilliSec() string {
-	return time.Now().Format("20060102.150405.000")
+	return GetClock().Now().Format(TimeLayoutMilliSec)
+}
+
+// TimeStrNano returns the time format string with nanosecond, like 20240915.221219.123456789,
+// for names created at a high frequency
+func TimeStrNano() string {
+	return GetClock().Now().Format(TimeLayoutNano)
+}
+
+// TimeStrSecUTC is like TimeStrSec in UTC, so the strings of hosts in different time zones sort together
+func TimeStrSecUTC() string {
+	return TimeStrIn(time.UTC, TimeLayoutSec)
+}
+
+// TimeStrMilliSecUTC is like TimeStrMilliSec in UTC, like 20240915.141219.123
+func TimeStrMilliSecUTC() string {
+	return TimeStrIn(time.UTC, TimeLayoutMilliSec)
+}
+
+// TimeStrIn returns the current time in loc, or in local time if loc is nil, formatted with layout,
+// e.g. TimeStrIn(time.UTC, TimeLayoutSec) returns 20240915.141219
+func TimeStrIn(loc *time.Location, layout string) string {
+	if loc == nil {
+		loc = time.Local
+	}
+	return GetClock().Now().In(loc).Format(layout)
+}
+
+// ParseTimeStr parses a string of TimeStrSec, TimeStrMilliSec or TimeStrNano in local time,
+// the layout being detected by its length
+func ParseTimeStr(s string) (time.Time, error) {
+	return ParseTimeStrIn(s, time.Local)
+}
+
+// ParseTimeStrIn is like ParseTimeStr, interpreting the time in loc, e.g. time.UTC for TimeStrSecUTC
+func ParseTimeStrIn(s string, loc *time.Location) (time.Time, error) {
+	for _, layout := range []string{TimeLayoutSec, TimeLayoutMilliSec, TimeLayoutNano} {
+		if len(s) == len(layout) {
+			return time.ParseInLocation(layout, s, loc)
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time string %q, expected the layout %s, %s or %s",
+		s, TimeLayoutSec, TimeLayoutMilliSec, TimeLayoutNano)
 }
 
 func UUID4() string {
 	return uuid.New().String()
 }
+
+// UUID7 returns a time-ordered UUID version 7, suited to ids sorted by creation time like request ids.
+// It panics if the random source fails, see UUID7E.
+func UUID7() string {
+	id, err := UUID7E()
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// UUID7E is like UUID7, but returns the error of the random source
+func UUID7E() (string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+// UUID7Batch returns n UUIDs version 7, in increasing order, reading the random source once
+func UUID7Batch(n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	// each UUID reads 16 random bytes, part of them replaced by the time, version and variant
+	random := make([]byte, 16*n)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(random)
+
+	ids := make([]string, n)
+	for i := range ids {
+		id, err := uuid.NewV7FromReader(r)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id.String()
+	}
+	return ids, nil
+}
+
+// UUIDTime returns the creation time embedded in a time-based UUID, of version 1, 6 or 7
+func UUIDTime(id string) (time.Time, error) {
+	u, err := uuid.Parse(id)
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch u.Version() {
+	case 1, 6, 7:
+		return time.Unix(u.Time().UnixTime()), nil
+	default:
+		return time.Time{}, fmt.Errorf("uuid %s of version %d has no time", id, u.Version())
+	}
+}
+
+// shortIDAlphabet is the URL-safe alphabet of ShortID, of 64 characters so that random bytes map uniformly
+const shortIDAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz_-"
+
+// ShortID returns a random id of n characters from a URL-safe alphabet, 12 if n <= 0, like "V1StGXR8_Z5j".
+//
+// Each character carries 6 bits: among k ids of 12 characters, the probability of a collision is
+// about k²/2^73, e.g. 1e-10 for a million ids. Use a larger n, or UUID4, for more ids.
+func ShortID(n int) string {
+	if n <= 0 {
+		n = 12
+	}
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	for i := range b {
+		b[i] = shortIDAlphabet[b[i]&63]
+	}
+	return string(b)
+}
+
+// ShortIDPrefix returns prefix and a ShortID of n characters joined by "-", like "job-4f8a1c"
+func ShortIDPrefix(prefix string, n int) string {
+	return prefix + "-" + ShortID(n)
+}
+
+// CharsetAlphanumeric is the default charset of RandString and FastRandString
+const CharsetAlphanumeric = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// randCharset returns the characters of the concatenated charsets, CharsetAlphanumeric if none.
+// It panics for more than 256 characters, which RandString can't pick from without bias.
+func randCharset(charsets []string) []rune {
+	chars := []rune(strings.Join(charsets, ""))
+	if len(chars) == 0 {
+		chars = []rune(CharsetAlphanumeric)
+	}
+	if len(chars) > 256 {
+		panic(fmt.Sprintf("charset of %d characters, at most 256 are supported", len(chars)))
+	}
+	return chars
+}
+
+// RandString returns a random string of n characters from crypto/rand, for passwords, tokens or nonces.
+// The characters are picked uniformly from the concatenated charsets, CharsetAlphanumeric by default,
+// which must be at most 256 characters. It returns "" if n <= 0.
+func RandString(n int, charset ...string) string {
+	if n <= 0 {
+		return ""
+	}
+	chars := randCharset(charset)
+
+	// bytes beyond the largest multiple of len(chars) are rejected, so the modulo doesn't bias
+	limit := 256 - 256%len(chars)
+	result := make([]rune, 0, n)
+	buf := make([]byte, n+n/4+8)
+	for len(result) < n {
+		if _, err := rand.Read(buf); err != nil {
+			panic(err)
+		}
+		for _, b := range buf {
+			if int(b) < limit && len(result) < n {
+				result = append(result, chars[int(b)%len(chars)])
+			}
+		}
+	}
+	return string(result)
+}
+
+// RandBytesHex returns n random bytes from crypto/rand, hex encoded in 2n characters.
+// It returns "" if n <= 0.
+func RandBytesHex(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// FastRandString is like RandString with math/rand, faster but predictable:
+// only for non-security uses like jitter or temporary name suffixes, never for secrets
+func FastRandString(n int, charset ...string) string {
+	if n <= 0 {
+		return ""
+	}
+	chars := randCharset(charset)
+	result := make([]rune, n)
+	for i := range result {
+		result[i] = chars[mathrand.IntN(len(chars))]
+	}
+	return string(result)
+}
+
+// BytesToStr returns a human-readable size with binary units, like 1.4GiB.
+// One decimal place is kept, without a trailing ".0", e.g. 512B, 1KiB, 1.5MiB.
+func BytesToStr(n int64) string {
+	return BytesToStrOpts(n, BytesFormat{})
+}
+
+// BytesFormat is the format of BytesToStrOpts
+type BytesFormat struct {
+	// Decimal uses the units of 1000 bytes, KB, MB, GB..., instead of the binary units of 1024 bytes
+	Decimal bool
+}
+
+var (
+	binaryByteUnits  = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+	decimalByteUnits = []string{"B", "KB", "MB", "GB", "TB", "PB", "EB"}
+)
+
+// BytesToStrOpts is like BytesToStr, with the units of format, e.g. 1.5GB with decimal units
+func BytesToStrOpts(n int64, format BytesFormat) string {
+	units, base := binaryByteUnits, 1024.0
+	if format.Decimal {
+		units, base = decimalByteUnits, 1000.0
+	}
+
+	sign := ""
+	v := float64(n)
+	if n < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	i := 0
+	for v >= base && i < len(units)-1 {
+		v /= base
+		i++
+	}
+
+	s := strconv.FormatFloat(v, 'f', 1, 64)
+	s = strings.TrimSuffix(s, ".0")
+	return sign + s + units[i]
+}
+
+// ParseBytesStr parses a size like 512MiB, 1.5GB or 100, the reverse of BytesToStr and BytesToStrOpts.
+// Units are case-insensitive, KiB, MiB... are binary and KB, MB... are decimal, no unit means bytes.
+func ParseBytesStr(s string) (int64, error) {
+	str := strings.TrimSpace(s)
+	i := strings.IndexFunc(str, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.' && r != '-' && r != '+'
+	})
+	if i < 0 {
+		i = len(str)
+	}
+	num, unit := str[:i], strings.TrimSpace(str[i:])
+
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+
+	multiplier := 0.0
+	for j := range binaryByteUnits {
+		switch {
+		case unit == "" || strings.EqualFold(unit, "B"):
+			multiplier = 1
+		case strings.EqualFold(unit, binaryByteUnits[j]):
+			multiplier = math.Pow(1024, float64(j))
+		case strings.EqualFold(unit, decimalByteUnits[j]):
+			multiplier = math.Pow(1000, float64(j))
+		}
+	}
+	if multiplier == 0 {
+		return 0, fmt.Errorf("invalid size %q: unknown unit %q", s, unit)
+	}
+
+	v = math.Round(v * multiplier)
+	if v >= math.MaxInt64 || v < math.MinInt64 {
+		return 0, fmt.Errorf("invalid size %q: out of range", s)
+	}
+	return int64(v), nil
+}
+
+// DurationFormat is the format of DurationToStrOpts
+type DurationFormat struct {
+	// MaxUnit is the largest unit, one of 24h, time.Hour, time.Minute, time.Second and time.Millisecond,
+	// 24h (days) by default.
+	MaxUnit time.Duration
+	// Precision is the number of units printed from the largest non-zero one, 3 by default
+	Precision int
+}
+
+// durationUnits are the units of DurationToStr, largest first
+var durationUnits = []struct {
+	d    time.Duration
+	name string
+}{
+	{24 * time.Hour, "d"},
+	{time.Hour, "h"},
+	{time.Minute, "m"},
+	{time.Second, "s"},
+	{time.Millisecond, "ms"},
+}
+
+// DurationToStr returns a human-readable duration with up to 3 units, like 3d7h12m or 1m30s.
+// The smaller units are truncated, down to milliseconds: zero is 0ms. Negative durations are prefixed with "-".
+func DurationToStr(d time.Duration) string {
+	return DurationToStrOpts(d, DurationFormat{})
+}
+
+// DurationToStrOpts is like DurationToStr, with the largest unit and the number of units of format,
+// e.g. DurationFormat{MaxUnit: time.Hour} renders 75h3m instead of 3d3h3m
+func DurationToStrOpts(d time.Duration, format DurationFormat) string {
+	if format.MaxUnit == 0 {
+		format.MaxUnit = 24 * time.Hour
+	}
+	if format.Precision <= 0 {
+		format.Precision = 3
+	}
+
+	sign := ""
+	// the absolute value of math.MinInt64 overflows, as an unsigned it doesn't
+	abs := uint64(d)
+	if d < 0 {
+		sign = "-"
+		abs = -abs
+	}
+
+	var sb strings.Builder
+	// count is the number of units from the largest non-zero one
+	count := 0
+	for _, unit := range durationUnits {
+		if unit.d > format.MaxUnit {
+			continue
+		}
+		n := abs / uint64(unit.d)
+		abs %= uint64(unit.d)
+		if n == 0 && count == 0 {
+			continue
+		}
+		if count++; count > format.Precision {
+			break
+		}
+		if n > 0 {
+			sb.WriteString(strconv.FormatUint(n, 10) + unit.name)
+		}
+	}
+	if sb.Len() == 0 {
+		return "0ms"
+	}
+	return sign + sb.String()
+}
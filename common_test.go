@@ -1,7 +1,11 @@
 package goutils_test
 
 import (
+	"math"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
@@ -24,3 +28,284 @@ func TestCommon(t *testing.T) {
 	log.Debug().Str("GitRepoRoot", dir).Msg("GitRepoRoot")
 
 }
+
+func TestTimeStr(t *testing.T) {
+	ast := assert.New(t)
+
+	ast.Regexp(regexp.MustCompile(`^\d{8}\.\d{6}$`), goutils.TimeStrSec())
+	ast.Regexp(regexp.MustCompile(`^\d{8}\.\d{6}\.\d{3}$`), goutils.TimeStrMilliSec())
+	ast.Regexp(regexp.MustCompile(`^\d{8}\.\d{6}\.\d{9}$`), goutils.TimeStrNano())
+	ast.Regexp(regexp.MustCompile(`^\d{8}\.\d{6}$`), goutils.TimeStrSecUTC())
+	ast.Regexp(regexp.MustCompile(`^\d{8}\.\d{6}\.\d{3}$`), goutils.TimeStrMilliSecUTC())
+
+	// the layouts parse the strings back
+	for layout, s := range map[string]string{
+		goutils.TimeLayoutSec:      goutils.TimeStrSecUTC(),
+		goutils.TimeLayoutMilliSec: goutils.TimeStrMilliSecUTC(),
+	} {
+		ts, err := time.Parse(layout, s)
+		ast.NoError(err)
+		ast.WithinDuration(time.Now(), ts, 5*time.Second)
+	}
+
+	// in a time zone far from UTC, the UTC strings differ from the local ones
+	local := time.Local
+	defer func() { time.Local = local }()
+	time.Local = time.FixedZone("UTC+13", 13*60*60)
+	ast.NotEqual(goutils.TimeStrSec()[:11], goutils.TimeStrSecUTC()[:11])
+	ast.Equal(goutils.TimeStrSec()[:11], goutils.TimeStrIn(nil, goutils.TimeLayoutSec)[:11])
+	ast.Equal(time.Now().In(time.UTC).Format("2006-01-02"), goutils.TimeStrIn(time.UTC, "2006-01-02"))
+}
+
+func TestParseTimeStr(t *testing.T) {
+	ast := assert.New(t)
+
+	now := time.Now()
+	for _, s := range []string{goutils.TimeStrSec(), goutils.TimeStrMilliSec(), goutils.TimeStrNano()} {
+		ts, err := goutils.ParseTimeStr(s)
+		ast.NoError(err, s)
+		ast.WithinDuration(now, ts, 5*time.Second)
+		ast.Equal(time.Local, ts.Location())
+	}
+
+	ts, err := goutils.ParseTimeStr("20240915.221219.123")
+	ast.NoError(err)
+	ast.Equal(time.Date(2024, 9, 15, 22, 12, 19, 123000000, time.Local), ts)
+
+	ts, err = goutils.ParseTimeStrIn(goutils.TimeStrSecUTC(), time.UTC)
+	ast.NoError(err)
+	ast.WithinDuration(now, ts, 5*time.Second)
+
+	for _, s := range []string{"", "garbage", "20240915", "20241315.221219", "2024-09-15 22:12:19"} {
+		_, err := goutils.ParseTimeStr(s)
+		ast.Error(err, s)
+	}
+	_, err = goutils.ParseTimeStr("garbage")
+	ast.ErrorContains(err, goutils.TimeLayoutMilliSec)
+}
+
+func TestBytesToStr(t *testing.T) {
+	ast := assert.New(t)
+
+	tests := []struct {
+		n        int64
+		expected string
+	}{
+		{0, "0B"},
+		{512, "512B"},
+		{1024, "1KiB"},
+		{1536, "1.5KiB"},
+		{1024 * 1024, "1MiB"},
+		{1468006400, "1.4GiB"},
+		{-2048, "-2KiB"},
+	}
+	for _, tt := range tests {
+		ast.Equal(tt.expected, goutils.BytesToStr(tt.n), tt.n)
+	}
+
+	formats := []struct {
+		n        int64
+		format   goutils.BytesFormat
+		expected string
+	}{
+		{0, goutils.BytesFormat{Decimal: true}, "0B"},
+		{999, goutils.BytesFormat{Decimal: true}, "999B"},
+		{1000, goutils.BytesFormat{Decimal: true}, "1KB"},
+		{1468006400, goutils.BytesFormat{Decimal: true}, "1.5GB"},
+		{-2500000, goutils.BytesFormat{Decimal: true}, "-2.5MB"},
+		{1536, goutils.BytesFormat{}, "1.5KiB"},
+	}
+	for _, tt := range formats {
+		ast.Equal(tt.expected, goutils.BytesToStrOpts(tt.n, tt.format), tt.n)
+	}
+}
+
+func TestParseBytesStr(t *testing.T) {
+	ast := assert.New(t)
+
+	tests := []struct {
+		s        string
+		expected int64
+	}{
+		{"0", 0},
+		{"100", 100},
+		{"512B", 512},
+		{"512MiB", 512 * 1024 * 1024},
+		{"1.5KiB", 1536},
+		{"1.5 GB", 1500000000},
+		{"2kb", 2000},
+		{"-2KiB", -2048},
+		{"1EiB", 1 << 60},
+	}
+	for _, tt := range tests {
+		n, err := goutils.ParseBytesStr(tt.s)
+		ast.NoError(err, tt.s)
+		ast.Equal(tt.expected, n, tt.s)
+	}
+
+	for _, s := range []string{"", "MiB", "12XB", "1.2.3KB", "8EiB"} {
+		_, err := goutils.ParseBytesStr(s)
+		ast.Error(err, s)
+	}
+
+	// round trip of the sizes exact to one decimal place
+	for _, n := range []int64{0, 512, 1024, 1536, 1024 * 1024, 3 << 30, -2048} {
+		parsed, err := goutils.ParseBytesStr(goutils.BytesToStr(n))
+		ast.NoError(err)
+		ast.Equal(n, parsed)
+	}
+	for _, n := range []int64{0, 999, 1000, 1500000, 3100000000, -2000} {
+		parsed, err := goutils.ParseBytesStr(goutils.BytesToStrOpts(n, goutils.BytesFormat{Decimal: true}))
+		ast.NoError(err)
+		ast.Equal(n, parsed)
+	}
+}
+
+func TestDurationToStr(t *testing.T) {
+	ast := assert.New(t)
+
+	tests := []struct {
+		d        time.Duration
+		expected string
+	}{
+		{0, "0ms"},
+		{time.Microsecond, "0ms"},
+		{1500 * time.Millisecond, "1s500ms"},
+		{90 * time.Second, "1m30s"},
+		{time.Hour, "1h"},
+		{time.Hour + 2*time.Second, "1h2s"},
+		{75*time.Hour + 3*time.Minute, "3d3h3m"},
+		{79*time.Hour + 12*time.Minute + 5*time.Second, "3d7h12m"},
+		{24 * time.Hour, "1d"},
+		{-5 * time.Second, "-5s"},
+		{-(26*time.Hour + 1500*time.Millisecond), "-1d2h"},
+		{math.MinInt64, "-106751d23h47m"},
+	}
+	for _, tt := range tests {
+		ast.Equal(tt.expected, goutils.DurationToStr(tt.d), tt.d.String())
+	}
+
+	formats := []struct {
+		d        time.Duration
+		format   goutils.DurationFormat
+		expected string
+	}{
+		{75*time.Hour + 3*time.Minute, goutils.DurationFormat{MaxUnit: time.Hour}, "75h3m"},
+		{75*time.Hour + 3*time.Minute + time.Second, goutils.DurationFormat{MaxUnit: time.Hour, Precision: 2}, "75h3m"},
+		{2*time.Hour + 5*time.Second, goutils.DurationFormat{MaxUnit: time.Second}, "7205s"},
+		{79*time.Hour + 12*time.Minute, goutils.DurationFormat{Precision: 1}, "3d"},
+		{-90 * time.Minute, goutils.DurationFormat{MaxUnit: time.Minute}, "-90m"},
+	}
+	for _, tt := range formats {
+		ast.Equal(tt.expected, goutils.DurationToStrOpts(tt.d, tt.format), tt.d.String())
+	}
+}
+
+func TestShortID(t *testing.T) {
+	ast := assert.New(t)
+
+	ast.Len(goutils.ShortID(6), 6)
+	ast.Len(goutils.ShortID(0), 12)
+	ast.Len(goutils.ShortID(-1), 12)
+	ast.Regexp(regexp.MustCompile(`^job-[0-9A-Za-z_-]{6}$`), goutils.ShortIDPrefix("job", 6))
+
+	alphabet := regexp.MustCompile(`^[0-9A-Za-z_-]{12}$`)
+	seen := map[string]bool{}
+	for i := 0; i < 100000; i++ {
+		id := goutils.ShortID(0)
+		if !alphabet.MatchString(id) {
+			ast.Fail("invalid id", id)
+			break
+		}
+		if seen[id] {
+			ast.Fail("duplicate id", id)
+			break
+		}
+		seen[id] = true
+	}
+}
+
+func TestRandString(t *testing.T) {
+	ast := assert.New(t)
+
+	ast.Empty(goutils.RandString(0))
+	ast.Empty(goutils.RandString(-1))
+	ast.Empty(goutils.FastRandString(0))
+	ast.Regexp(regexp.MustCompile(`^[0-9A-Za-z]{16}$`), goutils.RandString(16))
+	ast.Regexp(regexp.MustCompile(`^[0-9A-Za-z]{16}$`), goutils.FastRandString(16))
+	ast.Regexp(regexp.MustCompile(`^[ab0-9]{32}$`), goutils.RandString(32, "ab", "0123456789"))
+	ast.Regexp(regexp.MustCompile(`^[0-9a-f]{16}$`), goutils.RandBytesHex(8))
+	ast.Empty(goutils.RandBytesHex(0))
+	ast.Equal("ééé", goutils.RandString(3, "é"))
+	ast.Panics(func() { goutils.RandString(1, strings.Repeat("x", 257)) })
+
+	// every character appears, close to uniformly
+	for _, gen := range []func(n int) string{
+		func(n int) string { return goutils.RandString(n) },
+		func(n int) string { return goutils.FastRandString(n) },
+	} {
+		const perChar = 1000
+		counts := map[rune]int{}
+		for _, c := range gen(len(goutils.CharsetAlphanumeric) * perChar) {
+			counts[c]++
+		}
+		ast.Len(counts, len(goutils.CharsetAlphanumeric))
+		for c, count := range counts {
+			ast.InDelta(perChar, count, perChar*0.2, string(c))
+		}
+	}
+
+	// a charset not dividing 256 is not biased towards its first characters
+	charset := strings.Repeat("a", 100) + strings.Repeat("b", 100)
+	counts := map[rune]int{}
+	for _, c := range goutils.RandString(100000, charset) {
+		counts[c]++
+	}
+	ast.InDelta(50000, counts['a'], 2000)
+}
+
+func TestUUID7(t *testing.T) {
+	ast := assert.New(t)
+
+	a := goutils.UUID7()
+	b := goutils.UUID7()
+	ast.Len(a, 36)
+	ast.Equal("7", a[14:15])
+	ast.NotEqual(a, b)
+	ast.Less(a, b)
+
+	id, err := goutils.UUID7E()
+	ast.NoError(err)
+	ast.Less(b, id)
+}
+
+func TestUUID7Batch(t *testing.T) {
+	ast := assert.New(t)
+
+	ids, err := goutils.UUID7Batch(1000)
+	ast.NoError(err)
+	ast.Len(ids, 1000)
+	for i := 1; i < len(ids); i++ {
+		ast.Less(ids[i-1], ids[i])
+	}
+	ast.Less(ids[len(ids)-1], goutils.UUID7())
+
+	ids, err = goutils.UUID7Batch(0)
+	ast.NoError(err)
+	ast.Empty(ids)
+}
+
+func TestUUIDTime(t *testing.T) {
+	ast := assert.New(t)
+
+	before := time.Now().Truncate(time.Millisecond)
+	ts, err := goutils.UUIDTime(goutils.UUID7())
+	ast.NoError(err)
+	ast.False(ts.Before(before))
+	ast.WithinDuration(time.Now(), ts, time.Second)
+
+	_, err = goutils.UUIDTime(goutils.UUID4())
+	ast.ErrorContains(err, "version 4")
+	_, err = goutils.UUIDTime("not-a-uuid")
+	ast.Error(err)
+}
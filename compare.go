@@ -0,0 +1,362 @@
+package goutils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FilesEqual returns true if the two files have the same content.
+// Files of different sizes are unequal without reading them, otherwise they are compared in chunks.
+func FilesEqual(a, b string) (bool, error) {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	ia, err := fa.Stat()
+	if err != nil {
+		return false, err
+	}
+	ib, err := fb.Stat()
+	if err != nil {
+		return false, err
+	}
+	if ia.Size() != ib.Size() {
+		return false, nil
+	}
+	if os.SameFile(ia, ib) {
+		return true, nil
+	}
+
+	bufA := make([]byte, 64*1024)
+	bufB := make([]byte, 64*1024)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		endA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		endB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		if errA != nil && !endA {
+			return false, errA
+		}
+		if errB != nil && !endB {
+			return false, errB
+		}
+		if endA || endB {
+			return endA == endB, nil
+		}
+	}
+}
+
+// CompareOption is an option for DirsEqual and DiffDirs
+type CompareOption interface {
+	applyToCompare(*compareOptions) error
+}
+
+type compareOptions struct {
+	IgnoreMtimes   bool
+	IgnoreModes    bool
+	FollowSymlinks bool
+	Exclude        []string
+}
+
+// WithIgnoreMtimes is a compare option to ignore the modification times of files
+type WithIgnoreMtimes struct {
+}
+
+func (w WithIgnoreMtimes) applyToCompare(o *compareOptions) error {
+	o.IgnoreMtimes = true
+	return nil
+}
+
+// WithIgnoreModes is a compare option to ignore the permission bits of files and directories
+type WithIgnoreModes struct {
+}
+
+func (w WithIgnoreModes) applyToCompare(o *compareOptions) error {
+	o.IgnoreModes = true
+	return nil
+}
+
+// WithFollowSymlinks is a compare option to compare what symlinks point to, instead of their targets
+type WithFollowSymlinks struct {
+}
+
+func (w WithFollowSymlinks) applyToCompare(o *compareOptions) error {
+	o.FollowSymlinks = true
+	return nil
+}
+
+func (w WithExclude) applyToCompare(o *compareOptions) error {
+	o.Exclude = append(o.Exclude, w...)
+	return nil
+}
+
+func newCompareOptions(opts []CompareOption) (*compareOptions, error) {
+	opt := &compareOptions{}
+	for _, o := range opts {
+		if err := o.applyToCompare(opt); err != nil {
+			return nil, err
+		}
+	}
+	return opt, nil
+}
+
+// listTree returns the entries under root by slash-separated relative path, root excluded
+func listTree(root string, opt *compareOptions) (map[string]os.FileInfo, error) {
+	entries := map[string]os.FileInfo{}
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if matchExclude(opt.Exclude, rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		var info os.FileInfo
+		if opt.FollowSymlinks {
+			info, err = os.Stat(path)
+		} else {
+			info, err = d.Info()
+		}
+		if err != nil {
+			return err
+		}
+		entries[rel] = info
+		return nil
+	})
+	return entries, err
+}
+
+// entryEqual compares the entry at rel of the two trees
+func entryEqual(a, b string, rel string, ia, ib os.FileInfo, opt *compareOptions) (bool, error) {
+	if ia.Mode().Type() != ib.Mode().Type() {
+		return false, nil
+	}
+	if !opt.IgnoreModes && ia.Mode().Perm() != ib.Mode().Perm() && ia.Mode()&os.ModeSymlink == 0 {
+		return false, nil
+	}
+
+	pa := filepath.Join(a, filepath.FromSlash(rel))
+	pb := filepath.Join(b, filepath.FromSlash(rel))
+	switch {
+	case ia.Mode()&os.ModeSymlink != 0:
+		ta, err := os.Readlink(pa)
+		if err != nil {
+			return false, err
+		}
+		tb, err := os.Readlink(pb)
+		if err != nil {
+			return false, err
+		}
+		return ta == tb, nil
+	case ia.Mode().IsRegular():
+		if !opt.IgnoreMtimes && !ia.ModTime().Equal(ib.ModTime()) {
+			return false, nil
+		}
+		return FilesEqual(pa, pb)
+	default:
+		return true, nil
+	}
+}
+
+// DirsEqual compares the two directory trees, and returns the sorted slash-separated relative paths
+// which differ or are missing on one side.
+//
+// Files are compared by content, permission bits and modification time, see WithIgnoreMtimes and WithIgnoreModes.
+// Symlinks are compared by their targets without being followed, unless WithFollowSymlinks is set.
+func DirsEqual(a, b string, opts ...CompareOption) (bool, []string, error) {
+	opt, err := newCompareOptions(opts)
+	if err != nil {
+		return false, nil, err
+	}
+
+	entriesA, err := listTree(a, opt)
+	if err != nil {
+		return false, nil, err
+	}
+	entriesB, err := listTree(b, opt)
+	if err != nil {
+		return false, nil, err
+	}
+
+	var diffs []string
+	for rel, ia := range entriesA {
+		ib, ok := entriesB[rel]
+		if !ok {
+			diffs = append(diffs, rel)
+			continue
+		}
+		equal, err := entryEqual(a, b, rel, ia, ib, opt)
+		if err != nil {
+			return false, nil, err
+		}
+		if !equal {
+			diffs = append(diffs, rel)
+		}
+	}
+	for rel := range entriesB {
+		if _, ok := entriesA[rel]; !ok {
+			diffs = append(diffs, rel)
+		}
+	}
+
+	sort.Strings(diffs)
+	return len(diffs) == 0, diffs, nil
+}
+
+// DirDiff is the difference between two directory trees, as returned by DiffDirs.
+// Paths are slash-separated, relative to the roots and sorted.
+type DirDiff struct {
+	OnlyInA  []string
+	OnlyInB  []string
+	Modified []string
+}
+
+// Empty returns true if the two trees have the same content
+func (d *DirDiff) Empty() bool {
+	return len(d.OnlyInA) == 0 && len(d.OnlyInB) == 0 && len(d.Modified) == 0
+}
+
+// String renders the difference one path per line, prefixed with "-" for OnlyInA, "+" for OnlyInB
+// and "~" for Modified, followed by a summary line
+func (d *DirDiff) String() string {
+	if d.Empty() {
+		return "no differences"
+	}
+
+	var sb strings.Builder
+	for _, p := range d.OnlyInA {
+		fmt.Fprintf(&sb, "- %s\n", p)
+	}
+	for _, p := range d.OnlyInB {
+		fmt.Fprintf(&sb, "+ %s\n", p)
+	}
+	for _, p := range d.Modified {
+		fmt.Fprintf(&sb, "~ %s\n", p)
+	}
+	fmt.Fprintf(&sb, "%d only in a, %d only in b, %d modified", len(d.OnlyInA), len(d.OnlyInB), len(d.Modified))
+	return sb.String()
+}
+
+// DiffDirs compares the content of the two directory trees, for a dry run before a sync.
+//
+// Unlike DirsEqual, modes and times are ignored: files are modified if their sizes or SHA-256 hashes differ,
+// and symlinks if their targets differ. A directory present on one side only is reported alone, without its content.
+func DiffDirs(a, b string, opts ...CompareOption) (*DirDiff, error) {
+	opt, err := newCompareOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	entriesA, err := listTree(a, opt)
+	if err != nil {
+		return nil, err
+	}
+	entriesB, err := listTree(b, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &DirDiff{}
+	for rel, ia := range entriesA {
+		ib, ok := entriesB[rel]
+		if !ok {
+			if !parentMissing(rel, entriesB) {
+				diff.OnlyInA = append(diff.OnlyInA, rel)
+			}
+			continue
+		}
+		equal, err := contentEqual(a, b, rel, ia, ib)
+		if err != nil {
+			return nil, err
+		}
+		if !equal {
+			diff.Modified = append(diff.Modified, rel)
+		}
+	}
+	for rel := range entriesB {
+		if _, ok := entriesA[rel]; !ok && !parentMissing(rel, entriesA) {
+			diff.OnlyInB = append(diff.OnlyInB, rel)
+		}
+	}
+
+	sort.Strings(diff.OnlyInA)
+	sort.Strings(diff.OnlyInB)
+	sort.Strings(diff.Modified)
+	return diff, nil
+}
+
+// parentMissing returns true if the parent directory of rel is not a directory in entries
+func parentMissing(rel string, entries map[string]os.FileInfo) bool {
+	dir := path.Dir(rel)
+	if dir == "." {
+		return false
+	}
+	info, found := entries[dir]
+	return !found || !info.IsDir()
+}
+
+// contentEqual compares the entry at rel of the two trees by size and hash, or by target for symlinks
+func contentEqual(a, b string, rel string, ia, ib os.FileInfo) (bool, error) {
+	if ia.Mode().Type() != ib.Mode().Type() {
+		return false, nil
+	}
+
+	pa := filepath.Join(a, filepath.FromSlash(rel))
+	pb := filepath.Join(b, filepath.FromSlash(rel))
+	switch {
+	case ia.Mode()&os.ModeSymlink != 0:
+		ta, err := os.Readlink(pa)
+		if err != nil {
+			return false, err
+		}
+		tb, err := os.Readlink(pb)
+		if err != nil {
+			return false, err
+		}
+		return ta == tb, nil
+	case ia.Mode().IsRegular():
+		if ia.Size() != ib.Size() {
+			return false, nil
+		}
+		ha, err := FileSHA256(pa)
+		if err != nil {
+			return false, err
+		}
+		hb, err := FileSHA256(pb)
+		if err != nil {
+			return false, err
+		}
+		return ha == hb, nil
+	default:
+		return true, nil
+	}
+}
@@ -0,0 +1,120 @@
+package goutils_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestFilesEqual(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	c := filepath.Join(dir, "c.txt")
+	d := filepath.Join(dir, "d.txt")
+	ast.NoError(goutils.WriteText(a, "hello"))
+	ast.NoError(goutils.WriteText(b, "hello"))
+	ast.NoError(goutils.WriteText(c, "hellO"))
+	ast.NoError(goutils.WriteText(d, "hello world"))
+
+	equal, err := goutils.FilesEqual(a, b)
+	ast.NoError(err)
+	ast.True(equal)
+
+	equal, err = goutils.FilesEqual(a, c)
+	ast.NoError(err)
+	ast.False(equal)
+
+	equal, err = goutils.FilesEqual(a, d)
+	ast.NoError(err)
+	ast.False(equal)
+
+	_, err = goutils.FilesEqual(a, filepath.Join(dir, "missing"))
+	ast.Error(err)
+}
+
+func TestDirsEqual(t *testing.T) {
+	ast := assert.New(t)
+
+	src := t.TempDir()
+	ast.NoError(goutils.WriteText(filepath.Join(src, "a.txt"), "a"))
+	ast.NoError(goutils.WriteText(filepath.Join(src, "sub", "b.txt"), "b"))
+	ast.NoError(goutils.WriteText(filepath.Join(src, "sub", "run.sh"), "#!/bin/sh\n"))
+	ast.NoError(goutils.WriteText(filepath.Join(src, "debug.log"), "log"))
+	ast.NoError(os.Symlink("sub/b.txt", filepath.Join(src, "link")))
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	ast.NoError(goutils.CopyDirContext(context.Background(), src, dst, goutils.WithPreserveTimes{}))
+	// CopyDir follows symlinks, recreate it as a symlink
+	ast.NoError(os.Remove(filepath.Join(dst, "link")))
+	ast.NoError(os.Symlink("sub/b.txt", filepath.Join(dst, "link")))
+
+	equal, diffs, err := goutils.DirsEqual(src, dst)
+	ast.NoError(err)
+	ast.True(equal)
+	ast.Empty(diffs)
+
+	// mutate one file and one mode
+	ast.NoError(goutils.WriteText(filepath.Join(dst, "sub", "b.txt"), "B"))
+	ast.NoError(os.Chmod(filepath.Join(dst, "sub", "run.sh"), 0755))
+	ast.NoError(goutils.WriteText(filepath.Join(dst, "extra.txt"), "extra"))
+	ast.NoError(os.Remove(filepath.Join(src, "debug.log")))
+
+	equal, diffs, err = goutils.DirsEqual(src, dst)
+	ast.NoError(err)
+	ast.False(equal)
+	ast.Equal([]string{"debug.log", "extra.txt", "sub/b.txt", "sub/run.sh"}, diffs)
+
+	_, diffs, err = goutils.DirsEqual(src, dst, goutils.WithIgnoreModes{}, goutils.WithIgnoreMtimes{}, goutils.WithExclude{"*.log", "extra.txt"})
+	ast.NoError(err)
+	ast.Equal([]string{"sub/b.txt"}, diffs)
+
+	// symlinks compare by target
+	ast.NoError(os.Remove(filepath.Join(dst, "link")))
+	ast.NoError(os.Symlink("a.txt", filepath.Join(dst, "link")))
+	_, diffs, err = goutils.DirsEqual(src, dst, goutils.WithExclude{"sub", "*.log", "extra.txt"})
+	ast.NoError(err)
+	ast.Equal([]string{"link"}, diffs)
+}
+
+func TestDiffDirs(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	ast.NoError(goutils.WriteText(filepath.Join(a, "same.txt"), "same"))
+	ast.NoError(goutils.WriteText(filepath.Join(b, "same.txt"), "same"))
+	ast.NoError(goutils.WriteText(filepath.Join(a, "deleted.txt"), "deleted"))
+	ast.NoError(goutils.WriteText(filepath.Join(b, "added.txt"), "added"))
+	ast.NoError(goutils.WriteText(filepath.Join(a, "sub", "modified.txt"), "old"))
+	ast.NoError(goutils.WriteText(filepath.Join(b, "sub", "modified.txt"), "new"))
+	ast.NoError(goutils.WriteText(filepath.Join(b, "newdir", "x", "y.txt"), "y"))
+	ast.NoError(os.Symlink("same.txt", filepath.Join(a, "link")))
+	ast.NoError(os.Symlink("added.txt", filepath.Join(b, "link")))
+	ast.NoError(goutils.WriteText(filepath.Join(a, "skip.log"), "a"))
+	ast.NoError(goutils.WriteText(filepath.Join(b, "skip.log"), "b"))
+
+	// modes and times are ignored
+	ast.NoError(os.Chmod(filepath.Join(b, "same.txt"), 0600))
+
+	diff, err := goutils.DiffDirs(a, b, goutils.WithExclude{"*.log"})
+	ast.NoError(err)
+	ast.False(diff.Empty())
+	ast.Equal([]string{"deleted.txt"}, diff.OnlyInA)
+	ast.Equal([]string{"added.txt", "newdir"}, diff.OnlyInB)
+	ast.Equal([]string{"link", "sub/modified.txt"}, diff.Modified)
+	ast.Equal("- deleted.txt\n+ added.txt\n+ newdir\n~ link\n~ sub/modified.txt\n1 only in a, 2 only in b, 2 modified", diff.String())
+
+	diff, err = goutils.DiffDirs(a, a)
+	ast.NoError(err)
+	ast.True(diff.Empty())
+	ast.Equal("no differences", diff.String())
+}
@@ -0,0 +1,112 @@
+package goutils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrEnvNotSet is returned by EnvE for the variables unset or empty
+var ErrEnvNotSet = errors.New("environment variable not set")
+
+// EnvValue are the types parsed by EnvE
+type EnvValue interface {
+	string | bool | int | int64 | float64 | time.Duration
+}
+
+// LookupEnvTrim is os.LookupEnv with the value trimmed of spaces, like the trailing newlines of CI secrets
+func LookupEnvTrim(key string) (string, bool) {
+	v, ok := os.LookupEnv(key)
+	return strings.TrimSpace(v), ok
+}
+
+// parseBool parses the booleans of the environment variables: 1, true, yes and on, or 0, false, no and off,
+// case-insensitively
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "1", "true", "yes", "on":
+		return true, nil
+	case "0", "false", "no", "off":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid boolean %q", s)
+	}
+}
+
+// EnvE returns the environment variable key parsed as T, trimmed by LookupEnvTrim.
+// The booleans are parsed by 1/true/yes/on and 0/false/no/off, case-insensitively, and time.Duration
+// by time.ParseDuration. It returns ErrEnvNotSet if the variable is unset or empty, and an error naming
+// the variable and its value if it is malformed.
+func EnvE[T EnvValue](key string) (T, error) {
+	var v T
+	s, _ := LookupEnvTrim(key)
+	if s == "" {
+		return v, fmt.Errorf("%w: %s", ErrEnvNotSet, key)
+	}
+
+	var err error
+	switch p := any(&v).(type) {
+	case *string:
+		*p = s
+	case *bool:
+		*p, err = parseBool(s)
+	case *int:
+		*p, err = strconv.Atoi(s)
+	case *int64:
+		*p, err = strconv.ParseInt(s, 10, 64)
+	case *float64:
+		*p, err = strconv.ParseFloat(s, 64)
+	case *time.Duration:
+		*p, err = time.ParseDuration(s)
+	}
+	if err != nil {
+		var zero T
+		return zero, fmt.Errorf("invalid value %q of environment variable %s: %w", s, key, err)
+	}
+	return v, nil
+}
+
+// env returns EnvE, or def if the variable is unset, empty or malformed, with a warning for the latter
+func env[T EnvValue](key string, def T) T {
+	v, err := EnvE[T](key)
+	if err != nil {
+		if !errors.Is(err, ErrEnvNotSet) {
+			Logger.Warn().Err(err).Msg("Using the default value of the environment variable")
+		}
+		return def
+	}
+	return v
+}
+
+// EnvStr returns the environment variable key trimmed, or def if it is unset or empty
+func EnvStr(key, def string) string {
+	return env(key, def)
+}
+
+// EnvInt returns the environment variable key as an int, or def if it is unset, empty or malformed
+func EnvInt(key string, def int) int {
+	return env(key, def)
+}
+
+// EnvBool returns the environment variable key as a boolean, see EnvE, or def if it is unset, empty or malformed
+func EnvBool(key string, def bool) bool {
+	return env(key, def)
+}
+
+// EnvDuration returns the environment variable key parsed by time.ParseDuration, like "1m30s",
+// or def if it is unset, empty or malformed
+func EnvDuration(key string, def time.Duration) time.Duration {
+	return env(key, def)
+}
+
+// MustEnv returns the environment variable key trimmed, or exits like Must if it is unset or empty
+func MustEnv(key string) string {
+	v, err := EnvE[string](key)
+	if err != nil {
+		mustFail(1, err)
+	}
+	return v
+}
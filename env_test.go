@@ -0,0 +1,92 @@
+package goutils_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+	"github.com/117503445/goutils/testutil"
+)
+
+func TestEnv(t *testing.T) {
+	ast := assert.New(t)
+	logs := testutil.CaptureLogs(t)
+
+	const key = "GOUTILS_TEST_ENV"
+	set := func(value *string) {
+		if value == nil {
+			os.Unsetenv(key)
+			return
+		}
+		t.Setenv(key, *value)
+	}
+	t.Setenv(key, "")
+
+	tests := []struct {
+		value    *string
+		str      string
+		int      int
+		bool     bool
+		duration time.Duration
+	}{
+		{nil, "def", 7, true, time.Second},
+		{goutils.Ptr(""), "def", 7, true, time.Second},
+		{goutils.Ptr("  "), "def", 7, true, time.Second},
+		{goutils.Ptr("42"), "42", 42, true, time.Second},
+		{goutils.Ptr(" 42\n"), "42", 42, true, time.Second},
+		{goutils.Ptr("-3"), "-3", -3, true, time.Second},
+		{goutils.Ptr("0"), "0", 0, false, 0},
+		{goutils.Ptr("YES"), "YES", 7, true, time.Second},
+		{goutils.Ptr("off"), "off", 7, false, time.Second},
+		{goutils.Ptr("1m30s"), "1m30s", 7, true, 90 * time.Second},
+		{goutils.Ptr("abc"), "abc", 7, true, time.Second},
+	}
+	for _, tt := range tests {
+		set(tt.value)
+		name := goutils.Deref(tt.value, "<unset>")
+		ast.Equal(tt.str, goutils.EnvStr(key, "def"), name)
+		ast.Equal(tt.int, goutils.EnvInt(key, 7), name)
+		ast.Equal(tt.bool, goutils.EnvBool(key, true), name)
+		ast.Equal(tt.duration, goutils.EnvDuration(key, time.Second), name)
+	}
+	ast.True(logs.Contains("warn", `invalid value \"abc\" of environment variable GOUTILS_TEST_ENV`))
+}
+
+func TestEnvE(t *testing.T) {
+	ast := assert.New(t)
+
+	const key = "GOUTILS_TEST_ENV"
+	t.Setenv(key, "")
+	_, err := goutils.EnvE[int](key)
+	ast.ErrorIs(err, goutils.ErrEnvNotSet)
+	ast.ErrorContains(err, key)
+
+	t.Setenv(key, "12x")
+	_, err = goutils.EnvE[int](key)
+	ast.ErrorContains(err, `invalid value "12x" of environment variable GOUTILS_TEST_ENV`)
+	_, err = goutils.EnvE[bool](key)
+	ast.ErrorContains(err, `invalid boolean "12x"`)
+
+	t.Setenv(key, "1.5")
+	f, err := goutils.EnvE[float64](key)
+	ast.NoError(err)
+	ast.Equal(1.5, f)
+
+	t.Setenv(key, "9000000000")
+	n, err := goutils.EnvE[int64](key)
+	ast.NoError(err)
+	ast.Equal(int64(9000000000), n)
+
+	t.Setenv(key, "secret\n")
+	v, ok := goutils.LookupEnvTrim(key)
+	ast.True(ok)
+	ast.Equal("secret", v)
+	ast.Equal("secret", goutils.MustEnv(key))
+
+	os.Unsetenv(key)
+	_, ok = goutils.LookupEnvTrim(key)
+	ast.False(ok)
+}
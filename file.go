@@ -1,14 +1,90 @@
 package goutils
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/user"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
 )
 
+// ExpandPath expands a leading "~" or "~user" to the home directory, and $VAR or ${VAR} to the environment variable values,
+// then cleans the result, e.g. "~/data/$APP" becomes "/home/user/data/app".
+// Unknown environment variables expand to an empty string.
+func ExpandPath(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	if strings.HasPrefix(path, "~") {
+		name, rest := path[1:], ""
+		if i := strings.IndexAny(name, "/"+string(filepath.Separator)); i >= 0 {
+			name, rest = name[:i], name[i+1:]
+		}
+
+		var home string
+		if name == "" {
+			h, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			home = h
+		} else {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			home = u.HomeDir
+		}
+		path = filepath.Join(home, rest)
+	}
+
+	path = os.Expand(path, func(key string) string {
+		v, ok := os.LookupEnv(key)
+		if !ok {
+			Logger.Debug().Str("key", key).Str("path", path).Msg("Unknown environment variable in path")
+		}
+		return v
+	})
+
+	return filepath.Clean(path), nil
+}
+
+// ExpandPathAbs is like ExpandPath, but also makes a relative result absolute by joining it to base.
+// If base is empty, the current working directory is used.
+func ExpandPathAbs(path, base string) (string, error) {
+	path, err := ExpandPath(path)
+	if err != nil {
+		return "", err
+	}
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+
+	if base == "" {
+		return filepath.Abs(path)
+	}
+	base, err = ExpandPathAbs(base, "")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, path), nil
+}
+
 // GetGitRootDir returns the root directory of the git repository
 // Deprecated: Use FindGitRepoRoot instead
 func GetGitRootDir() (string, error) {
@@ -60,10 +136,279 @@ func ReadJSON[T any](filename string, data *T) error {
 	}
 	defer file.Close()
 
-	return json.NewDecoder(file).Decode(data)
+	if err := json.NewDecoder(file).Decode(data); err != nil {
+		return jsonDecodeError(filename, err)
+	}
+	return nil
+}
+
+// jsonDecodeError wraps a json decoding error with the file name and, if known, the byte offset of the error
+func jsonDecodeError(filename string, err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return fmt.Errorf("failed to decode json file %s at offset %d: %w", filename, syntaxErr.Offset, err)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Errorf("failed to decode json file %s at offset %d: %w", filename, typeErr.Offset, err)
+	}
+	return fmt.Errorf("failed to decode json file %s: %w", filename, err)
+}
+
+// ReadJSONAs reads a JSON file and returns the decoded value, e.g. `cfg, err := ReadJSONAs[Config]("cfg.json")`
+func ReadJSONAs[T any](filename string) (T, error) {
+	var data T
+	err := ReadJSON(filename, &data)
+	return data, err
+}
+
+// MustReadJSONAs is like ReadJSONAs, but exits with a fatal log on error
+func MustReadJSONAs[T any](filename string) T {
+	data, err := ReadJSONAs[T](filename)
+	if err != nil {
+		Logger.Fatal().Err(err).Str("file", filename).Msg("Failed to read json file")
+	}
+	return data
+}
+
+// ReadYAML reads a YAML file into data
+func ReadYAML[T any](filename string, data *T) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// an empty file decodes to the zero value
+	if err := yaml.NewDecoder(file).Decode(data); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to decode yaml file %s: %w", filename, err)
+	}
+	return nil
+}
+
+// ReadYAMLAs reads a YAML file and returns the decoded value
+func ReadYAMLAs[T any](filename string) (T, error) {
+	var data T
+	err := ReadYAML(filename, &data)
+	return data, err
+}
+
+// MustReadYAMLAs is like ReadYAMLAs, but exits with a fatal log on error
+func MustReadYAMLAs[T any](filename string) T {
+	data, err := ReadYAMLAs[T](filename)
+	if err != nil {
+		Logger.Fatal().Err(err).Str("file", filename).Msg("Failed to read yaml file")
+	}
+	return data
+}
+
+// ReadTOML reads a TOML file into data
+func ReadTOML[T any](filename string, data *T) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := toml.NewDecoder(file).Decode(data); err != nil {
+		return tomlDecodeError(filename, err)
+	}
+	return nil
+}
+
+// tomlDecodeError wraps a toml decoding error with the file name and, if known, the line of the error
+func tomlDecodeError(filename string, err error) error {
+	var decodeErr *toml.DecodeError
+	if errors.As(err, &decodeErr) {
+		row, col := decodeErr.Position()
+		return fmt.Errorf("failed to decode toml file %s at line %d column %d: %w", filename, row, col, err)
+	}
+	return fmt.Errorf("failed to decode toml file %s: %w", filename, err)
+}
+
+// ReadTOMLAs reads a TOML file and returns the decoded value
+func ReadTOMLAs[T any](filename string) (T, error) {
+	var data T
+	err := ReadTOML(filename, &data)
+	return data, err
+}
+
+// MustReadTOMLAs is like ReadTOMLAs, but exits with a fatal log on error
+func MustReadTOMLAs[T any](filename string) T {
+	data, err := ReadTOMLAs[T](filename)
+	if err != nil {
+		Logger.Fatal().Err(err).Str("file", filename).Msg("Failed to read toml file")
+	}
+	return data
+}
+
+// ReadJSONStrict is like ReadJSON, but fails on fields of the file missing in data, e.g. a typo like "titel".
+// The error names the unknown field and its line.
+func ReadJSONStrict[T any](filename string, data *T) error {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(data); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+			return jsonDecodeError(filename, err)
+		}
+		// unknown fields are reported without offset, so the line is found by searching the field key
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			re := regexp.MustCompile(regexp.QuoteMeta(field) + `\s*:`)
+			if loc := re.FindIndex(content); loc != nil {
+				line := 1 + bytes.Count(content[:loc[0]], []byte{'\n'})
+				return fmt.Errorf("failed to decode json file %s at line %d: %w", filename, line, err)
+			}
+		}
+		return fmt.Errorf("failed to decode json file %s: %w", filename, err)
+	}
+	return nil
+}
+
+// ReadYAMLStrict is like ReadYAML, but fails on fields of the file missing in data.
+// The error names the unknown field and its line.
+func ReadYAMLStrict[T any](filename string, data *T) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	dec := yaml.NewDecoder(file)
+	dec.KnownFields(true)
+	if err := dec.Decode(data); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to decode yaml file %s: %w", filename, err)
+	}
+	return nil
+}
+
+// ReadTOMLStrict is like ReadTOML, but fails on fields of the file missing in data.
+// The error names the unknown fields and their lines.
+func ReadTOMLStrict[T any](filename string, data *T) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	dec := toml.NewDecoder(file)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(data); err != nil {
+		var strictErr *toml.StrictMissingError
+		if errors.As(err, &strictErr) {
+			var fields []string
+			for _, e := range strictErr.Errors {
+				row, _ := e.Position()
+				fields = append(fields, fmt.Sprintf("%s (line %d)", strings.Join(e.Key(), "."), row))
+			}
+			return fmt.Errorf("failed to decode toml file %s, unknown fields: %s: %w", filename, strings.Join(fields, ", "), err)
+		}
+		return tomlDecodeError(filename, err)
+	}
+	return nil
+}
+
+// WriteYAML writes data to a file in YAML format
+func WriteYAML(filename string, data interface{}) error {
+	content, err := yaml.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return WriteText(filename, string(content))
+}
+
+// WriteTOML writes data to a file in TOML format
+func WriteTOML(filename string, data interface{}) error {
+	content, err := toml.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return WriteText(filename, string(content))
+}
+
+// structFormat returns the format of a file from its extension: "json", "yaml", "toml", or "" if it has no extension
+func structFormat(filename string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	switch ext {
+	case ".json":
+		return "json", nil
+	case ".yaml", ".yml":
+		return "yaml", nil
+	case ".toml":
+		return "toml", nil
+	case "":
+		return "", nil
+	default:
+		return "", fmt.Errorf("unsupported file extension %s of %s, expected one of .json, .yaml, .yml, .toml", ext, filename)
+	}
+}
+
+// ReadStruct reads a JSON, YAML or TOML file into data, depending on the file extension.
+// If the file has no extension, the format is sniffed from the content.
+func ReadStruct[T any](filename string, data *T) error {
+	format, err := structFormat(filename)
+	if err != nil {
+		return err
+	}
+
+	if format == "" {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		trimmed := strings.TrimSpace(string(content))
+		switch {
+		case strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "["):
+			format = "json"
+		case toml.Unmarshal(content, new(map[string]interface{})) == nil:
+			format = "toml"
+		default:
+			format = "yaml"
+		}
+	}
+
+	switch format {
+	case "json":
+		return ReadJSON(filename, data)
+	case "yaml":
+		return ReadYAML(filename, data)
+	default:
+		return ReadTOML(filename, data)
+	}
+}
+
+// WriteStruct writes data to a JSON, YAML or TOML file, depending on the file extension
+func WriteStruct[T any](filename string, data T) error {
+	format, err := structFormat(filename)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		return WriteJSON(filename, data)
+	case "yaml":
+		return WriteYAML(filename, data)
+	case "toml":
+		return WriteTOML(filename, data)
+	default:
+		return fmt.Errorf("missing file extension of %s, expected one of .json, .yaml, .yml, .toml", filename)
+	}
 }
 
+// ReadText reads a file as a string. The path is expanded by ExpandPath.
 func ReadText(filename string) (string, error) {
+	filename, err := ExpandPath(filename)
+	if err != nil {
+		return "", err
+	}
+
 	content, err := os.ReadFile(filename)
 	if err != nil {
 		return "", err
@@ -71,7 +416,13 @@ func ReadText(filename string) (string, error) {
 	return string(content), nil
 }
 
+// WriteText writes a string to a file, creating its directory if missing. The path is expanded by ExpandPath.
 func WriteText(filename, content string) error {
+	filename, err := ExpandPath(filename)
+	if err != nil {
+		return err
+	}
+
 	dir := filepath.Dir(filename)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
@@ -80,119 +431,1187 @@ func WriteText(filename, content string) error {
 	return os.WriteFile(filename, []byte(content), 0644)
 }
 
-// CopyFile copies a file from src to dst
-func CopyFile(src, dst string) error {
-	// create dst directory recursively
-	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
-		return err
+// WriteTextIfChanged writes content to a file only if the existing content differs, and returns whether it was written.
+// The file is written atomically with AtomicWriteFile, and left untouched, mtime included, when identical.
+func WriteTextIfChanged(filename, content string) (changed bool, err error) {
+	return writeIfChanged(filename, []byte(content))
+}
+
+// WriteJSONIfChanged is like WriteJSON, but only writes if the existing content differs, see WriteTextIfChanged
+func WriteJSONIfChanged(filename string, data interface{}) (changed bool, err error) {
+	content, err := json.MarshalIndent(data, "", "    ")
+	if err != nil {
+		return false, err
 	}
+	return writeIfChanged(filename, content)
+}
 
-	srcFile, err := os.Open(src)
+// WriteYAMLIfChanged is like WriteYAML, but only writes if the existing content differs, see WriteTextIfChanged
+func WriteYAMLIfChanged(filename string, data interface{}) (changed bool, err error) {
+	content, err := yaml.Marshal(data)
 	if err != nil {
-		return err
+		return false, err
 	}
-	defer srcFile.Close()
+	return writeIfChanged(filename, content)
+}
 
-	dstFile, err := os.Create(dst)
+func writeIfChanged(filename string, content []byte) (bool, error) {
+	same, err := fileContentEqual(filename, content)
 	if err != nil {
-		return err
+		return false, err
 	}
-	defer dstFile.Close()
+	if same {
+		return false, nil
+	}
+
+	if err := AtomicWriteFile(filename, bytes.NewReader(content)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
 
-	_, err = io.Copy(dstFile, srcFile)
+// fileContentEqual returns true if the file exists and its content is content.
+// The file is compared in chunks, so a large file is not loaded in memory.
+func fileContentEqual(filename string, content []byte) (bool, error) {
+	f, err := os.Open(filename)
 	if err != nil {
-		return err
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
 	}
+	defer f.Close()
 
-	// copy file mode
-	srcInfo, err := os.Stat(src)
+	info, err := f.Stat()
 	if err != nil {
-		return err
+		return false, err
+	}
+	if !info.Mode().IsRegular() || info.Size() != int64(len(content)) {
+		return false, nil
 	}
 
-	return os.Chmod(dst, srcInfo.Mode())
+	buf := make([]byte, 64*1024)
+	for offset := 0; offset < len(content); {
+		n, err := io.ReadFull(f, buf[:min(len(buf), len(content)-offset)])
+		if err != nil {
+			// the file changed size while reading
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return false, nil
+			}
+			return false, err
+		}
+		if !bytes.Equal(buf[:n], content[offset:offset+n]) {
+			return false, nil
+		}
+		offset += n
+	}
+	return true, nil
 }
 
-// MoveFile moves a file from src to dst
-func MoveFile(src, dst string) error {
-	if err := CopyFile(src, dst); err != nil {
+// AppendText appends content to a file, the file and its directory are created if missing
+func AppendText(filename, content string) error {
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
 
-	return os.Remove(src)
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err = f.WriteString(content); err != nil {
+		return err
+	}
+	return f.Close()
 }
 
-// CopyDir copies a directory from src to dst
-func CopyDir(src, dst string) error {
-	// create dst directory recursively
-	if err := os.MkdirAll(dst, 0755); err != nil {
+// ForEachLine calls fn for each line of the file, without the line ending ("\n" or "\r\n").
+// The file is streamed, so it works for huge files. Iteration stops at the first error returned by fn.
+func ForEachLine(filename string, fn func(line string) error) error {
+	f, err := os.Open(filename)
+	if err != nil {
 		return err
 	}
+	defer f.Close()
 
-	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
 			return err
 		}
+		// the last line may not end with a newline
+		if line == "" && err == io.EOF {
+			return nil
+		}
 
-		rel, err := filepath.Rel(src, path)
-		if err != nil {
-			return err
+		line = strings.TrimSuffix(line, "\n")
+		line = strings.TrimSuffix(line, "\r")
+		if fnErr := fn(line); fnErr != nil {
+			return fnErr
 		}
 
-		dstPath := filepath.Join(dst, rel)
-		if info.IsDir() {
-			return os.MkdirAll(dstPath, info.Mode())
+		if err == io.EOF {
+			return nil
 		}
+	}
+}
 
-		return CopyFile(path, dstPath)
+// ReadLines returns the lines of the file, without the line endings
+func ReadLines(filename string) ([]string, error) {
+	var lines []string
+	err := ForEachLine(filename, func(line string) error {
+		lines = append(lines, line)
+		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return lines, nil
 }
 
-// MoveDir moves a directory from src to dst
-func MoveDir(src, dst string) error {
-	if err := CopyDir(src, dst); err != nil {
-		return err
+// WriteLines writes lines to a file, each followed by "\n"
+func WriteLines(filename string, lines []string) error {
+	var sb strings.Builder
+	for _, line := range lines {
+		sb.WriteString(line)
+		sb.WriteString("\n")
 	}
-
-	return os.RemoveAll(src)
+	return WriteText(filename, sb.String())
 }
 
-// FindGitRepoRoot returns the root directory of the git repository
-func FindGitRepoRoot() (string, error) {
-	wd, err := os.Getwd()
+// tailChunkSize is the size of the chunks read backwards by ReadLastNLines
+const tailChunkSize = 4096
+
+// ReadLastNLines returns the last n lines of the file, without the line endings, like `tail -n`.
+// The file is read backwards in chunks from the end, so only the tail is loaded in memory.
+func ReadLastNLines(filename string, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	f, err := os.Open(filename)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	p := wd
-	for {
-		if _, err := os.Stat(p + "/.git"); err == nil {
-			return p, nil
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// chunks are collected from the end of the file, and joined in order at the end
+	var chunks [][]byte
+	newlines := 0
+	pos := info.Size()
+	for pos > 0 && newlines <= n {
+		size := int64(tailChunkSize)
+		if pos < size {
+			size = pos
 		}
-		if p == "/" {
-			return "", fmt.Errorf("Git repo root not found")
+		pos -= size
+
+		chunk := make([]byte, size)
+		if _, err := f.ReadAt(chunk, pos); err != nil && err != io.EOF {
+			return nil, err
+		}
+		// the newline ending the last line doesn't start a new line
+		if len(chunks) == 0 && chunk[len(chunk)-1] == '\n' {
+			newlines--
 		}
-		p = filepath.Dir(p)
+		newlines += bytes.Count(chunk, []byte{'\n'})
+		chunks = append(chunks, chunk)
+	}
+
+	var buf []byte
+	for i := len(chunks) - 1; i >= 0; i-- {
+		buf = append(buf, chunks[i]...)
+	}
+	if len(buf) == 0 {
+		return nil, nil
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
 	}
+	return lines, nil
 }
 
-// PathExists returns true if the path exists
-func PathExists(path string) bool {
-	_, err := os.Stat(path)
-	return err == nil || os.IsExist(err)
+// ReadTailBytes returns at most the last maxBytes bytes of the file
+func ReadTailBytes(filename string, maxBytes int64) ([]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := info.Size()
+	if maxBytes < 0 {
+		maxBytes = 0
+	}
+	if maxBytes > size {
+		maxBytes = size
+	}
+
+	buf := make([]byte, maxBytes)
+	if _, err := f.ReadAt(buf, size-maxBytes); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf, nil
 }
 
-func FileExists(path string) bool {
-	info, err := os.Stat(path)
-	if os.IsNotExist(err) {
-		return false
+// AtomicWriteFile writes the content of r to path atomically, by writing to a temporary file in the same directory
+// and renaming it to path. The mode of an existing file at path is preserved, otherwise 0644 is used.
+func AtomicWriteFile(path string, r io.Reader) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
 	}
-	return !info.IsDir()
+	return AtomicWriteFileMode(path, r, mode)
 }
 
-func DirExists(path string) bool {
-	info, err := os.Stat(path)
-	if os.IsNotExist(err) {
-		return false
+// AtomicWriteFileMode is like AtomicWriteFile, but sets the mode of the written file.
+//
+// The temporary file is synced before the rename and the directory is synced after it,
+// so the file is either the old or the new content after a power loss. The temporary file is removed on failure.
+func AtomicWriteFileMode(path string, r io.Reader, mode os.FileMode) (err error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".tmp.*")
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err = io.Copy(f, r); err != nil {
+		return err
+	}
+	if err = f.Chmod(mode); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	// syncing a directory is not supported on windows
+	if runtime.GOOS != "windows" {
+		d, err := os.Open(dir)
+		if err != nil {
+			return err
+		}
+		defer d.Close()
+		return d.Sync()
+	}
+	return nil
+}
+
+// CopyFile copies a file from src to dst
+func CopyFile(src, dst string) error {
+	_, err := CopyFileN(src, dst)
+	return err
+}
+
+// ErrExists is returned by the copy and download helpers when the destination exists and WithNoOverwrite is set
+var ErrExists = fmt.Errorf("destination already exists: %w", os.ErrExist)
+
+// CopyFileN copies a file from src to dst and returns the number of bytes copied.
+// Copying a file onto itself is a no-op.
+func CopyFileN(src, dst string, opts ...CopyOption) (int64, error) {
+	opt, err := newCopyOptions(opts)
+	if err != nil {
+		return 0, err
+	}
+	return copyFileContext(context.Background(), src, dst, opt, nil)
+}
+
+// MoveFile moves a file from src to dst
+func MoveFile(src, dst string) error {
+	// create dst directory recursively
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	// fall back to copy and remove, e.g. across filesystems
+	if err := CopyFileContext(context.Background(), src, dst); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// BackupFile copies path to path.bak.<TimeStrMilliSec()>, keeping its mode, then removes the older backups of path
+// beyond the newest keep ones. Other files in the directory are left alone. A keep <= 0 removes no backup.
+//
+// A missing path returns an error matching os.ErrNotExist, without creating a backup.
+func BackupFile(path string, keep int) (backupPath string, err error) {
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+
+	for {
+		backupPath = path + ".bak." + TimeStrMilliSec()
+		err = CopyFileContext(context.Background(), path, backupPath, WithNoOverwrite{})
+		if !errors.Is(err, ErrExists) {
+			break
+		}
+		// a backup was already made in this millisecond
+		time.Sleep(time.Millisecond)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if keep <= 0 {
+		return backupPath, nil
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return backupPath, err
+	}
+	type backup struct {
+		name string
+		time time.Time
+	}
+	var backups []backup
+	prefix := filepath.Base(path) + ".bak."
+	for _, e := range entries {
+		stamp, ok := strings.CutPrefix(e.Name(), prefix)
+		if !ok || e.IsDir() {
+			continue
+		}
+		t, err := time.ParseInLocation(TimeLayoutMilliSec, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{name: e.Name(), time: t})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].time.After(backups[j].time)
+	})
+
+	for i := keep; i < len(backups); i++ {
+		old := filepath.Join(filepath.Dir(path), backups[i].name)
+		if err := os.Remove(old); err != nil {
+			return backupPath, err
+		}
+		Logger.Debug().Str("path", old).Msg("Removed old backup")
+	}
+	return backupPath, nil
+}
+
+// CopyFileContext copies a file from src to dst in chunks, checking ctx between chunks.
+//
+// On cancellation, it returns an error wrapping ctx.Err() and removes the partial dst, unless WithKeepPartial is set.
+func CopyFileContext(ctx context.Context, src, dst string, opts ...CopyOption) error {
+	opt, err := newCopyOptions(opts)
+	if err != nil {
+		return err
+	}
+	_, err = copyFileContext(ctx, src, dst, opt, nil)
+	return err
+}
+
+// CopyDir copies a directory from src to dst, see CopyDirContext for the options
+func CopyDir(src, dst string, opts ...CopyOption) error {
+	return CopyDirContext(context.Background(), src, dst, opts...)
+}
+
+// CopyOption is an option for the copy helpers, like CopyDirContext
+type CopyOption interface {
+	applyTo(*copyOptions) error
+}
+
+type copyOptions struct {
+	Progress        func(copiedFiles, totalFiles int, copiedBytes, totalBytes int64)
+	CleanupOnCancel bool
+	PreserveTimes   bool
+	NoOverwrite     bool
+	KeepPartial     bool
+	BufferSize      int
+	Clone           bool
+	HardLink        bool
+}
+
+func newCopyOptions(opts []CopyOption) (*copyOptions, error) {
+	opt := &copyOptions{
+		BufferSize: copyChunkSize,
+	}
+	for _, o := range opts {
+		if err := o.applyTo(opt); err != nil {
+			return nil, err
+		}
+	}
+	return opt, nil
+}
+
+// WithProgress is a copy option to receive progress updates.
+// An initial walk is done to compute totalFiles and totalBytes before copying starts.
+type WithProgress func(copiedFiles, totalFiles int, copiedBytes, totalBytes int64)
+
+func (w WithProgress) applyTo(o *copyOptions) error {
+	o.Progress = w
+	return nil
+}
+
+// WithCleanupOnCancel is a copy option to remove the files and directories created by the copy
+// when the context is cancelled
+type WithCleanupOnCancel struct {
+}
+
+func (w WithCleanupOnCancel) applyTo(o *copyOptions) error {
+	o.CleanupOnCancel = true
+	return nil
+}
+
+// WithPreserveTimes is a copy option to copy the access and modification times of the source files
+type WithPreserveTimes struct {
+}
+
+func (w WithPreserveTimes) applyTo(o *copyOptions) error {
+	o.PreserveTimes = true
+	return nil
+}
+
+// WithNoOverwrite is a copy and download option to fail with ErrExists instead of overwriting an existing
+// destination file
+type WithNoOverwrite struct {
+}
+
+func (w WithNoOverwrite) applyTo(o *copyOptions) error {
+	o.NoOverwrite = true
+	return nil
+}
+
+// WithKeepPartial is a copy option to keep the partially written destination file when the copy is cancelled
+type WithKeepPartial struct {
+}
+
+func (w WithKeepPartial) applyTo(o *copyOptions) error {
+	o.KeepPartial = true
+	return nil
+}
+
+// WithBufferSize is a copy option to set the size of the copy buffer, 1MB by default.
+// The context of the Context variants is checked after each buffer.
+type WithBufferSize int
+
+func (w WithBufferSize) applyTo(o *copyOptions) error {
+	if w <= 0 {
+		return fmt.Errorf("invalid buffer size: %d", w)
+	}
+	o.BufferSize = int(w)
+	return nil
+}
+
+// copyChunkSize is the default size of the chunks used by copyFileContext, ctx is checked between chunks
+const copyChunkSize = 1024 * 1024
+
+// CopyDirContext copies a directory from src to dst, checking ctx between files and between chunks of large files.
+//
+// On cancellation, it returns an error wrapping ctx.Err(). The file being copied is removed as by CopyFileContext,
+// but the files already copied are left in place, unless WithCleanupOnCancel is set.
+func CopyDirContext(ctx context.Context, src, dst string, opts ...CopyOption) error {
+	opt, err := newCopyOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	var totalFiles int
+	var totalBytes int64
+	if opt.Progress != nil {
+		err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				totalFiles++
+				totalBytes += info.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// created records the paths created by this copy, used by WithCleanupOnCancel
+	var created []string
+	var copiedFiles int
+	var copiedBytes int64
+
+	err = func() error {
+		if !PathExists(dst) {
+			created = append(created, dst)
+		}
+		// create dst directory recursively
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+
+		return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(src, path)
+			if err != nil {
+				return err
+			}
+
+			dstPath := filepath.Join(dst, rel)
+			if !PathExists(dstPath) {
+				created = append(created, dstPath)
+			}
+			if info.IsDir() {
+				return os.MkdirAll(dstPath, info.Mode())
+			}
+
+			if opt.Clone {
+				if _, err := cloneFile(path, dstPath, opt); err != nil {
+					return err
+				}
+				copiedBytes += info.Size()
+			} else {
+				_, err = copyFileContext(ctx, path, dstPath, opt, func(n int64) {
+					copiedBytes += n
+					if opt.Progress != nil {
+						opt.Progress(copiedFiles, totalFiles, copiedBytes, totalBytes)
+					}
+				})
+				if err != nil {
+					return err
+				}
+			}
+			copiedFiles++
+			if opt.Progress != nil {
+				opt.Progress(copiedFiles, totalFiles, copiedBytes, totalBytes)
+			}
+			return nil
+		})
+	}()
+
+	if err != nil && ctx.Err() != nil && opt.CleanupOnCancel {
+		// remove in reverse order, so files are removed before their parent directories
+		for i := len(created) - 1; i >= 0; i-- {
+			if err := os.Remove(created[i]); err != nil && !os.IsNotExist(err) {
+				Logger.Warn().Err(err).Str("path", created[i]).Msg("Failed to clean up after cancelled copy")
+			}
+		}
+	}
+
+	return err
+}
+
+// copyFileContext copies a file from src to dst in chunks, checking ctx between chunks.
+// onWrite is called with the size of each written chunk.
+func copyFileContext(ctx context.Context, src, dst string, opt *copyOptions, onWrite func(n int64)) (int64, error) {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return 0, err
+	}
+
+	if dstInfo, err := os.Stat(dst); err == nil {
+		if os.SameFile(srcInfo, dstInfo) {
+			return 0, nil
+		}
+		if opt.NoOverwrite {
+			return 0, fmt.Errorf("%w: %s", ErrExists, dst)
+		}
+	}
+
+	// create dst directory recursively
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return 0, err
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer srcFile.Close()
+
+	flag := os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	if opt.NoOverwrite {
+		flag |= os.O_EXCL
+	}
+	dstFile, err := os.OpenFile(dst, flag, 0666)
+	if err != nil {
+		if os.IsExist(err) {
+			return 0, fmt.Errorf("%w: %s", ErrExists, dst)
+		}
+		return 0, err
+	}
+	defer dstFile.Close()
+
+	var written int64
+	buf := make([]byte, opt.BufferSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			if !opt.KeepPartial {
+				dstFile.Close()
+				os.Remove(dst)
+			}
+			return written, fmt.Errorf("copy %s to %s cancelled: %w", src, dst, err)
+		}
+		n, err := srcFile.Read(buf)
+		if n > 0 {
+			if _, err := dstFile.Write(buf[:n]); err != nil {
+				return written, err
+			}
+			written += int64(n)
+			if onWrite != nil {
+				onWrite(int64(n))
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+
+	if err := dstFile.Close(); err != nil {
+		return written, err
+	}
+
+	// copy file mode
+	if err := os.Chmod(dst, srcInfo.Mode()); err != nil {
+		return written, err
+	}
+
+	if opt.PreserveTimes {
+		if err := os.Chtimes(dst, fileAtime(srcInfo), srcInfo.ModTime()); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// MoveDir moves a directory from src to dst
+func MoveDir(src, dst string) error {
+	if err := CopyDir(src, dst); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(src)
+}
+
+// FindGitRepoRoot returns the root directory of the git repository containing the current working directory
+func FindGitRepoRoot() (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return FindGitRepoRootFrom(wd)
+}
+
+// FindGitRepoRootFrom returns the root directory of the git repository containing start.
+// Both the `.git` directory and the `.git` file of worktrees and submodules are recognized.
+func FindGitRepoRootFrom(start string) (string, error) {
+	p, err := filepath.Abs(start)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(p, ".git")); err == nil {
+			return p, nil
+		}
+		// the root of the filesystem, e.g. "/" or "C:\"
+		parent := filepath.Dir(p)
+		if parent == p {
+			return "", fmt.Errorf("Git repo root not found")
+		}
+		p = parent
+	}
+}
+
+// GitDir returns the git directory of the repository at root, found by FindGitRepoRootFrom.
+// It is root/.git, or the directory referenced by the `gitdir: ...` line of the `.git` file for worktrees and submodules.
+func GitDir(root string) (string, error) {
+	dotGit := filepath.Join(root, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return dotGit, nil
+	}
+
+	content, err := ReadText(dotGit)
+	if err != nil {
+		return "", err
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(content), "gitdir:")
+	if !ok {
+		return "", fmt.Errorf("invalid .git file %s", dotGit)
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(root, gitDir)
+	}
+	return filepath.Clean(gitDir), nil
+}
+
+// GitCommonDir returns the git directory shared by all the worktrees of the repository at root.
+// For the main worktree and submodules, it is the same as GitDir.
+func GitCommonDir(root string) (string, error) {
+	gitDir, err := GitDir(root)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := ReadText(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return gitDir, nil
+		}
+		return "", err
+	}
+	commonDir := strings.TrimSpace(content)
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return filepath.Clean(commonDir), nil
+}
+
+// PathExistsE returns true if the path exists.
+// Unlike PathExists, it returns an error if the path can't be stat-ed for a reason other than absence, e.g. permission denied.
+func PathExistsE(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// IsFile returns true if the path exists and is not a directory
+func IsFile(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return !info.IsDir(), nil
+}
+
+// IsDir returns true if the path exists and is a directory
+func IsDir(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return info.IsDir(), nil
+}
+
+// PathExists returns true if the path exists. Stat failures are treated as absence, use PathExistsE to tell them apart.
+func PathExists(path string) bool {
+	exists, _ := PathExistsE(path)
+	return exists
+}
+
+// FileExists returns true if the path exists and is not a directory. Stat failures are treated as absence, use IsFile to tell them apart.
+func FileExists(path string) bool {
+	isFile, _ := IsFile(path)
+	return isFile
+}
+
+// DirExists returns true if the path exists and is a directory. Stat failures are treated as absence, use IsDir to tell them apart.
+func DirExists(path string) bool {
+	isDir, _ := IsDir(path)
+	return isDir
+}
+
+// WatchEvent is a file change reported by WatchFile and WatchDir
+type WatchEvent struct {
+	// Path is the path of the changed file
+	Path string
+	// Op is the last operation seen on Path within the debounce window
+	Op fsnotify.Op
+}
+
+// WatchOption is an option for WatchFile and WatchDir
+type WatchOption interface {
+	applyTo(*watchOptions) error
+}
+
+type watchOptions struct {
+	Debounce time.Duration
+}
+
+// WithDebounce is a watch option to set the debounce window, 100ms by default.
+// Events on the same path within the window are reported as a single change.
+type WithDebounce time.Duration
+
+func (w WithDebounce) applyTo(o *watchOptions) error {
+	o.Debounce = time.Duration(w)
+	return nil
+}
+
+// WatchFile calls onChange when the file at path changes, until ctx is cancelled.
+//
+// The parent directory is watched instead of the file itself,
+// so the watch survives editors replacing the file by renaming a temporary file onto it.
+// It blocks until ctx is done and returns nil in that case.
+func WatchFile(ctx context.Context, path string, onChange func(event WatchEvent), opts ...WatchOption) error {
+	path = filepath.Clean(path)
+	return watch(ctx, filepath.Dir(path), func(name string) bool {
+		return name == path
+	}, onChange, opts)
+}
+
+// WatchDir calls onChange when a file in dir changes, until ctx is cancelled. Subdirectories are not watched.
+// It blocks until ctx is done and returns nil in that case.
+func WatchDir(ctx context.Context, dir string, onChange func(event WatchEvent), opts ...WatchOption) error {
+	return watch(ctx, filepath.Clean(dir), func(string) bool {
+		return true
+	}, onChange, opts)
+}
+
+func watch(ctx context.Context, dir string, match func(name string) bool, onChange func(event WatchEvent), opts []WatchOption) error {
+	opt := &watchOptions{
+		Debounce: 100 * time.Millisecond,
+	}
+	for _, o := range opts {
+		if err := o.applyTo(opt); err != nil {
+			return err
+		}
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(dir); err != nil {
+		return err
+	}
+
+	// pending holds the last event of each path, until its debounce timer fires.
+	// A timer fires with its generation, so a timer replaced by a later event is ignored.
+	type firing struct {
+		name string
+		gen  int
+	}
+	pending := map[string]WatchEvent{}
+	gens := map[string]int{}
+	timers := map[string]*time.Timer{}
+	fired := make(chan firing)
+	defer func() {
+		for _, t := range timers {
+			t.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			Logger.Warn().Err(err).Str("dir", dir).Msg("Watch error")
+		case e, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			name := filepath.Clean(e.Name)
+			if !match(name) || e.Op == fsnotify.Chmod {
+				continue
+			}
+
+			pending[name] = WatchEvent{Path: name, Op: e.Op}
+			gens[name]++
+			if t, ok := timers[name]; ok {
+				t.Stop()
+			}
+			f := firing{name: name, gen: gens[name]}
+			timers[name] = time.AfterFunc(opt.Debounce, func() {
+				select {
+				case fired <- f:
+				case <-ctx.Done():
+				}
+			})
+		case f := <-fired:
+			if f.gen != gens[f.name] {
+				continue
+			}
+			event := pending[f.name]
+			delete(pending, f.name)
+			delete(timers, f.name)
+			onChange(event)
+		}
+	}
+}
+
+// WithTempDir creates a temporary directory under os.TempDir(), calls fn with it and removes it afterwards,
+// even if fn panics.
+func WithTempDir(prefix string, fn func(dir string) error) error {
+	dir, err := os.MkdirTemp("", prefix)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			Logger.Warn().Err(err).Str("dir", dir).Msg("Failed to remove temp dir")
+		}
+	}()
+
+	return fn(dir)
+}
+
+// WithTempFile creates a temporary file under os.TempDir(), calls fn with it and closes and removes it afterwards,
+// even if fn panics. pattern is the same as in os.CreateTemp.
+func WithTempFile(pattern string, fn func(f *os.File) error) error {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		f.Close()
+		if err := os.Remove(f.Name()); err != nil && !os.IsNotExist(err) {
+			Logger.Warn().Err(err).Str("file", f.Name()).Msg("Failed to remove temp file")
+		}
+	}()
+
+	return fn(f)
+}
+
+// TempFileFromString writes content to a new temporary file, for APIs that only accept file paths.
+// cleanup removes the file.
+func TempFileFromString(content string) (path string, cleanup func(), err error) {
+	f, err := os.CreateTemp("", "goutils-*")
+	if err != nil {
+		return "", nil, err
+	}
+	path = f.Name()
+	cleanup = func() {
+		os.Remove(path)
+	}
+
+	if _, err = f.WriteString(content); err != nil {
+		f.Close()
+		cleanup()
+		return "", nil, err
+	}
+	if err = f.Close(); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return path, cleanup, nil
+}
+
+// walkTree walks root without following symlinks, calling fn for each entry except root.
+// Unreadable entries are skipped with a warning, their errors are returned only if nothing was readable.
+func walkTree(root string, opts []WalkOption, fn func(rel string, d os.DirEntry) error) error {
+	m, err := newWalkMatcher(root, opts)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	readable := false
+	err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if path == root {
+			if err != nil {
+				return err
+			}
+			return m.loadIgnoreFiles("")
+		}
+		if err != nil {
+			Logger.Warn().Err(err).Str("path", path).Msg("Skip unreadable path")
+			errs = append(errs, err)
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if m.skip(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if err := m.loadIgnoreFiles(rel); err != nil {
+				return err
+			}
+		}
+
+		if err := fn(rel, d); err != nil {
+			Logger.Warn().Err(err).Str("path", path).Msg("Skip unreadable path")
+			errs = append(errs, err)
+			return nil
+		}
+		readable = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !readable && len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// DirSize returns the total size in bytes of the files under path. Symlinks are not followed.
+func DirSize(path string, opts ...WalkOption) (int64, error) {
+	var size int64
+	err := walkTree(path, opts, func(rel string, d os.DirEntry) error {
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// DirSizeHuman returns the total size of the files under path in a human-readable form, like 1.4GiB
+func DirSizeHuman(path string, opts ...WalkOption) (string, error) {
+	size, err := DirSize(path, opts...)
+	if err != nil {
+		return "", err
+	}
+	return BytesToStr(size), nil
+}
+
+// CountFiles returns the number of files and directories under path, path itself excluded.
+// Symlinks are counted as files and not followed.
+func CountFiles(path string, opts ...WalkOption) (files, dirs int, err error) {
+	err = walkTree(path, opts, func(rel string, d os.DirEntry) error {
+		if d.IsDir() {
+			dirs++
+		} else {
+			files++
+		}
+		return nil
+	})
+	return files, dirs, err
+}
+
+// EnsureDir creates the directory and its parents if missing. It fails if path exists and is not a directory.
+func EnsureDir(path string, mode os.FileMode) error {
+	info, err := os.Stat(path)
+	if err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("%s exists and is not a directory", path)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	return os.MkdirAll(path, mode)
+}
+
+// EmptyDir removes the content of the directory, but keeps the directory itself, e.g. for a mount point.
+// Read-only children are made writable before removal.
+func EmptyDir(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		child := filepath.Join(path, entry.Name())
+		if entry.IsDir() {
+			// files can't be removed from a read-only directory
+			err := filepath.WalkDir(child, func(p string, d os.DirEntry, err error) error {
+				if err == nil && d.IsDir() {
+					return os.Chmod(p, 0755)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if err := os.RemoveAll(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveGlob removes all paths matching the pattern, see filepath.Glob for the pattern syntax
+func RemoveGlob(pattern string) error {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	for _, m := range matches {
+		if err := os.RemoveAll(m); err != nil {
+			return err
+		}
 	}
-	return info.IsDir()
+	Logger.Debug().Str("pattern", pattern).Int("count", len(matches)).Msg("Removed paths matching pattern")
+	return nil
 }
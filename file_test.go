@@ -1,7 +1,14 @@
 package goutils_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
@@ -72,3 +79,976 @@ func TestWriteText(t *testing.T) {
 	err := goutils.WriteText(filename, data)
 	ast.NoError(err)
 }
+
+func TestCopyDirContext(t *testing.T) {
+	ast := assert.New(t)
+
+	src := filepath.Join(t.TempDir(), "src")
+	for i := 0; i < 20; i++ {
+		err := goutils.WriteText(filepath.Join(src, fmt.Sprintf("d%d", i%3), fmt.Sprintf("%d.txt", i)), strings.Repeat("a", 1024))
+		ast.NoError(err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	var lastFiles, lastTotal int
+	var lastBytes int64
+	err := goutils.CopyDirContext(context.Background(), src, dst, goutils.WithProgress(func(copiedFiles, totalFiles int, copiedBytes, totalBytes int64) {
+		lastFiles, lastTotal, lastBytes = copiedFiles, totalFiles, copiedBytes
+	}))
+	ast.NoError(err)
+	ast.Equal(20, lastFiles)
+	ast.Equal(20, lastTotal)
+	ast.Equal(int64(20*1024), lastBytes)
+
+	// cancel mid-copy
+	dst = filepath.Join(t.TempDir(), "dst")
+	ctx, cancel := context.WithCancel(context.Background())
+	copied := 0
+	err = goutils.CopyDirContext(ctx, src, dst, goutils.WithProgress(func(copiedFiles, totalFiles int, copiedBytes, totalBytes int64) {
+		copied = copiedFiles
+		if copiedFiles == 5 {
+			cancel()
+		}
+	}))
+	ast.ErrorIs(err, context.Canceled)
+	ast.Equal(5, copied)
+	ast.True(goutils.DirExists(dst))
+
+	// cancel mid-copy with cleanup
+	dst = filepath.Join(t.TempDir(), "dst")
+	ctx, cancel = context.WithCancel(context.Background())
+	err = goutils.CopyDirContext(ctx, src, dst, goutils.WithCleanupOnCancel{}, goutils.WithProgress(func(copiedFiles, totalFiles int, copiedBytes, totalBytes int64) {
+		if copiedFiles == 5 {
+			cancel()
+		}
+	}))
+	ast.ErrorIs(err, context.Canceled)
+	ast.False(goutils.PathExists(dst))
+}
+
+func TestPathExists(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	ast.NoError(goutils.WriteText(file, "test"))
+	missing := filepath.Join(dir, "missing")
+
+	exists, err := goutils.PathExistsE(file)
+	ast.NoError(err)
+	ast.True(exists)
+	exists, err = goutils.PathExistsE(missing)
+	ast.NoError(err)
+	ast.False(exists)
+
+	isFile, err := goutils.IsFile(file)
+	ast.NoError(err)
+	ast.True(isFile)
+	isFile, err = goutils.IsFile(dir)
+	ast.NoError(err)
+	ast.False(isFile)
+
+	isDir, err := goutils.IsDir(dir)
+	ast.NoError(err)
+	ast.True(isDir)
+	isDir, err = goutils.IsDir(missing)
+	ast.NoError(err)
+	ast.False(isDir)
+
+	ast.True(goutils.PathExists(file))
+	ast.True(goutils.FileExists(file))
+	ast.False(goutils.FileExists(dir))
+	ast.True(goutils.DirExists(dir))
+	ast.False(goutils.DirExists(missing))
+}
+
+func TestPathExistsPermissionDenied(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permission checks are bypassed as root")
+	}
+	ast := assert.New(t)
+
+	dir := filepath.Join(t.TempDir(), "locked")
+	ast.NoError(os.Mkdir(dir, 0000))
+	defer os.Chmod(dir, 0755)
+	file := filepath.Join(dir, "file.txt")
+
+	_, err := goutils.PathExistsE(file)
+	ast.Error(err)
+	_, err = goutils.IsFile(file)
+	ast.Error(err)
+	_, err = goutils.IsDir(file)
+	ast.Error(err)
+
+	ast.False(goutils.PathExists(file))
+	ast.False(goutils.FileExists(file))
+	ast.False(goutils.DirExists(file))
+}
+
+func TestAtomicWriteFile(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+
+	ast.NoError(goutils.AtomicWriteFile(file, strings.NewReader("v1")))
+	info, err := os.Stat(file)
+	ast.NoError(err)
+	ast.Equal(os.FileMode(0644), info.Mode().Perm())
+
+	ast.NoError(goutils.AtomicWriteFileMode(file, strings.NewReader("v2"), 0600))
+	info, err = os.Stat(file)
+	ast.NoError(err)
+	ast.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	// the existing mode is preserved
+	ast.NoError(goutils.AtomicWriteFile(file, strings.NewReader("v3")))
+	info, err = os.Stat(file)
+	ast.NoError(err)
+	ast.Equal(os.FileMode(0600), info.Mode().Perm())
+	content, err := goutils.ReadText(file)
+	ast.NoError(err)
+	ast.Equal("v3", content)
+
+	// rename fails when the target is a non-empty directory
+	target := filepath.Join(dir, "target")
+	ast.NoError(goutils.WriteText(filepath.Join(target, "child.txt"), "child"))
+	ast.Error(goutils.AtomicWriteFile(target, strings.NewReader("v1")))
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.tmp.*"))
+	ast.NoError(err)
+	ast.Empty(matches)
+}
+
+func TestLines(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "lines.txt")
+	ast.NoError(goutils.WriteLines(file, []string{"a", "b", "c"}))
+	content, err := goutils.ReadText(file)
+	ast.NoError(err)
+	ast.Equal("a\nb\nc\n", content)
+	lines, err := goutils.ReadLines(file)
+	ast.NoError(err)
+	ast.Equal([]string{"a", "b", "c"}, lines)
+
+	// CRLF without trailing newline
+	ast.NoError(goutils.WriteText(file, "a\r\n\r\nb"))
+	lines, err = goutils.ReadLines(file)
+	ast.NoError(err)
+	ast.Equal([]string{"a", "", "b"}, lines)
+
+	ast.NoError(goutils.WriteText(file, ""))
+	lines, err = goutils.ReadLines(file)
+	ast.NoError(err)
+	ast.Empty(lines)
+
+	_, err = goutils.ReadLines(filepath.Join(dir, "missing.txt"))
+	ast.Error(err)
+
+	// stop at the first error of fn
+	ast.NoError(goutils.WriteLines(file, []string{"a", "stop", "c"}))
+	var visited []string
+	errStop := errors.New("stop")
+	err = goutils.ForEachLine(file, func(line string) error {
+		visited = append(visited, line)
+		if line == "stop" {
+			return errStop
+		}
+		return nil
+	})
+	ast.ErrorIs(err, errStop)
+	ast.Equal([]string{"a", "stop"}, visited)
+}
+
+func TestAppendText(t *testing.T) {
+	ast := assert.New(t)
+
+	file := filepath.Join(t.TempDir(), "sub", "append.txt")
+	ast.NoError(goutils.AppendText(file, "a\n"))
+	ast.NoError(goutils.AppendText(file, "b\n"))
+	content, err := goutils.ReadText(file)
+	ast.NoError(err)
+	ast.Equal("a\nb\n", content)
+}
+
+func TestReadLastNLines(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+
+	naive := func(file string, n int) []string {
+		lines, err := goutils.ReadLines(file)
+		ast.NoError(err)
+		if len(lines) > n {
+			lines = lines[len(lines)-n:]
+		}
+		return lines
+	}
+
+	// line lengths around the internal chunk size of 4096 bytes, with multi-byte runes
+	for _, size := range []int{0, 1, 10, 4095, 4096, 4097, 8191, 8192, 20000} {
+		for _, trailingNewline := range []bool{true, false} {
+			var sb strings.Builder
+			for i := 0; sb.Len() < size; i++ {
+				if i%7 == 0 {
+					sb.WriteString(fmt.Sprintf("行%d\r\n", i))
+				} else {
+					sb.WriteString(fmt.Sprintf("line %d %s\n", i, strings.Repeat("x", i%50)))
+				}
+			}
+			content := sb.String()
+			if len(content) > size {
+				content = content[:size]
+			}
+			if !trailingNewline {
+				content = strings.TrimRight(content, "\r\n")
+			}
+
+			file := filepath.Join(dir, fmt.Sprintf("%d-%v.txt", size, trailingNewline))
+			ast.NoError(goutils.WriteText(file, content))
+
+			for _, n := range []int{1, 2, 10, 200, 10000} {
+				lines, err := goutils.ReadLastNLines(file, n)
+				ast.NoError(err)
+				expected := naive(file, n)
+				if len(expected) == 0 {
+					ast.Empty(lines, "size=%d n=%d", size, n)
+				} else {
+					ast.Equal(expected, lines, "size=%d n=%d", size, n)
+				}
+			}
+		}
+	}
+
+	lines, err := goutils.ReadLastNLines(filepath.Join(dir, "10-true.txt"), 0)
+	ast.NoError(err)
+	ast.Empty(lines)
+}
+
+func TestReadTailBytes(t *testing.T) {
+	ast := assert.New(t)
+
+	file := filepath.Join(t.TempDir(), "tail.txt")
+	ast.NoError(goutils.WriteText(file, "0123456789"))
+
+	data, err := goutils.ReadTailBytes(file, 4)
+	ast.NoError(err)
+	ast.Equal("6789", string(data))
+
+	data, err = goutils.ReadTailBytes(file, 100)
+	ast.NoError(err)
+	ast.Equal("0123456789", string(data))
+}
+
+func TestWatchFile(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "watched.txt")
+	ast.NoError(goutils.WriteText(file, "v0"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan goutils.WatchEvent, 10)
+	done := make(chan error)
+	go func() {
+		done <- goutils.WatchFile(ctx, file, func(event goutils.WatchEvent) {
+			events <- event
+		}, goutils.WithDebounce(50*time.Millisecond))
+	}()
+	// wait for the watcher to be set up
+	time.Sleep(100 * time.Millisecond)
+
+	expectOne := func() {
+		select {
+		case e := <-events:
+			ast.Equal(file, e.Path)
+		case <-time.After(2 * time.Second):
+			ast.Fail("no event received")
+		}
+		select {
+		case e := <-events:
+			ast.Fail("unexpected event", "%v", e)
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+
+	// direct write, in several steps
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_TRUNC, 0644)
+	ast.NoError(err)
+	for i := 0; i < 5; i++ {
+		_, err = f.WriteString("v1")
+		ast.NoError(err)
+	}
+	ast.NoError(f.Close())
+	expectOne()
+
+	// rename and replace, like editors do
+	tmp := filepath.Join(dir, "watched.txt.swp")
+	ast.NoError(goutils.WriteText(tmp, "v2"))
+	ast.NoError(os.Rename(tmp, file))
+	expectOne()
+
+	// the watch survives the replacement
+	ast.NoError(goutils.WriteText(file, "v3"))
+	expectOne()
+
+	// other files in the directory are ignored
+	ast.NoError(goutils.WriteText(filepath.Join(dir, "other.txt"), "other"))
+	select {
+	case e := <-events:
+		ast.Fail("unexpected event", "%v", e)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	cancel()
+	ast.NoError(<-done)
+}
+
+func TestWithTempDir(t *testing.T) {
+	ast := assert.New(t)
+
+	var tempDir string
+	err := goutils.WithTempDir("goutils-test-", func(dir string) error {
+		tempDir = dir
+		return goutils.WriteText(filepath.Join(dir, "a.txt"), "a")
+	})
+	ast.NoError(err)
+	ast.NotEmpty(tempDir)
+	ast.False(goutils.PathExists(tempDir))
+
+	errFn := errors.New("fn failed")
+	err = goutils.WithTempDir("goutils-test-", func(dir string) error {
+		tempDir = dir
+		return errFn
+	})
+	ast.ErrorIs(err, errFn)
+	ast.False(goutils.PathExists(tempDir))
+
+	ast.PanicsWithValue("boom", func() {
+		goutils.WithTempDir("goutils-test-", func(dir string) error {
+			tempDir = dir
+			panic("boom")
+		})
+	})
+	ast.False(goutils.PathExists(tempDir))
+}
+
+func TestWithTempFile(t *testing.T) {
+	ast := assert.New(t)
+
+	var tempFile string
+	err := goutils.WithTempFile("goutils-test-*.txt", func(f *os.File) error {
+		tempFile = f.Name()
+		_, err := f.WriteString("test")
+		return err
+	})
+	ast.NoError(err)
+	ast.True(strings.HasSuffix(tempFile, ".txt"))
+	ast.False(goutils.PathExists(tempFile))
+
+	errFn := errors.New("fn failed")
+	err = goutils.WithTempFile("goutils-test-*", func(f *os.File) error {
+		tempFile = f.Name()
+		return errFn
+	})
+	ast.ErrorIs(err, errFn)
+	ast.False(goutils.PathExists(tempFile))
+
+	ast.PanicsWithValue("boom", func() {
+		goutils.WithTempFile("goutils-test-*", func(f *os.File) error {
+			tempFile = f.Name()
+			panic("boom")
+		})
+	})
+	ast.False(goutils.PathExists(tempFile))
+}
+
+func TestTempFileFromString(t *testing.T) {
+	ast := assert.New(t)
+
+	path, cleanup, err := goutils.TempFileFromString("test")
+	ast.NoError(err)
+	content, err := goutils.ReadText(path)
+	ast.NoError(err)
+	ast.Equal("test", content)
+
+	cleanup()
+	ast.False(goutils.PathExists(path))
+}
+
+func TestReadAs(t *testing.T) {
+	ast := assert.New(t)
+
+	type Config struct {
+		Name string `json:"name" yaml:"name" toml:"name"`
+		Port int    `json:"port" yaml:"port" toml:"port"`
+	}
+	expected := Config{Name: "app", Port: 8080}
+
+	dir := t.TempDir()
+	jsonFile := filepath.Join(dir, "cfg.json")
+	yamlFile := filepath.Join(dir, "cfg.yaml")
+	tomlFile := filepath.Join(dir, "cfg.toml")
+	ast.NoError(goutils.WriteText(jsonFile, `{"name": "app", "port": 8080}`))
+	ast.NoError(goutils.WriteText(yamlFile, "name: app\nport: 8080\n"))
+	ast.NoError(goutils.WriteText(tomlFile, "name = \"app\"\nport = 8080\n"))
+
+	cfg, err := goutils.ReadJSONAs[Config](jsonFile)
+	ast.NoError(err)
+	ast.Equal(expected, cfg)
+	cfg, err = goutils.ReadYAMLAs[Config](yamlFile)
+	ast.NoError(err)
+	ast.Equal(expected, cfg)
+	cfg, err = goutils.ReadTOMLAs[Config](tomlFile)
+	ast.NoError(err)
+	ast.Equal(expected, cfg)
+	ast.Equal(expected, goutils.MustReadJSONAs[Config](jsonFile))
+
+	missing := filepath.Join(dir, "missing.json")
+	_, err = goutils.ReadJSONAs[Config](missing)
+	ast.ErrorIs(err, os.ErrNotExist)
+	_, err = goutils.ReadYAMLAs[Config](missing)
+	ast.ErrorIs(err, os.ErrNotExist)
+	_, err = goutils.ReadTOMLAs[Config](missing)
+	ast.ErrorIs(err, os.ErrNotExist)
+
+	// malformed content
+	ast.NoError(goutils.WriteText(jsonFile, `{"name": "app", "port": }`))
+	_, err = goutils.ReadJSONAs[Config](jsonFile)
+	ast.Error(err)
+	ast.Contains(err.Error(), jsonFile)
+	ast.Contains(err.Error(), "offset 25")
+
+	ast.NoError(goutils.WriteText(jsonFile, `{"name": "app", "port": "80"}`))
+	_, err = goutils.ReadJSONAs[Config](jsonFile)
+	ast.Error(err)
+	ast.Contains(err.Error(), "offset 28")
+
+	ast.NoError(goutils.WriteText(yamlFile, "name: app\nport: [\n"))
+	_, err = goutils.ReadYAMLAs[Config](yamlFile)
+	ast.Error(err)
+	ast.Contains(err.Error(), yamlFile)
+	ast.Contains(err.Error(), "line")
+
+	ast.NoError(goutils.WriteText(tomlFile, "name = \"app\"\nport = \n"))
+	_, err = goutils.ReadTOMLAs[Config](tomlFile)
+	ast.Error(err)
+	ast.Contains(err.Error(), tomlFile)
+	ast.Contains(err.Error(), "line 2")
+}
+
+func TestReadWriteStruct(t *testing.T) {
+	ast := assert.New(t)
+
+	type Server struct {
+		Host string `json:"host" yaml:"host" toml:"host"`
+		Port int    `json:"port" yaml:"port" toml:"port"`
+	}
+	type Config struct {
+		Name    string   `json:"name" yaml:"name" toml:"name"`
+		Tags    []string `json:"tags" yaml:"tags" toml:"tags"`
+		Server  Server   `json:"server" yaml:"server" toml:"server"`
+		Enabled bool     `json:"enabled" yaml:"enabled" toml:"enabled"`
+	}
+	expected := Config{Name: "app", Tags: []string{"a", "b"}, Server: Server{Host: "localhost", Port: 8080}, Enabled: true}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"cfg.json": `{"name": "app", "tags": ["a", "b"], "server": {"host": "localhost", "port": 8080}, "enabled": true}`,
+		"cfg.yaml": "name: app\ntags: [a, b]\nserver:\n  host: localhost\n  port: 8080\nenabled: true\n",
+		"cfg.yml":  "name: app\ntags: [a, b]\nserver:\n  host: localhost\n  port: 8080\nenabled: true\n",
+		"cfg.toml": "name = \"app\"\ntags = [\"a\", \"b\"]\nenabled = true\n\n[server]\nhost = \"localhost\"\nport = 8080\n",
+	}
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		ast.NoError(goutils.WriteText(file, content))
+
+		var cfg Config
+		ast.NoError(goutils.ReadStruct(file, &cfg), name)
+		ast.Equal(expected, cfg, name)
+
+		// round trip
+		out := filepath.Join(dir, "out", name)
+		ast.NoError(goutils.WriteStruct(out, cfg), name)
+		cfg = Config{}
+		ast.NoError(goutils.ReadStruct(out, &cfg), name)
+		ast.Equal(expected, cfg, name)
+
+		// sniff the format without extension
+		noExt := filepath.Join(dir, "noext", strings.TrimSuffix(name, filepath.Ext(name))+strings.TrimPrefix(filepath.Ext(name), "."))
+		ast.NoError(goutils.WriteText(noExt, content))
+		cfg = Config{}
+		ast.NoError(goutils.ReadStruct(noExt, &cfg), name)
+		ast.Equal(expected, cfg, name)
+	}
+
+	var cfg Config
+	err := goutils.ReadStruct(filepath.Join(dir, "cfg.ini"), &cfg)
+	ast.Error(err)
+	ast.Contains(err.Error(), ".ini")
+	ast.Error(goutils.WriteStruct(filepath.Join(dir, "cfg.ini"), cfg))
+	ast.Error(goutils.WriteStruct(filepath.Join(dir, "cfg"), cfg))
+}
+
+func TestDirSize(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+	ast.NoError(goutils.WriteText(filepath.Join(dir, "a.txt"), strings.Repeat("a", 100)))
+	ast.NoError(goutils.WriteText(filepath.Join(dir, "sub", "b.txt"), strings.Repeat("b", 1000)))
+	ast.NoError(goutils.WriteText(filepath.Join(dir, "sub", "deep", "c.log"), strings.Repeat("c", 10)))
+	ast.NoError(goutils.WriteText(filepath.Join(dir, "empty", ".keep"), ""))
+
+	// the symlinked directory is not followed
+	outside := t.TempDir()
+	ast.NoError(goutils.WriteText(filepath.Join(outside, "big.txt"), strings.Repeat("x", 10000)))
+	ast.NoError(os.Symlink(outside, filepath.Join(dir, "link")))
+	linkInfo, err := os.Lstat(filepath.Join(dir, "link"))
+	ast.NoError(err)
+
+	size, err := goutils.DirSize(dir)
+	ast.NoError(err)
+	ast.Equal(int64(1110)+linkInfo.Size(), size)
+
+	size, err = goutils.DirSize(dir, goutils.WithExclude{"*.log", "link"})
+	ast.NoError(err)
+	ast.Equal(int64(1100), size)
+
+	human, err := goutils.DirSizeHuman(dir, goutils.WithExclude{"link"})
+	ast.NoError(err)
+	ast.Equal("1.1KiB", human)
+
+	files, dirs, err := goutils.CountFiles(dir)
+	ast.NoError(err)
+	ast.Equal(5, files)
+	ast.Equal(3, dirs)
+
+	files, dirs, err = goutils.CountFiles(dir, goutils.WithExclude{"sub"})
+	ast.NoError(err)
+	ast.Equal(3, files)
+	ast.Equal(1, dirs)
+
+	_, err = goutils.DirSize(filepath.Join(dir, "missing"))
+	ast.Error(err)
+}
+
+func TestEnsureDir(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := filepath.Join(t.TempDir(), "a", "b")
+	ast.NoError(goutils.EnsureDir(dir, 0755))
+	ast.True(goutils.DirExists(dir))
+	ast.NoError(goutils.EnsureDir(dir, 0755))
+
+	file := filepath.Join(dir, "file.txt")
+	ast.NoError(goutils.WriteText(file, "test"))
+	ast.Error(goutils.EnsureDir(file, 0755))
+}
+
+func TestEmptyDir(t *testing.T) {
+	ast := assert.New(t)
+
+	// a mount point like directory, which must be kept
+	mount := filepath.Join(t.TempDir(), "mount")
+	ast.NoError(goutils.WriteText(filepath.Join(mount, "a.txt"), "a"))
+	ast.NoError(goutils.WriteText(filepath.Join(mount, "sub", "b.txt"), "b"))
+	ast.NoError(goutils.WriteText(filepath.Join(mount, "readonly", "c.txt"), "c"))
+	ast.NoError(os.Chmod(filepath.Join(mount, "readonly", "c.txt"), 0444))
+	ast.NoError(os.Chmod(filepath.Join(mount, "readonly"), 0555))
+
+	ast.NoError(goutils.EmptyDir(mount))
+	ast.True(goutils.DirExists(mount))
+	entries, err := os.ReadDir(mount)
+	ast.NoError(err)
+	ast.Empty(entries)
+
+	file := filepath.Join(mount, "file.txt")
+	ast.NoError(goutils.WriteText(file, "test"))
+	err = goutils.EmptyDir(file)
+	ast.Error(err)
+	ast.Contains(err.Error(), "not a directory")
+}
+
+func TestRemoveGlob(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+	ast.NoError(goutils.WriteText(filepath.Join(dir, "a.log"), "a"))
+	ast.NoError(goutils.WriteText(filepath.Join(dir, "b.log"), "b"))
+	ast.NoError(goutils.WriteText(filepath.Join(dir, "c.txt"), "c"))
+
+	ast.NoError(goutils.RemoveGlob(filepath.Join(dir, "*.log")))
+	ast.False(goutils.PathExists(filepath.Join(dir, "a.log")))
+	ast.False(goutils.PathExists(filepath.Join(dir, "b.log")))
+	ast.True(goutils.PathExists(filepath.Join(dir, "c.txt")))
+
+	ast.Error(goutils.RemoveGlob("[")) // malformed pattern
+}
+
+func TestFindGitRepoRootFrom(t *testing.T) {
+	ast := assert.New(t)
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	ast.NoError(err)
+
+	// main repository
+	main := filepath.Join(dir, "main")
+	ast.NoError(os.MkdirAll(filepath.Join(main, ".git", "worktrees", "wt"), 0755))
+	ast.NoError(os.MkdirAll(filepath.Join(main, "a", "b"), 0755))
+
+	// worktree, with a .git file
+	wt := filepath.Join(dir, "wt")
+	ast.NoError(goutils.WriteText(filepath.Join(wt, ".git"), "gitdir: "+filepath.Join(main, ".git", "worktrees", "wt")+"\n"))
+	ast.NoError(goutils.WriteText(filepath.Join(main, ".git", "worktrees", "wt", "commondir"), "../..\n"))
+	ast.NoError(os.MkdirAll(filepath.Join(wt, "src"), 0755))
+
+	// submodule, with a relative gitdir
+	sub := filepath.Join(main, "sub")
+	ast.NoError(os.MkdirAll(filepath.Join(main, ".git", "modules", "sub"), 0755))
+	ast.NoError(goutils.WriteText(filepath.Join(sub, ".git"), "gitdir: ../.git/modules/sub\n"))
+
+	root, err := goutils.FindGitRepoRootFrom(filepath.Join(main, "a", "b"))
+	ast.NoError(err)
+	ast.Equal(main, root)
+	gitDir, err := goutils.GitDir(root)
+	ast.NoError(err)
+	ast.Equal(filepath.Join(main, ".git"), gitDir)
+	commonDir, err := goutils.GitCommonDir(root)
+	ast.NoError(err)
+	ast.Equal(filepath.Join(main, ".git"), commonDir)
+
+	root, err = goutils.FindGitRepoRootFrom(filepath.Join(wt, "src"))
+	ast.NoError(err)
+	ast.Equal(wt, root)
+	gitDir, err = goutils.GitDir(root)
+	ast.NoError(err)
+	ast.Equal(filepath.Join(main, ".git", "worktrees", "wt"), gitDir)
+	commonDir, err = goutils.GitCommonDir(root)
+	ast.NoError(err)
+	ast.Equal(filepath.Join(main, ".git"), commonDir)
+
+	root, err = goutils.FindGitRepoRootFrom(sub)
+	ast.NoError(err)
+	ast.Equal(sub, root)
+	gitDir, err = goutils.GitDir(root)
+	ast.NoError(err)
+	ast.Equal(filepath.Join(main, ".git", "modules", "sub"), gitDir)
+
+	_, err = goutils.FindGitRepoRootFrom(filepath.Join(dir, "nowhere"))
+	ast.Error(err)
+}
+
+func TestCopyFileN(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.txt")
+	ast.NoError(goutils.WriteText(src, strings.Repeat("a", 3000)))
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	ast.NoError(os.Chtimes(src, mtime, mtime))
+
+	dst := filepath.Join(dir, "sub", "dst.txt")
+	n, err := goutils.CopyFileN(src, dst, goutils.WithPreserveTimes{}, goutils.WithBufferSize(1000))
+	ast.NoError(err)
+	ast.Equal(int64(3000), n)
+	info, err := os.Stat(dst)
+	ast.NoError(err)
+	ast.True(mtime.Equal(info.ModTime()))
+
+	// without WithPreserveTimes, the mtime is the copy time
+	dst2 := filepath.Join(dir, "dst2.txt")
+	ast.NoError(goutils.CopyFile(src, dst2))
+	info, err = os.Stat(dst2)
+	ast.NoError(err)
+	ast.False(mtime.Equal(info.ModTime()))
+
+	// overwrite guard
+	ast.NoError(goutils.WriteText(dst, "existing"))
+	n, err = goutils.CopyFileN(src, dst, goutils.WithNoOverwrite{})
+	ast.ErrorIs(err, goutils.ErrExists)
+	ast.ErrorIs(err, os.ErrExist)
+	ast.Equal(int64(0), n)
+	content, err := goutils.ReadText(dst)
+	ast.NoError(err)
+	ast.Equal("existing", content)
+
+	// copying a file onto itself is a no-op
+	n, err = goutils.CopyFileN(src, src)
+	ast.NoError(err)
+	ast.Equal(int64(0), n)
+	content, err = goutils.ReadText(src)
+	ast.NoError(err)
+	ast.Equal(3000, len(content))
+
+	_, err = goutils.CopyFileN(src, dst, goutils.WithBufferSize(0))
+	ast.Error(err)
+}
+
+func TestWriteTextIfChanged(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "gen.txt")
+
+	changed, err := goutils.WriteTextIfChanged(file, "v1")
+	ast.NoError(err)
+	ast.True(changed)
+
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	ast.NoError(os.Chtimes(file, old, old))
+
+	changed, err = goutils.WriteTextIfChanged(file, "v1")
+	ast.NoError(err)
+	ast.False(changed)
+	info, err := os.Stat(file)
+	ast.NoError(err)
+	ast.True(old.Equal(info.ModTime()))
+
+	changed, err = goutils.WriteTextIfChanged(file, "v2")
+	ast.NoError(err)
+	ast.True(changed)
+	info, err = os.Stat(file)
+	ast.NoError(err)
+	ast.True(info.ModTime().After(old))
+	content, err := goutils.ReadText(file)
+	ast.NoError(err)
+	ast.Equal("v2", content)
+
+	// same size, different content, larger than the comparison chunk
+	large := strings.Repeat("a", 200*1024)
+	changed, err = goutils.WriteTextIfChanged(file, large)
+	ast.NoError(err)
+	ast.True(changed)
+	changed, err = goutils.WriteTextIfChanged(file, large)
+	ast.NoError(err)
+	ast.False(changed)
+	changed, err = goutils.WriteTextIfChanged(file, large[:len(large)-1]+"b")
+	ast.NoError(err)
+	ast.True(changed)
+
+	data := map[string]interface{}{"key": "value"}
+	jsonFile := filepath.Join(dir, "gen.json")
+	changed, err = goutils.WriteJSONIfChanged(jsonFile, data)
+	ast.NoError(err)
+	ast.True(changed)
+	changed, err = goutils.WriteJSONIfChanged(jsonFile, data)
+	ast.NoError(err)
+	ast.False(changed)
+
+	// the output is the same as WriteJSON
+	ast.NoError(goutils.WriteJSON(jsonFile, data))
+	changed, err = goutils.WriteJSONIfChanged(jsonFile, data)
+	ast.NoError(err)
+	ast.False(changed)
+
+	yamlFile := filepath.Join(dir, "gen.yaml")
+	changed, err = goutils.WriteYAMLIfChanged(yamlFile, data)
+	ast.NoError(err)
+	ast.True(changed)
+	changed, err = goutils.WriteYAMLIfChanged(yamlFile, data)
+	ast.NoError(err)
+	ast.False(changed)
+}
+
+func TestExpandPath(t *testing.T) {
+	ast := assert.New(t)
+
+	home, err := os.UserHomeDir()
+	ast.NoError(err)
+
+	t.Setenv("GOUTILS_TEST_DIR", "tools")
+	os.Unsetenv("GOUTILS_TEST_UNSET")
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"", ""},
+		{"~", home},
+		{"~/data/cache", filepath.Join(home, "data", "cache")},
+		{"/opt/${GOUTILS_TEST_DIR}/bin", "/opt/tools/bin"},
+		{"/opt/$GOUTILS_TEST_DIR/bin", "/opt/tools/bin"},
+		{"~/${GOUTILS_TEST_DIR}", filepath.Join(home, "tools")},
+		{"/opt/${GOUTILS_TEST_UNSET}/bin", "/opt/bin"},
+		{"/usr//local/../bin/", "/usr/bin"},
+		{"relative/./path", "relative/path"},
+	}
+	for _, tt := range tests {
+		actual, err := goutils.ExpandPath(tt.path)
+		ast.NoError(err, tt.path)
+		ast.Equal(tt.expected, actual, tt.path)
+	}
+
+	_, err = goutils.ExpandPath("~goutils-no-such-user/data")
+	ast.Error(err)
+
+	abs, err := goutils.ExpandPathAbs("data/$GOUTILS_TEST_DIR", "/srv")
+	ast.NoError(err)
+	ast.Equal("/srv/data/tools", abs)
+
+	abs, err = goutils.ExpandPathAbs("/etc/app", "/srv")
+	ast.NoError(err)
+	ast.Equal("/etc/app", abs)
+
+	wd, err := os.Getwd()
+	ast.NoError(err)
+	abs, err = goutils.ExpandPathAbs("data", "")
+	ast.NoError(err)
+	ast.Equal(filepath.Join(wd, "data"), abs)
+
+	// the helpers taking user supplied paths expand them
+	t.Setenv("GOUTILS_TEST_DIR", t.TempDir())
+	ast.NoError(goutils.WriteText("${GOUTILS_TEST_DIR}/expanded.txt", "test"))
+	content, err := goutils.ReadText("$GOUTILS_TEST_DIR/expanded.txt")
+	ast.NoError(err)
+	ast.Equal("test", content)
+}
+
+func TestReadStrict(t *testing.T) {
+	ast := assert.New(t)
+
+	type Doc struct {
+		Title string `json:"title" yaml:"title" toml:"title"`
+		Count int    `json:"count" yaml:"count" toml:"count"`
+	}
+	expected := Doc{Title: "hello", Count: 1}
+
+	dir := t.TempDir()
+	jsonFile := filepath.Join(dir, "doc.json")
+	yamlFile := filepath.Join(dir, "doc.yaml")
+	tomlFile := filepath.Join(dir, "doc.toml")
+
+	// exact match
+	ast.NoError(goutils.WriteText(jsonFile, "{\n  \"title\": \"hello\",\n  \"count\": 1\n}\n"))
+	ast.NoError(goutils.WriteText(yamlFile, "title: hello\ncount: 1\n"))
+	ast.NoError(goutils.WriteText(tomlFile, "title = \"hello\"\ncount = 1\n"))
+
+	var doc Doc
+	ast.NoError(goutils.ReadJSONStrict(jsonFile, &doc))
+	ast.Equal(expected, doc)
+	doc = Doc{}
+	ast.NoError(goutils.ReadYAMLStrict(yamlFile, &doc))
+	ast.Equal(expected, doc)
+	doc = Doc{}
+	ast.NoError(goutils.ReadTOMLStrict(tomlFile, &doc))
+	ast.Equal(expected, doc)
+
+	// one extra field
+	ast.NoError(goutils.WriteText(jsonFile, "{\n  \"titel\": \"hello\",\n  \"count\": 1\n}\n"))
+	ast.NoError(goutils.WriteText(yamlFile, "count: 1\ntitel: hello\n"))
+	ast.NoError(goutils.WriteText(tomlFile, "count = 1\ntitel = \"hello\"\n"))
+
+	err := goutils.ReadJSONStrict(jsonFile, &doc)
+	ast.Error(err)
+	ast.Contains(err.Error(), "titel")
+	ast.Contains(err.Error(), "line 2")
+
+	err = goutils.ReadYAMLStrict(yamlFile, &doc)
+	ast.Error(err)
+	ast.Contains(err.Error(), "titel")
+	ast.Contains(err.Error(), "line 2")
+
+	err = goutils.ReadTOMLStrict(tomlFile, &doc)
+	ast.Error(err)
+	ast.Contains(err.Error(), "titel")
+	ast.Contains(err.Error(), "line 2")
+
+	// the lenient versions are unchanged
+	ast.NoError(goutils.ReadJSON(jsonFile, &doc))
+	ast.NoError(goutils.ReadYAML(yamlFile, &doc))
+	ast.NoError(goutils.ReadTOML(tomlFile, &doc))
+}
+
+func TestCopyFileContext(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "large.bin")
+	ast.NoError(goutils.WriteText(src, strings.Repeat("0123456789abcdef", 2*1024*1024)))
+
+	dst := filepath.Join(dir, "copy.bin")
+	ast.NoError(goutils.CopyFileContext(context.Background(), src, dst))
+	equal, err := goutils.FilesEqual(src, dst)
+	ast.NoError(err)
+	ast.True(equal)
+
+	// already cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dst = filepath.Join(dir, "cancelled.bin")
+	err = goutils.CopyFileContext(ctx, src, dst)
+	ast.ErrorIs(err, context.Canceled)
+	ast.False(goutils.PathExists(dst))
+
+	// cancelled mid-copy, with small chunks to make the copy slow
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	err = goutils.CopyFileContext(ctx, src, dst, goutils.WithBufferSize(512))
+	ast.ErrorIs(err, context.DeadlineExceeded)
+	ast.False(goutils.PathExists(dst))
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	err = goutils.CopyFileContext(ctx, src, dst, goutils.WithBufferSize(512), goutils.WithKeepPartial{})
+	ast.ErrorIs(err, context.DeadlineExceeded)
+	info, err := os.Stat(dst)
+	ast.NoError(err)
+	ast.Less(info.Size(), int64(32*1024*1024))
+}
+
+func TestMoveFile(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.txt")
+	dst := filepath.Join(dir, "sub", "dst.txt")
+	ast.NoError(goutils.WriteText(src, "test"))
+
+	ast.NoError(goutils.MoveFile(src, dst))
+	ast.False(goutils.PathExists(src))
+	content, err := goutils.ReadText(dst)
+	ast.NoError(err)
+	ast.Equal("test", content)
+}
+
+func TestBackupFile(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	ast.NoError(os.WriteFile(path, []byte("v0"), 0600))
+	ast.NoError(goutils.WriteText(filepath.Join(dir, "config.yaml.bak.unrelated"), ""))
+	ast.NoError(goutils.WriteText(filepath.Join(dir, "other.yaml.bak.20240101.000000.000"), ""))
+
+	var backups []string
+	for i := 0; i < 5; i++ {
+		ast.NoError(os.WriteFile(path, []byte(fmt.Sprintf("v%d", i)), 0600))
+		backup, err := goutils.BackupFile(path, 3)
+		ast.NoError(err)
+		backups = append(backups, backup)
+	}
+
+	for i, backup := range backups {
+		if i < 2 {
+			ast.NoFileExists(backup)
+			continue
+		}
+		content, err := goutils.ReadText(backup)
+		ast.NoError(err)
+		ast.Equal(fmt.Sprintf("v%d", i), content)
+		info, err := os.Stat(backup)
+		ast.NoError(err)
+		ast.Equal(os.FileMode(0600), info.Mode().Perm())
+	}
+
+	entries, err := os.ReadDir(dir)
+	ast.NoError(err)
+	ast.Len(entries, 6)
+
+	_, err = goutils.BackupFile(filepath.Join(dir, "missing.yaml"), 3)
+	ast.ErrorIs(err, os.ErrNotExist)
+	ast.NoFileExists(filepath.Join(dir, "missing.yaml.bak."+goutils.TimeStrMilliSec()))
+}
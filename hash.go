@@ -0,0 +1,104 @@
+package goutils
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// hashBufferSize is the size of the buffer used to stream data into a hash
+const hashBufferSize = 32 * 1024
+
+// newHash returns the hash for algo, one of "md5", "sha1", "sha256", "sha512"
+func newHash(algo string) (hash.Hash, error) {
+	switch strings.ToLower(algo) {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm: %s", algo)
+	}
+}
+
+// HashReader returns the hex digest of the data read from r.
+// algo is one of "md5", "sha1", "sha256", "sha512".
+func HashReader(r io.Reader, algo string) (string, error) {
+	h, err := newHash(algo)
+	if err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, hashBufferSize)
+	if _, err := io.CopyBuffer(h, r, buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// HashFile returns the hex digest of the file content
+func HashFile(path string, algo string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return HashReader(f, algo)
+}
+
+// FileSHA256 returns the hex SHA256 digest of the file content
+func FileSHA256(path string) (string, error) {
+	return HashFile(path, "sha256")
+}
+
+// FileMD5 returns the hex MD5 digest of the file content
+func FileMD5(path string) (string, error) {
+	return HashFile(path, "md5")
+}
+
+// VerifyChecksum checks the file content against the expected hex digest.
+// The algorithm is detected from the digest length: md5, sha1, sha256 or sha512.
+func VerifyChecksum(path, expected string) error {
+	expected = strings.ToLower(strings.TrimSpace(expected))
+	algo, err := checksumAlgo(expected)
+	if err != nil {
+		return err
+	}
+
+	actual, err := HashFile(path, algo)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return fmt.Errorf("%s checksum mismatch for %s: expected %s, actual %s", algo, path, expected, actual)
+	}
+	return nil
+}
+
+// checksumAlgo detects the algorithm of a hex digest from its length: md5, sha1, sha256 or sha512
+func checksumAlgo(digest string) (string, error) {
+	switch len(digest) {
+	case 32:
+		return "md5", nil
+	case 40:
+		return "sha1", nil
+	case 64:
+		return "sha256", nil
+	case 128:
+		return "sha512", nil
+	default:
+		return "", fmt.Errorf("unknown checksum length %d: %s", len(digest), digest)
+	}
+}
@@ -0,0 +1,47 @@
+package goutils_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestHash(t *testing.T) {
+	ast := assert.New(t)
+
+	const sha256Hello = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	const md5Hello = "5d41402abc4b2a76b9719d911017c592"
+
+	file := filepath.Join(t.TempDir(), "hello.txt")
+	ast.NoError(goutils.WriteText(file, "hello"))
+
+	sum, err := goutils.FileSHA256(file)
+	ast.NoError(err)
+	ast.Equal(sha256Hello, sum)
+
+	sum, err = goutils.FileMD5(file)
+	ast.NoError(err)
+	ast.Equal(md5Hello, sum)
+
+	sum, err = goutils.HashReader(strings.NewReader("hello"), "sha256")
+	ast.NoError(err)
+	ast.Equal(sha256Hello, sum)
+
+	_, err = goutils.HashReader(strings.NewReader("hello"), "crc32")
+	ast.Error(err)
+
+	ast.NoError(goutils.VerifyChecksum(file, sha256Hello))
+	ast.NoError(goutils.VerifyChecksum(file, strings.ToUpper(md5Hello)))
+
+	wrong := strings.Repeat("0", 64)
+	err = goutils.VerifyChecksum(file, wrong)
+	ast.Error(err)
+	ast.Contains(err.Error(), wrong)
+	ast.Contains(err.Error(), sha256Hello)
+
+	ast.Error(goutils.VerifyChecksum(file, "abc"))
+}
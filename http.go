@@ -1,38 +1,1324 @@
 package goutils
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
 	"io"
+	"math"
+	"math/rand/v2"
+	"mime"
 	"net/http"
+	neturl "net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/rs/zerolog/log"
+	"github.com/rs/zerolog"
 )
 
-func Download(url string, filePath string) error {
-	err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm)
+// DownloadOption is an option for Download and DownloadContext
+type DownloadOption interface {
+	applyToDownload(*downloadOptions) error
+}
+
+type downloadOptions struct {
+	Resume       bool
+	Checksum     *WithChecksum
+	ChecksumURL  string
+	Retries      int
+	Backoff      WithRetryBackoff
+	AllowNon2xx  bool
+	Header       http.Header
+	Jar          http.CookieJar
+	Parts        int
+	Proxy        func(*http.Request) (*neturl.URL, error)
+	TLSConfig    *tls.Config
+	Insecure     bool
+	Client       *http.Client
+	RoundTripper http.RoundTripper
+	MaxRedirects int
+	Timeout      time.Duration
+	FailFast     bool
+	Progress     WithDownloadProgress
+	ProgressLog  time.Duration
+	Shuffle      bool
+	FileMode     os.FileMode
+	NoOverwrite  bool
+
+	// Transport is built by setup from Proxy and TLSConfig
+	Transport *http.Transport
+}
+
+// DefaultUserAgent is the User-Agent header of Download and Upload, see WithUserAgent
+const DefaultUserAgent = "goutils (+https://github.com/117503445/goutils)"
+
+// DefaultHTTPTimeout is the timeout of each request of Download and Upload, body included, see WithHTTPTimeout
+const DefaultHTTPTimeout = time.Hour
+
+// defaultTransport is the transport of Download and Upload, pooling the connections of all the calls
+var defaultTransport = func() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConnsPerHost = 16
+	t.ResponseHeaderTimeout = time.Minute
+	return t
+}()
+
+func newDownloadOptions() *downloadOptions {
+	return &downloadOptions{
+		Backoff:      WithRetryBackoff{Base: 500 * time.Millisecond, Max: 10 * time.Second, Jitter: 0.2},
+		MaxRedirects: 10,
+		Timeout:      DefaultHTTPTimeout,
+	}
+}
+
+// WithResume is a download option to download into <path>.partial, renamed to path once complete,
+// and to resume from an existing partial file with an HTTP Range request.
+// Servers ignoring the range restart the download from scratch.
+type WithResume struct {
+}
+
+func (w WithResume) applyToDownload(o *downloadOptions) error {
+	o.Resume = true
+	return nil
+}
+
+// WithChecksum is a download option to verify the hex digest of the file with Algo, one of "md5", "sha1",
+// "sha256" and "sha512". The file is downloaded into <path>.partial, renamed to path once verified,
+// and removed on mismatch with an ErrChecksumMismatch.
+type WithChecksum struct {
+	Algo     string
+	Expected string
+}
+
+func (w WithChecksum) applyToDownload(o *downloadOptions) error {
+	if _, err := newHash(w.Algo); err != nil {
+		return err
+	}
+	o.Checksum = &w
+	return nil
+}
+
+// WithChecksumURL is a download option like WithChecksum, with the digest of the file in a checksum file
+// at this URL, in the format of sha256sum and the like: "<hex digest>  <file name>" per line.
+// The entry of the file name of the URL is used, or else of the file name of the path.
+type WithChecksumURL string
+
+func (w WithChecksumURL) applyToDownload(o *downloadOptions) error {
+	o.ChecksumURL = string(w)
+	return nil
+}
+
+// WithAllowNon2xx is a download option to write the body of a non-2xx response to the file with a warning,
+// instead of failing. It applies to the downloads without WithResume and WithChecksum.
+type WithAllowNon2xx struct {
+}
+
+func (w WithAllowNon2xx) applyToDownload(o *downloadOptions) error {
+	o.AllowNon2xx = true
+	return nil
+}
+
+// WithHeader is a download and upload option to set a header of the requests
+type WithHeader struct {
+	Key   string
+	Value string
+}
+
+func (w WithHeader) applyToDownload(o *downloadOptions) error {
+	if w.Key == "" {
+		return errors.New("empty header key")
+	}
+	o.setHeader(w.Key, w.Value)
+	return nil
+}
+
+// WithBasicAuth is a download and upload option to authenticate the requests with HTTP basic authentication
+type WithBasicAuth struct {
+	User     string
+	Password string
+}
+
+func (w WithBasicAuth) applyToDownload(o *downloadOptions) error {
+	req := &http.Request{Header: http.Header{}}
+	req.SetBasicAuth(w.User, w.Password)
+	o.setHeader("Authorization", req.Header.Get("Authorization"))
+	return nil
+}
+
+// WithBearerToken is a download and upload option to authenticate the requests with this bearer token
+type WithBearerToken string
+
+func (w WithBearerToken) applyToDownload(o *downloadOptions) error {
+	o.setHeader("Authorization", "Bearer "+string(w))
+	return nil
+}
+
+// WithCookieJar is a download and upload option to send and store the cookies of the requests with Jar
+type WithCookieJar struct {
+	Jar http.CookieJar
+}
+
+func (w WithCookieJar) applyToDownload(o *downloadOptions) error {
+	o.Jar = w.Jar
+	return nil
+}
+
+// WithUserAgent is a download and upload option to replace DefaultUserAgent
+type WithUserAgent string
+
+func (w WithUserAgent) applyToDownload(o *downloadOptions) error {
+	o.setHeader("User-Agent", string(w))
+	return nil
+}
+
+func (o *downloadOptions) setHeader(key, value string) {
+	if o.Header == nil {
+		o.Header = http.Header{}
+	}
+	o.Header.Set(key, value)
+}
+
+// WithParts is a download option to fetch the file in this many byte ranges at once, into <path>.partial,
+// when the server supports ranges, as a single stream otherwise. The checksum of WithChecksum is verified on
+// the assembled file, and a failed range is retried alone from where it stopped, as set by WithRetries.
+type WithParts int
+
+func (w WithParts) applyToDownload(o *downloadOptions) error {
+	if w < 1 {
+		return fmt.Errorf("invalid parts: %d", w)
+	}
+	o.Parts = int(w)
+	return nil
+}
+
+// WithProxyFromEnv is a download and upload option to send the requests through the proxy set by the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables, as by http.ProxyFromEnvironment.
+// It is the default, and overrides an earlier WithProxyURL.
+type WithProxyFromEnv struct {
+}
+
+func (w WithProxyFromEnv) applyToDownload(o *downloadOptions) error {
+	o.Proxy = nil
+	return nil
+}
+
+// WithProxyURL is a download and upload option to send the requests through the proxy at this URL,
+// e.g. "http://proxy.example.com:3128"
+type WithProxyURL string
+
+func (w WithProxyURL) applyToDownload(o *downloadOptions) error {
+	u, err := neturl.Parse(string(w))
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL %q: %w", string(w), err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid proxy URL %q", string(w))
+	}
+	o.Proxy = http.ProxyURL(u)
+	return nil
+}
+
+// WithTLSConfig is a download and upload option to set the TLS configuration of the connections.
+// WithCAFile and WithInsecureSkipVerify after it amend it.
+type WithTLSConfig struct {
+	Config *tls.Config
+}
+
+func (w WithTLSConfig) applyToDownload(o *downloadOptions) error {
+	if w.Config == nil {
+		return errors.New("nil TLS config")
+	}
+	o.TLSConfig = w.Config.Clone()
+	return nil
+}
+
+// WithCAFile is a download and upload option to trust the certificates of this PEM file,
+// in addition to the ones of the system
+type WithCAFile string
+
+func (w WithCAFile) applyToDownload(o *downloadOptions) error {
+	path, err := ExpandPath(string(w))
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	config := o.tlsConfig()
+	pool := config.RootCAs
+	if pool == nil {
+		if pool, err = x509.SystemCertPool(); err != nil {
+			pool = x509.NewCertPool()
+		}
+	} else {
+		pool = pool.Clone()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no certificate in %s", path)
+	}
+	config.RootCAs = pool
+	return nil
+}
+
+// WithInsecureSkipVerify is a download and upload option to skip the verification of the TLS certificates,
+// which makes the connections open to interception. A warning is logged.
+type WithInsecureSkipVerify struct {
+}
+
+func (w WithInsecureSkipVerify) applyToDownload(o *downloadOptions) error {
+	o.tlsConfig().InsecureSkipVerify = true
+	o.Insecure = true
+	return nil
+}
+
+func (o *downloadOptions) tlsConfig() *tls.Config {
+	if o.TLSConfig == nil {
+		o.TLSConfig = &tls.Config{}
+	}
+	return o.TLSConfig
+}
+
+// WithMaxRedirects is a download and upload option to follow at most this many redirects, 10 by default
+type WithMaxRedirects int
+
+func (w WithMaxRedirects) applyToDownload(o *downloadOptions) error {
+	if w < 0 {
+		return fmt.Errorf("invalid max redirects: %d", w)
+	}
+	o.MaxRedirects = int(w)
+	return nil
+}
+
+// WithHTTPTimeout is a download and upload option to replace DefaultHTTPTimeout, 0 for no timeout
+type WithHTTPTimeout time.Duration
+
+func (w WithHTTPTimeout) applyToDownload(o *downloadOptions) error {
+	if w < 0 {
+		return fmt.Errorf("invalid HTTP timeout: %v", time.Duration(w))
+	}
+	o.Timeout = time.Duration(w)
+	return nil
+}
+
+// WithHTTPClient is a download and upload option to send the requests with Client. The proxy and TLS options
+// apply to a copy of its *http.Transport. WithCookieJar replaces its Jar, and without CheckRedirect,
+// the redirects are limited by WithMaxRedirects and drop the Authorization header to another host.
+type WithHTTPClient struct {
+	Client *http.Client
+}
+
+func (w WithHTTPClient) applyToDownload(o *downloadOptions) error {
+	if w.Client == nil {
+		return errors.New("nil HTTP client")
+	}
+	o.Client = w.Client
+	return nil
+}
+
+// WithTransport is a download, upload and wait option to send the requests with Transport, like an
+// instrumented http.RoundTripper, keeping the timeout and redirect limit of the other options. It replaces the
+// transport of WithHTTPClient. The proxy and TLS options apply to a copy of it, when it is an *http.Transport.
+type WithTransport struct {
+	Transport http.RoundTripper
+}
+
+func (w WithTransport) applyToDownload(o *downloadOptions) error {
+	if w.Transport == nil {
+		return errors.New("nil HTTP transport")
+	}
+	o.RoundTripper = w.Transport
+	return nil
+}
+
+// WithDownloadProgress is a download option to receive the number of bytes of the file written so far,
+// out of total, or -1 if the size is unknown. A retry of a download which can't be resumed starts over from 0.
+type WithDownloadProgress func(written, total int64)
+
+func (w WithDownloadProgress) applyToDownload(o *downloadOptions) error {
+	o.Progress = w
+	return nil
+}
+
+// WithProgressLog is a download option to log the progress at most once per this interval, with the
+// percentage, the speed and the remaining time when the size is known, and to log a summary once complete
+type WithProgressLog time.Duration
+
+func (w WithProgressLog) applyToDownload(o *downloadOptions) error {
+	if w <= 0 {
+		return fmt.Errorf("invalid progress log interval: %v", time.Duration(w))
+	}
+	o.ProgressLog = time.Duration(w)
+	return nil
+}
+
+// WithFailFast is a DownloadMany option to cancel the other downloads at the first failed one
+type WithFailFast struct {
+}
+
+func (w WithFailFast) applyToDownload(o *downloadOptions) error {
+	o.FailFast = true
+	return nil
+}
+
+// WithShuffleMirrors is a DownloadFromMirrors option to try the mirrors in a random order,
+// spreading the load of many clients
+type WithShuffleMirrors struct {
+}
+
+func (w WithShuffleMirrors) applyToDownload(o *downloadOptions) error {
+	o.Shuffle = true
+	return nil
+}
+
+// WithFileMode is a download option to set the mode of the downloaded file, e.g. 0755 for an executable
+type WithFileMode os.FileMode
+
+func (w WithFileMode) applyToDownload(o *downloadOptions) error {
+	if w == 0 {
+		return errors.New("invalid file mode: 0")
+	}
+	o.FileMode = os.FileMode(w)
+	return nil
+}
+
+func (w WithNoOverwrite) applyToDownload(o *downloadOptions) error {
+	o.NoOverwrite = true
+	return nil
+}
+
+// WithRetries is a download and upload option to retry a failed transfer up to this many times, on network
+// errors, 5xx responses and 429 responses, honoring their Retry-After header. Other 4xx responses are not retried.
+// With WithResume, the retries continue the partial file.
+type WithRetries int
+
+func (w WithRetries) applyToDownload(o *downloadOptions) error {
+	if w < 0 {
+		return fmt.Errorf("invalid retries: %d", w)
+	}
+	o.Retries = int(w)
+	return nil
+}
+
+// WithRetryBackoff is a download and upload option to wait Base before the first retry, doubled for each next
+// one up to Max, randomized by up to the Jitter fraction, 500ms up to 10s with a 0.2 jitter by default.
+// A Retry-After header of a 429 or 503 response sets the wait instead.
+type WithRetryBackoff struct {
+	Base   time.Duration
+	Max    time.Duration
+	Jitter float64
+}
+
+func (w WithRetryBackoff) applyToDownload(o *downloadOptions) error {
+	if w.Base < 0 || w.Max < 0 || w.Jitter < 0 || w.Jitter > 1 {
+		return fmt.Errorf("invalid retry backoff: base %v, max %v, jitter %v", w.Base, w.Max, w.Jitter)
+	}
+	o.Backoff = w
+	return nil
+}
+
+// statusErrorBodySize is the size of the start of the body kept in an httpStatusError
+const statusErrorBodySize = 512
+
+// httpStatusError is the error of an unexpected HTTP response status, with the start of its body
+type httpStatusError struct {
+	op         string
+	url        string
+	status     string
+	code       int
+	body       string
+	retryAfter time.Duration
+}
+
+func newHTTPStatusError(op string, url string, resp *http.Response) *httpStatusError {
+	e := &httpStatusError{op: op, url: RedactURL(url), status: resp.Status, code: resp.StatusCode}
+	if body, err := io.ReadAll(io.LimitReader(resp.Body, statusErrorBodySize)); err == nil {
+		e.body = strings.TrimSpace(string(body))
+	}
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil {
+			e.retryAfter = time.Duration(seconds) * time.Second
+		} else if t, err := http.ParseTime(v); err == nil {
+			e.retryAfter = time.Until(t)
+		}
+	}
+	return e
+}
+
+func (e *httpStatusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("%s %s: %s", e.op, e.url, e.status)
+	}
+	return fmt.Sprintf("%s %s: %s: %q", e.op, e.url, e.status, e.body)
+}
+
+func (e *httpStatusError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// Temporary returns true for the statuses retried by WithRetries: 429 Too Many Requests and the 5xx.
+// Check it on the errors of Download and Upload with errors.As and an interface{ Temporary() bool }.
+func (e *httpStatusError) Temporary() bool {
+	return retryableStatus(e.code)
+}
+
+// retryableStatus returns true for the statuses of WithRetries
+func retryableStatus(code int) bool {
+	return code >= 500 || code == http.StatusTooManyRequests
+}
+
+// retryableDownloadError returns true for the errors of WithRetries: network errors and retryable statuses
+func retryableDownloadError(err error) bool {
+	var statusErr *httpStatusError
+	var mismatch ErrChecksumMismatch
+	var pathErr *os.PathError
+	switch {
+	case errors.As(err, &statusErr):
+		return statusErr.Temporary()
+	case errors.As(err, &mismatch), errors.As(err, &pathErr):
+		return false
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	default:
+		return true
+	}
+}
+
+// ErrChecksumMismatch is the error of a download whose digest is not the expected one
+type ErrChecksumMismatch struct {
+	Expected string
+	Actual   string
+}
+
+func (e ErrChecksumMismatch) Error() string {
+	return fmt.Sprintf("checksum mismatch: expected %s, actual %s", e.Expected, e.Actual)
+}
+
+// Download downloads the url to filePath. The path is expanded by ExpandPath.
+// A non-2xx response is an error with its status and the start of its body, and leaves filePath untouched,
+// see WithAllowNon2xx. The requests go through the proxy of the environment by default, see WithProxyURL.
+func Download(url string, filePath string, opts ...DownloadOption) error {
+	return DownloadContext(context.Background(), url, filePath, opts...)
+}
+
+// DownloadContext is like Download, but the request is cancelled when ctx is done,
+// and warnings are logged with the logger of ctx, see LoggerIntoContext.
+func DownloadContext(ctx context.Context, url string, filePath string, opts ...DownloadOption) error {
+	opt := newDownloadOptions()
+	for _, o := range opts {
+		if err := o.applyToDownload(opt); err != nil {
+			return err
+		}
+	}
+	if err := opt.setup(ctx); err != nil {
+		return err
+	}
+	if opt.Transport != nil {
+		defer opt.Transport.CloseIdleConnections()
+	}
+
+	filePath, err := ExpandPath(filePath)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(filePath), os.ModePerm)
 	if err != nil {
 		return err
 	}
+	if opt.NoOverwrite {
+		if _, err := os.Lstat(filePath); err == nil {
+			return fmt.Errorf("download %s to %s: %w", RedactURL(url), filePath, ErrExists)
+		}
+	}
 
-	client := &http.Client{}
+	if opt.ChecksumURL != "" {
+		checksum, err := fetchChecksum(ctx, opt, opt.ChecksumURL, url, filePath)
+		if err != nil {
+			return err
+		}
+		opt.Checksum = checksum
+	}
+
+	download := func() error {
+		if opt.Resume || opt.Checksum != nil {
+			return downloadPartial(ctx, url, filePath, opt)
+		}
+		return downloadPlain(ctx, url, filePath, opt)
+	}
+	single := func() error {
+		return opt.retry(ctx, "Download failed, retrying", url, download)
+	}
+
+	var progressLog *downloadProgressLog
+	if opt.ProgressLog > 0 {
+		progressLog = newDownloadProgressLog(LoggerFromContext(ctx), url, opt.ProgressLog)
+		progress := opt.Progress
+		opt.Progress = func(written, total int64) {
+			if progress != nil {
+				progress(written, total)
+			}
+			progressLog.update(written, total)
+		}
+	}
 
-	resp, err := client.Get(url)
+	if opt.Parts > 1 {
+		// the ranges are retried one by one
+		err = downloadParts(ctx, url, filePath, opt, single)
+	} else {
+		err = single()
+	}
+	if err != nil {
+		return err
+	}
+	if progressLog != nil {
+		progressLog.done()
+	}
+	return nil
+}
+
+// retry calls fn as set by WithRetries and WithRetryBackoff, logging msg before each retry
+func (o *downloadOptions) retry(ctx context.Context, msg string, url string, fn func() error) error {
+	if o.Retries == 0 {
+		return fn()
+	}
+
+	attempt := 0
+	return Retry(ctx, func() error {
+		attempt++
+		err := fn()
+		if err != nil && attempt <= o.Retries && retryableDownloadError(err) {
+			LoggerFromContext(ctx).Debug().Err(err).Str("url", RedactURL(url)).Int("attempt", attempt).Msg(msg)
+		}
+		return err
+	},
+		WithAttempts(o.Retries+1),
+		WithBackoff{Initial: o.Backoff.Base, Max: o.Backoff.Max, Factor: 2},
+		WithJitter(o.Backoff.Jitter),
+		WithRetryIf(retryableDownloadError),
+	)
+}
+
+// DownloadToDir downloads url into dir, with the file name suggested by the Content-Disposition header of the
+// response to a HEAD request, or else the last element of the path of the URL, after the redirects.
+// It returns the path of the file. A file name with a path, like "../../etc/passwd", is an error.
+func DownloadToDir(ctx context.Context, url, dir string, opts ...DownloadOption) (string, error) {
+	opt := newDownloadOptions()
+	for _, o := range opts {
+		if err := o.applyToDownload(opt); err != nil {
+			return "", err
+		}
+	}
+	if err := opt.setup(ctx); err != nil {
+		return "", err
+	}
+	if opt.Transport != nil {
+		defer opt.Transport.CloseIdleConnections()
+	}
+	dir, err := ExpandPath(dir)
+	if err != nil {
+		return "", err
+	}
+
+	name := ""
+	finalURL := url
+	if resp, err := opt.do(ctx, http.MethodHead, url, ""); err == nil {
+		resp.Body.Close()
+		finalURL = resp.Request.URL.String()
+		if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+			if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+				name = params["filename"]
+			}
+		}
+	}
+	if name == "" {
+		u, err := neturl.Parse(finalURL)
+		if err != nil {
+			return "", err
+		}
+		name = path.Base(u.Path)
+	}
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") {
+		return "", fmt.Errorf("download %s: invalid file name %q", RedactURL(url), name)
+	}
+
+	filePath := filepath.Join(dir, name)
+	if err := DownloadContext(ctx, url, filePath, opts...); err != nil {
+		return "", err
+	}
+	return filePath, nil
+}
+
+// DownloadItem is a file of DownloadMany
+type DownloadItem struct {
+	URL  string
+	Dest string
+	// Checksum is verified as by the WithChecksum option, if set
+	Checksum *WithChecksum
+}
+
+// DownloadResult is the result of a DownloadItem
+type DownloadResult struct {
+	Item     DownloadItem
+	Err      error
+	Duration time.Duration
+	// Bytes is the size of the downloaded file
+	Bytes int64
+}
+
+// DownloadMany downloads items with at most concurrency downloads at once, or all at once if concurrency <= 0,
+// each with opts, and returns their results in the order of items.
+//
+// A failed download doesn't stop the others, unless WithFailFast is set. The items not downloaded then, or
+// when ctx is done, have the error of the cancellation.
+func DownloadMany(ctx context.Context, items []DownloadItem, concurrency int, opts ...DownloadOption) []DownloadResult {
+	results := make([]DownloadResult, len(items))
+	for i, item := range items {
+		results[i].Item = item
+	}
+
+	opt := newDownloadOptions()
+	for _, o := range opts {
+		if err := o.applyToDownload(opt); err != nil {
+			for i := range results {
+				results[i].Err = err
+			}
+			return results
+		}
+	}
+	var parallelOpts []ParallelOption
+	if !opt.FailFast {
+		parallelOpts = append(parallelOpts, WithCollectAll{})
+	}
+
+	indexes := make([]int, len(items))
+	started := make([]bool, len(items))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	ParallelMap(ctx, indexes, concurrency, func(ctx context.Context, i int) (struct{}, error) {
+		started[i] = true
+		item := items[i]
+		itemOpts := opts
+		if item.Checksum != nil {
+			itemOpts = append(slices.Clone(opts), *item.Checksum)
+		}
+
+		start := GetClock().Now()
+		err := DownloadContext(ctx, item.URL, item.Dest, itemOpts...)
+		results[i].Duration = GetClock().Since(start)
+		results[i].Err = err
+		if err == nil {
+			if path, err := ExpandPath(item.Dest); err == nil {
+				if info, err := os.Stat(path); err == nil {
+					results[i].Bytes = info.Size()
+				}
+			}
+		}
+		return struct{}{}, err
+	}, parallelOpts...)
+
+	for i := range results {
+		if !started[i] {
+			results[i].Err = context.Canceled
+			if err := ctx.Err(); err != nil {
+				results[i].Err = err
+			}
+		}
+	}
+	return results
+}
+
+// DownloadFromMirrors downloads the same file from the first of urls which succeeds, in order unless
+// WithShuffleMirrors is set, and returns it. Each URL is retried as set by WithRetries before trying the next one,
+// and a checksum mismatch moves on to the next one too. The errors of all the URLs are joined.
+func DownloadFromMirrors(ctx context.Context, urls []string, filePath string, opts ...DownloadOption) (string, error) {
+	if len(urls) == 0 {
+		return "", errors.New("no mirror URL")
+	}
+	opt := newDownloadOptions()
+	for _, o := range opts {
+		if err := o.applyToDownload(opt); err != nil {
+			return "", err
+		}
+	}
+	urls = slices.Clone(urls)
+	if opt.Shuffle {
+		rand.Shuffle(len(urls), func(i, j int) {
+			urls[i], urls[j] = urls[j], urls[i]
+		})
+	}
+
+	var errs []error
+	for _, url := range urls {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		err := DownloadContext(ctx, url, filePath, opts...)
+		if err == nil {
+			return url, nil
+		}
+		LoggerFromContext(ctx).Warn().Err(err).Str("url", RedactURL(url)).Msg("Mirror failed")
+		errs = append(errs, fmt.Errorf("mirror %s: %w", RedactURL(url), err))
+	}
+	return "", errors.Join(errs...)
+}
+
+// downloadPlain downloads url to a temporary file next to filePath, renamed to filePath once complete,
+// so that a failed download doesn't replace a file already there
+func downloadPlain(ctx context.Context, url string, filePath string, opt *downloadOptions) error {
+	resp, err := opt.do(ctx, http.MethodGet, url, "")
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		log.Warn().Str("status", resp.Status).Msg("non-200 status code received")
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if !opt.AllowNon2xx {
+			return newHTTPStatusError("download", url, resp)
+		}
+		LoggerFromContext(ctx).Warn().Str("status", resp.Status).Msg("non-2xx status code received")
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, newProgressCounter(opt.Progress, 0, resp.ContentLength).reader(resp.Body)); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := closeDownload(out); err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	return verifyDownload(out.Name(), filePath, opt, nil)
+}
+
+// downloadPartial downloads url to filePath through filePath.partial, resumed if opt.Resume is set,
+// and verified against opt.Checksum if set
+func downloadPartial(ctx context.Context, url string, filePath string, opt *downloadOptions) error {
+	partial := filePath + ".partial"
+	var offset int64
+	if opt.Resume {
+		if info, err := os.Stat(partial); err == nil {
+			offset = info.Size()
+		}
+	}
+
+	// hashed returns the hash of the first n bytes of the partial file, or nil without checksum
+	hashed := func(n int64) (hash.Hash, error) {
+		if opt.Checksum == nil {
+			return nil, nil
+		}
+		h, err := newHash(opt.Checksum.Algo)
+		if err != nil || n == 0 {
+			return h, err
+		}
+		f, err := os.Open(partial)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if _, err := io.CopyN(h, f, n); err != nil {
+			return nil, err
+		}
+		return h, nil
+	}
+
+	byteRange := ""
+	if offset > 0 {
+		byteRange = fmt.Sprintf("bytes=%d-", offset)
+	}
+	resp, err := opt.do(ctx, http.MethodGet, url, byteRange)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flag := os.O_CREATE | os.O_WRONLY
+	var total int64
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, size, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return err
+		}
+		if start != offset {
+			return fmt.Errorf("download %s: range starts at %d instead of %d", RedactURL(url), start, offset)
+		}
+		flag |= os.O_APPEND
+		total = size
+	case http.StatusOK:
+		// the server ignored the range
+		if offset > 0 {
+			LoggerFromContext(ctx).Debug().Str("url", RedactURL(url)).Int64("offset", offset).Msg("Range ignored, restarting the download")
+			offset = 0
+		}
+		flag |= os.O_TRUNC
+		total = resp.ContentLength
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the partial file may be complete already
+		_, size, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err == nil && size == offset {
+			h, err := hashed(offset)
+			if err != nil {
+				return err
+			}
+			return verifyDownload(partial, filePath, opt, h)
+		}
+		os.Remove(partial)
+		return fmt.Errorf("download %s: %s, partial file removed", RedactURL(url), resp.Status)
+	default:
+		return newHTTPStatusError("download", url, resp)
 	}
 
-	out, err := os.Create(filePath)
+	h, err := hashed(offset)
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(partial, flag, 0644)
 	if err != nil {
 		return err
 	}
 	defer out.Close()
+	body := newProgressCounter(opt.Progress, offset, total).reader(resp.Body)
+	if h != nil {
+		body = io.TeeReader(body, h)
+	}
+	if _, err := io.Copy(out, body); err != nil {
+		// keep the partial file to resume from
+		return err
+	}
+	info, err := out.Stat()
+	if err != nil {
+		return err
+	}
+	if err := closeDownload(out); err != nil {
+		return err
+	}
+
+	if total >= 0 && info.Size() != total {
+		os.Remove(partial)
+		return fmt.Errorf("download %s: got %d bytes instead of %d, partial file removed", RedactURL(url), info.Size(), total)
+	}
+	return verifyDownload(partial, filePath, opt, h)
+}
+
+// downloadParts downloads url to filePath through filePath.partial, in opt.Parts ranges fetched at once.
+// It falls back to single when the server doesn't support ranges, or to resume a partial file.
+func downloadParts(ctx context.Context, url string, filePath string, opt *downloadOptions, single func() error) error {
+	partial := filePath + ".partial"
+	if opt.Resume {
+		if _, err := os.Stat(partial); err == nil {
+			// the ranges already fetched are unknown, the partial file is resumed as a single stream
+			return single()
+		}
+	}
+
+	resp, err := opt.do(ctx, http.MethodHead, url, "")
+	if err != nil {
+		return single()
+	}
+	resp.Body.Close()
+	size := resp.ContentLength
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Accept-Ranges") != "bytes" || size <= 0 {
+		LoggerFromContext(ctx).Debug().Str("url", RedactURL(url)).Str("status", resp.Status).Msg("Ranges not supported, downloading as a single stream")
+		return single()
+	}
+
+	partSize := (size + int64(opt.Parts) - 1) / int64(opt.Parts)
+	var ranges [][2]int64
+	for start := int64(0); start < size; start += partSize {
+		ranges = append(ranges, [2]int64{start, min(start+partSize, size) - 1})
+	}
+
+	out, err := os.Create(partial)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := out.Truncate(size); err != nil {
+		return err
+	}
+	progress := newProgressCounter(opt.Progress, 0, size)
+	_, err = ParallelMap(ctx, ranges, len(ranges), func(ctx context.Context, r [2]int64) (struct{}, error) {
+		return struct{}{}, downloadRange(ctx, url, out, r[0], r[1], opt, progress)
+	})
+	if err != nil {
+		out.Close()
+		os.Remove(partial)
+		return err
+	}
+	if err := closeDownload(out); err != nil {
+		return err
+	}
 
-	_, err = io.Copy(out, resp.Body)
-	return err
+	var h hash.Hash
+	if opt.Checksum != nil {
+		if h, err = newHash(opt.Checksum.Algo); err != nil {
+			return err
+		}
+		f, err := os.Open(partial)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+	}
+	return verifyDownload(partial, filePath, opt, h)
+}
+
+// downloadRange downloads the bytes from start to end, inclusive, of url into out at the same offset.
+// A failed attempt is retried from the last byte received, as set by WithRetries.
+func downloadRange(ctx context.Context, url string, out *os.File, start, end int64, opt *downloadOptions, progress *progressCounter) error {
+	offset := start
+	return opt.retry(ctx, "Download failed, retrying", url, func() error {
+		resp, err := opt.do(ctx, http.MethodGet, url, fmt.Sprintf("bytes=%d-%d", offset, end))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusPartialContent {
+			return newHTTPStatusError("download", url, resp)
+		}
+		rangeStart, _, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return err
+		}
+		if rangeStart != offset {
+			return fmt.Errorf("download %s: range starts at %d instead of %d", RedactURL(url), rangeStart, offset)
+		}
+
+		n, err := io.Copy(io.NewOffsetWriter(out, offset), progress.reader(io.LimitReader(resp.Body, end+1-offset)))
+		offset += n
+		if err != nil {
+			return err
+		}
+		if offset <= end {
+			return fmt.Errorf("download %s: range %d-%d: %w", RedactURL(url), start, end, io.ErrUnexpectedEOF)
+		}
+		return nil
+	})
+}
+
+// progressCounter counts the bytes of a transfer for a progress callback, safe for concurrent use
+type progressCounter struct {
+	mu    sync.Mutex
+	n     int64
+	total int64
+	fn    func(n, total int64)
+}
+
+// newProgressCounter returns a progressCounter from start out of total for fn, nil if fn is nil
+func newProgressCounter(fn func(n, total int64), start, total int64) *progressCounter {
+	if fn == nil {
+		return nil
+	}
+	return &progressCounter{n: start, total: total, fn: fn}
+}
+
+func (c *progressCounter) add(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n += int64(n)
+	c.fn(c.n, c.total)
+}
+
+// reader returns r counting the bytes read, or r itself if c is nil
+func (c *progressCounter) reader(r io.Reader) io.Reader {
+	if c == nil {
+		return r
+	}
+	return &progressReader{r: r, counter: c}
+}
+
+type progressReader struct {
+	r       io.Reader
+	counter *progressCounter
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.counter.add(n)
+	}
+	return n, err
+}
+
+// downloadProgressLog logs the progress of a download, see WithProgressLog
+type downloadProgressLog struct {
+	logger   *zerolog.Logger
+	url      string
+	interval time.Duration
+
+	start   time.Time
+	last    time.Time
+	lastN   int64
+	written int64
+}
+
+func newDownloadProgressLog(logger *zerolog.Logger, url string, interval time.Duration) *downloadProgressLog {
+	now := GetClock().Now()
+	return &downloadProgressLog{logger: logger, url: RedactURL(url), interval: interval, start: now, last: now}
+}
+
+// update logs the progress if the interval has passed since the last log
+func (p *downloadProgressLog) update(written, total int64) {
+	p.written = written
+	now := GetClock().Now()
+	if now.Sub(p.last) < p.interval {
+		return
+	}
+	if written < p.lastN {
+		// the download restarted
+		p.lastN = 0
+	}
+
+	avgSpeed := bytesPerSecond(written, now.Sub(p.start))
+	e := p.logger.Info().Str("url", p.url).Str("transferred", BytesToStr(written))
+	if total > 0 {
+		e = e.Str("total", BytesToStr(total)).Float64("percent", math.Round(float64(written)*1000/float64(total))/10)
+		if avgSpeed > 0 {
+			e = e.Str("eta", DurationToStr(time.Duration(float64(total-written)/avgSpeed*float64(time.Second))))
+		}
+	}
+	e.Str("speed", speedToStr(bytesPerSecond(written-p.lastN, now.Sub(p.last)))).
+		Str("avg_speed", speedToStr(avgSpeed)).
+		Msg("Download progress")
+	p.last, p.lastN = now, written
+}
+
+// done logs the summary of the download
+func (p *downloadProgressLog) done() {
+	elapsed := GetClock().Since(p.start)
+	p.logger.Info().Str("url", p.url).
+		Str("transferred", BytesToStr(p.written)).
+		Str("elapsed", DurationToStr(elapsed)).
+		Str("avg_speed", speedToStr(bytesPerSecond(p.written, elapsed))).
+		Msg("Download complete")
+}
+
+func bytesPerSecond(n int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
+
+// speedToStr returns a speed in bytes per second like 1.5MiB/s
+func speedToStr(bytesPerSecond float64) string {
+	return BytesToStr(int64(bytesPerSecond)) + "/s"
+}
+
+// closeDownload flushes the downloaded file to disk and closes it, before it is renamed into place
+func closeDownload(out *os.File) error {
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// verifyDownload renames the complete partial file into filePath, with opt.FileMode if set, if h, the hash of its
+// content, has the digest of opt.Checksum, or else removes it
+func verifyDownload(partial string, filePath string, opt *downloadOptions, h hash.Hash) error {
+	if opt.Checksum != nil {
+		actual := hex.EncodeToString(h.Sum(nil))
+		expected := strings.ToLower(strings.TrimSpace(opt.Checksum.Expected))
+		if actual != expected {
+			os.Remove(partial)
+			return ErrChecksumMismatch{Expected: expected, Actual: actual}
+		}
+	}
+	// os.CreateTemp creates the plain download with 0600
+	mode := os.FileMode(0644)
+	if opt.FileMode != 0 {
+		mode = opt.FileMode
+	}
+	if err := os.Chmod(partial, mode); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	return os.Rename(partial, filePath)
+}
+
+// do sends a request for url with the Range header byteRange if set, see send
+func (o *downloadOptions) do(ctx context.Context, method string, url string, byteRange string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+	return o.send(req)
+}
+
+// send sends req with the headers and cookies of the options, its URL redacted in the error.
+// Redirects to another host drop the Authorization header.
+func (o *downloadOptions) send(req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", DefaultUserAgent)
+	for k, v := range o.Header {
+		req.Header[k] = v
+	}
+
+	client := &http.Client{Transport: defaultTransport, Jar: o.Jar, CheckRedirect: o.checkRedirect, Timeout: o.Timeout}
+	if o.Client != nil {
+		c := *o.Client
+		if o.Jar != nil {
+			c.Jar = o.Jar
+		}
+		if c.CheckRedirect == nil {
+			c.CheckRedirect = o.checkRedirect
+		}
+		client = &c
+	}
+	if o.Transport != nil {
+		client.Transport = o.Transport
+	} else if o.RoundTripper != nil {
+		client.Transport = o.RoundTripper
+	}
+	resp, err := client.Do(req)
+	// the errors of the client quote the URL of the request, maybe signed
+	var urlErr *neturl.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = RedactURL(urlErr.URL)
+	}
+	return resp, err
+}
+
+// setup builds the transport of the proxy and TLS options, from the one of WithTransport or WithHTTPClient if set
+func (o *downloadOptions) setup(ctx context.Context) error {
+	if o.Insecure {
+		LoggerFromContext(ctx).Warn().Msg("TLS certificate verification is disabled, the connections are insecure")
+	}
+	if o.Proxy == nil && o.TLSConfig == nil {
+		return nil
+	}
+
+	var custom http.RoundTripper
+	if o.RoundTripper != nil {
+		custom = o.RoundTripper
+	} else if o.Client != nil {
+		custom = o.Client.Transport
+	}
+	base := defaultTransport
+	if custom != nil {
+		t, ok := custom.(*http.Transport)
+		if !ok {
+			return fmt.Errorf("the proxy and TLS options need an *http.Transport, not %T", custom)
+		}
+		base = t
+	}
+	o.Transport = base.Clone()
+	if o.Proxy != nil {
+		o.Transport.Proxy = o.Proxy
+	}
+	if o.TLSConfig != nil {
+		o.Transport.TLSClientConfig = o.TLSConfig
+	}
+	return nil
+}
+
+// checkRedirect follows up to MaxRedirects redirects, dropping the Authorization header on a redirect to
+// another host, port included
+func (o *downloadOptions) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > o.MaxRedirects {
+		chain := make([]string, 0, len(via)+1)
+		for _, r := range via {
+			chain = append(chain, RedactURL(r.URL.String()))
+		}
+		chain = append(chain, RedactURL(req.URL.String()))
+		return fmt.Errorf("stopped after %d redirects: %s", o.MaxRedirects, strings.Join(chain, " -> "))
+	}
+	if req.URL.Host != via[0].URL.Host {
+		req.Header.Del("Authorization")
+	}
+	return nil
+}
+
+// fetchChecksum returns the checksum of the file of url or filePath, in the checksum file at checksumURL
+func fetchChecksum(ctx context.Context, opt *downloadOptions, checksumURL string, url string, filePath string) (*WithChecksum, error) {
+	resp, err := opt.do(ctx, http.MethodGet, checksumURL, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download checksum file %s: %s", RedactURL(checksumURL), resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 10*1024*1024))
+	if err != nil {
+		return nil, err
+	}
+
+	digests := map[string]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		digest, name, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		// a "*" marks the binary mode
+		name = strings.TrimPrefix(strings.TrimSpace(name), "*")
+		digests[name] = digest
+	}
+
+	names := []string{filepath.Base(filePath)}
+	if u, err := neturl.Parse(url); err == nil {
+		names = append([]string{path.Base(u.Path)}, names...)
+	}
+	for _, name := range names {
+		if digest, ok := digests[name]; ok {
+			algo, err := checksumAlgo(digest)
+			if err != nil {
+				return nil, err
+			}
+			return &WithChecksum{Algo: algo, Expected: digest}, nil
+		}
+	}
+	return nil, fmt.Errorf("no checksum of %s in %s", strings.Join(names, " or "), RedactURL(checksumURL))
+}
+
+// parseContentRange parses a Content-Range header like "bytes 100-199/1000" or "bytes */1000",
+// and returns the start, or -1 for "*", and the total size, or -1 if unknown
+func parseContentRange(s string) (start, size int64, err error) {
+	rng, ok := strings.CutPrefix(s, "bytes ")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+	}
+	rng, total, ok := strings.Cut(rng, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+	}
+
+	start, size = -1, -1
+	if rng != "*" {
+		first, _, _ := strings.Cut(rng, "-")
+		if start, err = strconv.ParseInt(first, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid Content-Range %q: %w", s, err)
+		}
+	}
+	if total != "*" {
+		if size, err = strconv.ParseInt(total, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid Content-Range %q: %w", s, err)
+		}
+	}
+	return start, size, nil
 }
@@ -1,11 +1,917 @@
 package goutils_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 
 	"github.com/117503445/goutils"
+	"github.com/117503445/goutils/testutil"
 )
 
 func TestDownload(t *testing.T) {
-	goutils.Download("https://example.com/testfile", "testfile")
+	ast := assert.New(t)
+	logs := testutil.CaptureLogs(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/testfile":
+			w.Write([]byte("content"))
+		case "/truncated":
+			w.Header().Set("Content-Length", "100")
+			w.Write([]byte("content"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	ast.NoError(goutils.Download(server.URL+"/testfile", filepath.Join(dir, "testfile")))
+	content, err := goutils.ReadText(filepath.Join(dir, "testfile"))
+	ast.NoError(err)
+	ast.Equal("content", content)
+	ast.Zero(logs.Count("warn"))
+
+	// a non-2xx response fails, without touching the file
+	ast.NoError(goutils.WriteText(filepath.Join(dir, "existing"), "existing"))
+	err = goutils.Download(server.URL+"/missing", filepath.Join(dir, "existing"))
+	ast.ErrorContains(err, "404 Not Found")
+	ast.ErrorContains(err, "404 page not found")
+	content, err = goutils.ReadText(filepath.Join(dir, "existing"))
+	ast.NoError(err)
+	ast.Equal("existing", content)
+	ast.Error(goutils.Download(server.URL+"/missing", filepath.Join(dir, "missing")))
+	ast.NoFileExists(filepath.Join(dir, "missing"))
+
+	// nor when the body is cut short, and without leaving the temporary file
+	ast.Error(goutils.Download(server.URL+"/truncated", filepath.Join(dir, "existing")))
+	content, err = goutils.ReadText(filepath.Join(dir, "existing"))
+	ast.NoError(err)
+	ast.Equal("existing", content)
+	entries, err := os.ReadDir(dir)
+	ast.NoError(err)
+	ast.Len(entries, 2)
+
+	// unless allowed
+	ast.NoError(goutils.Download(server.URL+"/missing", filepath.Join(dir, "missing"), goutils.WithAllowNon2xx{}))
+	ast.Equal(1, logs.Count("warn"))
+	ast.True(logs.Contains("warn", "404 Not Found"))
+	content, err = goutils.ReadText(filepath.Join(dir, "missing"))
+	ast.NoError(err)
+	ast.Equal("404 page not found\n", content)
+}
+
+func TestDownloadResume(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	content := bytes.Repeat([]byte("0123456789"), 100*1024)
+	for _, honorRange := range []bool{true, false} {
+		var requests, sent atomic.Int64
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := requests.Add(1)
+			if n == 1 {
+				// the first attempt is interrupted mid-stream
+				w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+				w.Write(content[:len(content)/2])
+				w.(http.Flusher).Flush()
+				sent.Add(int64(len(content) / 2))
+				panic(http.ErrAbortHandler)
+			}
+			cw := &countingWriter{ResponseWriter: w}
+			if honorRange {
+				http.ServeContent(cw, r, "file", time.Time{}, bytes.NewReader(content))
+			} else {
+				cw.Write(content)
+			}
+			sent.Add(cw.n)
+		}))
+
+		path := filepath.Join(t.TempDir(), "file")
+		err := goutils.Download(server.URL, path, goutils.WithResume{})
+		ast.Error(err)
+		ast.NoFileExists(path)
+		ast.FileExists(path + ".partial")
+
+		ast.NoError(goutils.Download(server.URL, path, goutils.WithResume{}))
+		data, err := os.ReadFile(path)
+		ast.NoError(err)
+		ast.Equal(content, data)
+		ast.NoFileExists(path + ".partial")
+		if honorRange {
+			// the fetched bytes are not downloaded again
+			ast.Equal(int64(len(content)), sent.Load())
+		} else {
+			ast.Equal(int64(len(content)+len(content)/2), sent.Load())
+		}
+
+		server.Close()
+	}
+}
+
+func TestDownloadResumeComplete(t *testing.T) {
+	ast := assert.New(t)
+
+	content := []byte("content")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	// a complete partial file is renamed
+	path := filepath.Join(t.TempDir(), "file")
+	ast.NoError(os.WriteFile(path+".partial", content, 0644))
+	ast.NoError(goutils.Download(server.URL, path, goutils.WithResume{}))
+	data, err := os.ReadFile(path)
+	ast.NoError(err)
+	ast.Equal(content, data)
+
+	// a partial file larger than the content is discarded
+	ast.NoError(os.WriteFile(path+".partial", []byte("content and more"), 0644))
+	ast.Error(goutils.Download(server.URL, path, goutils.WithResume{}))
+	ast.NoFileExists(path + ".partial")
+	ast.NoError(goutils.Download(server.URL, path, goutils.WithResume{}))
+
+	server.Close()
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	ast.ErrorContains(goutils.Download(missing.URL, path, goutils.WithResume{}), "404")
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func TestDownloadChecksum(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	content := []byte("release tarball")
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app-1.0.tar.gz":
+			http.ServeContent(w, r, "app-1.0.tar.gz", time.Time{}, bytes.NewReader(content))
+		case "/SHA256SUMS":
+			fmt.Fprintf(w, "%s  other.tar.gz\n%s *app-1.0.tar.gz\n", strings.Repeat("0", 64), digest)
+		case "/BADSUMS":
+			fmt.Fprintf(w, "%s  app-1.0.tar.gz\n", strings.Repeat("0", 64))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.tar.gz")
+
+	// match
+	ast.NoError(goutils.Download(server.URL+"/app-1.0.tar.gz", path, goutils.WithChecksum{Algo: "sha256", Expected: strings.ToUpper(digest)}))
+	data, err := os.ReadFile(path)
+	ast.NoError(err)
+	ast.Equal(content, data)
+	ast.NoError(os.Remove(path))
+
+	// mismatch: nothing lands at the destination
+	md5Sum := md5.Sum([]byte("other"))
+	err = goutils.Download(server.URL+"/app-1.0.tar.gz", path, goutils.WithChecksum{Algo: "md5", Expected: hex.EncodeToString(md5Sum[:])})
+	var mismatch goutils.ErrChecksumMismatch
+	ast.ErrorAs(err, &mismatch)
+	ast.Equal(hex.EncodeToString(md5Sum[:]), mismatch.Expected)
+	actual := md5.Sum(content)
+	ast.Equal(hex.EncodeToString(actual[:]), mismatch.Actual)
+	ast.NoFileExists(path)
+	ast.NoFileExists(path + ".partial")
+
+	ast.Error(goutils.Download(server.URL+"/app-1.0.tar.gz", path, goutils.WithChecksum{Algo: "crc"}))
+
+	// checksum file, by the name in the URL
+	ast.NoError(goutils.Download(server.URL+"/app-1.0.tar.gz", path, goutils.WithChecksumURL(server.URL+"/SHA256SUMS")))
+	ast.FileExists(path)
+	ast.NoError(os.Remove(path))
+
+	err = goutils.Download(server.URL+"/app-1.0.tar.gz", path, goutils.WithChecksumURL(server.URL+"/BADSUMS"))
+	ast.ErrorAs(err, &mismatch)
+	ast.NoFileExists(path)
+
+	err = goutils.Download(server.URL+"/app-1.0.tar.gz", filepath.Join(dir, "missing.tar.gz"), goutils.WithChecksumURL(server.URL+"/missing"))
+	ast.ErrorContains(err, "404")
+
+	// with a resumed download, the fetched bytes are hashed too
+	ast.NoError(os.WriteFile(path+".partial", content[:7], 0644))
+	ast.NoError(goutils.Download(server.URL+"/app-1.0.tar.gz", path, goutils.WithResume{}, goutils.WithChecksum{Algo: "sha256", Expected: digest}))
+	data, err = os.ReadFile(path)
+	ast.NoError(err)
+	ast.Equal(content, data)
+}
+
+func TestDownloadRetries(t *testing.T) {
+	ast := assert.New(t)
+	logs := testutil.CaptureLogs(t)
+	backoff := goutils.WithRetryBackoff{Base: time.Millisecond, Max: 5 * time.Millisecond}
+
+	var requests atomic.Int64
+	var (
+		mu    sync.Mutex
+		times []time.Time
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky":
+			// fails twice, then succeeds
+			if requests.Add(1) <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("content"))
+		case "/throttled":
+			if requests.Add(1) == 1 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Write([]byte("content"))
+		case "/busy":
+			// throttled twice without Retry-After, then succeeds
+			mu.Lock()
+			times = append(times, time.Now())
+			mu.Unlock()
+			if requests.Add(1) <= 2 {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Write([]byte("content"))
+		case "/down":
+			requests.Add(1)
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			requests.Add(1)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+
+	for _, opts := range [][]goutils.DownloadOption{{}, {goutils.WithResume{}}} {
+		requests.Store(0)
+		path := filepath.Join(dir, "flaky")
+		ast.NoError(goutils.Download(server.URL+"/flaky", path, append(opts, goutils.WithRetries(3), backoff)...))
+		ast.EqualValues(3, requests.Load())
+		content, err := goutils.ReadText(path)
+		ast.NoError(err)
+		ast.Equal("content", content)
+		ast.NoError(os.Remove(path))
+	}
+	ast.Equal(4, logs.Count("debug"))
+	ast.True(logs.Contains("debug", "Download failed, retrying"))
+	ast.Equal(float64(2), logs.Entries()[1]["attempt"])
+
+	// the wait of Retry-After replaces the backoff
+	requests.Store(0)
+	start := time.Now()
+	ast.NoError(goutils.Download(server.URL+"/throttled", filepath.Join(dir, "throttled"), goutils.WithRetries(1), backoff))
+	ast.GreaterOrEqual(time.Since(start), time.Second)
+	ast.EqualValues(2, requests.Load())
+
+	// throttling backs off exponentially, with jitter
+	requests.Store(0)
+	ast.NoError(goutils.Download(server.URL+"/busy", filepath.Join(dir, "busy"), goutils.WithRetries(2),
+		goutils.WithRetryBackoff{Base: 50 * time.Millisecond, Max: time.Second, Jitter: 0.2}))
+	ast.EqualValues(3, requests.Load())
+	if ast.Len(times, 3) {
+		first, second := times[1].Sub(times[0]), times[2].Sub(times[1])
+		ast.GreaterOrEqual(first, 40*time.Millisecond)
+		ast.Greater(second, first)
+	}
+
+	// the attempts are exhausted
+	requests.Store(0)
+	err := goutils.Download(server.URL+"/down", filepath.Join(dir, "down"), goutils.WithRetries(2), backoff)
+	ast.ErrorContains(err, "failed after 3 attempts")
+	ast.ErrorContains(err, "502 Bad Gateway")
+	ast.EqualValues(3, requests.Load())
+	var temporary interface{ Temporary() bool }
+	ast.True(errors.As(err, &temporary) && temporary.Temporary())
+	ast.NoFileExists(filepath.Join(dir, "down"))
+
+	// 4xx are not retried
+	requests.Store(0)
+	err = goutils.Download(server.URL+"/missing", filepath.Join(dir, "missing"), goutils.WithRetries(2), goutils.WithResume{}, backoff)
+	ast.ErrorContains(err, "failed after 1 attempts")
+	ast.ErrorContains(err, "404 Not Found")
+	ast.EqualValues(1, requests.Load())
+	ast.True(errors.As(err, &temporary))
+	ast.False(temporary.Temporary())
+
+	ast.Error(goutils.Download(server.URL+"/flaky", filepath.Join(dir, "flaky"), goutils.WithRetries(-1)))
+	ast.Error(goutils.Download(server.URL+"/flaky", filepath.Join(dir, "flaky"), goutils.WithRetryBackoff{Jitter: 2}))
+}
+
+func TestDownloadHeaders(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	var other atomic.Value
+	otherServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		other.Store(r.Header.Clone())
+		w.Write([]byte("content"))
+	}))
+	defer otherServer.Close()
+
+	var got atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.Store(r.Header.Clone())
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cret"})
+		case "/redirect":
+			http.Redirect(w, r, "/file", http.StatusFound)
+		case "/redirect-other":
+			http.Redirect(w, r, otherServer.URL+"/file", http.StatusFound)
+		default:
+			w.Write([]byte("content"))
+		}
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	header := func() http.Header { return got.Load().(http.Header) }
+
+	ast.NoError(goutils.Download(server.URL+"/file", path))
+	ast.Equal(goutils.DefaultUserAgent, header().Get("User-Agent"))
+	ast.Empty(header().Get("Authorization"))
+
+	ast.NoError(goutils.Download(server.URL+"/file", path,
+		goutils.WithHeader{Key: "X-Api-Key", Value: "key"}, goutils.WithUserAgent("app/1.0"), goutils.WithBearerToken("token")))
+	ast.Equal("key", header().Get("X-Api-Key"))
+	ast.Equal("app/1.0", header().Get("User-Agent"))
+	ast.Equal("Bearer token", header().Get("Authorization"))
+
+	ast.NoError(goutils.Download(server.URL+"/file", path, goutils.WithBasicAuth{User: "user", Password: "pass"}, goutils.WithResume{}))
+	ast.Equal("Basic dXNlcjpwYXNz", header().Get("Authorization"))
+
+	ast.Error(goutils.Download(server.URL+"/file", path, goutils.WithHeader{Value: "value"}))
+
+	// the cookies of the jar are sent
+	jar, err := cookiejar.New(nil)
+	ast.NoError(err)
+	ast.NoError(goutils.Download(server.URL+"/login", path, goutils.WithCookieJar{Jar: jar}))
+	ast.NoError(goutils.Download(server.URL+"/file", path, goutils.WithCookieJar{Jar: jar}))
+	ast.Equal("session=s3cret", header().Get("Cookie"))
+
+	// the Authorization header follows a redirect to the same host only
+	ast.NoError(goutils.Download(server.URL+"/redirect", path, goutils.WithBearerToken("token")))
+	ast.Equal("Bearer token", header().Get("Authorization"))
+
+	ast.NoError(goutils.Download(server.URL+"/redirect-other", path, goutils.WithBearerToken("token"), goutils.WithHeader{Key: "X-Api-Key", Value: "key"}))
+	ast.Equal("Bearer token", header().Get("Authorization"))
+	otherHeader := other.Load().(http.Header)
+	ast.Empty(otherHeader.Get("Authorization"))
+	ast.Equal("key", otherHeader.Get("X-Api-Key"))
+	content, err := goutils.ReadText(path)
+	ast.NoError(err)
+	ast.Equal("content", content)
+}
+
+func TestDownloadParts(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	content := make([]byte, 1000*1024)
+	for i := range content {
+		content[i] = byte(i * 7 % 251)
+	}
+	sum := sha256.Sum256(content)
+
+	var mu sync.Mutex
+	ranges := map[string]int{}
+	var failed atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			mu.Lock()
+			ranges[r.Header.Get("Range")]++
+			mu.Unlock()
+		}
+		switch r.URL.Path {
+		case "/file":
+			http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+		case "/flaky":
+			// the range of the second part is interrupted once mid-stream
+			if strings.HasPrefix(r.Header.Get("Range"), fmt.Sprintf("bytes=%d-", len(content)/4)) && !failed.Swap(true) {
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", len(content)/4, len(content)/2-1, len(content)))
+				w.Header().Set("Content-Length", strconv.Itoa(len(content)/4))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(content[len(content)/4 : len(content)/4+1000])
+				w.(http.Flusher).Flush()
+				panic(http.ErrAbortHandler)
+			}
+			http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+		case "/stream":
+			// no ranges
+			w.Write(content)
+		}
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	check := func() {
+		data, err := os.ReadFile(path)
+		ast.NoError(err)
+		ast.True(bytes.Equal(content, data))
+		ast.NoFileExists(path + ".partial")
+		ast.NoError(os.Remove(path))
+	}
+
+	ast.NoError(goutils.Download(server.URL+"/file", path, goutils.WithParts(4),
+		goutils.WithChecksum{Algo: "sha256", Expected: hex.EncodeToString(sum[:])}))
+	check()
+	ast.Len(ranges, 4)
+	ast.Equal(1, ranges[fmt.Sprintf("bytes=0-%d", len(content)/4-1)])
+	ast.Equal(1, ranges[fmt.Sprintf("bytes=%d-%d", len(content)*3/4, len(content)-1)])
+
+	// only the failed range is retried, from where it stopped
+	clear(ranges)
+	ast.NoError(goutils.Download(server.URL+"/flaky", path, goutils.WithParts(4), goutils.WithRetries(1),
+		goutils.WithRetryBackoff{Base: time.Millisecond}))
+	check()
+	ast.Len(ranges, 5)
+	ast.Equal(1, ranges[fmt.Sprintf("bytes=%d-%d", len(content)/4, len(content)/2-1)])
+	ast.Equal(1, ranges[fmt.Sprintf("bytes=%d-%d", len(content)/4+1000, len(content)/2-1)])
+
+	// without it, the download fails
+	failed.Store(false)
+	ast.Error(goutils.Download(server.URL+"/flaky", path, goutils.WithParts(4)))
+	ast.NoFileExists(path)
+	ast.NoFileExists(path + ".partial")
+
+	// a server without ranges gets a single stream
+	clear(ranges)
+	ast.NoError(goutils.Download(server.URL+"/stream", path, goutils.WithParts(4)))
+	check()
+	ast.Equal(1, ranges[""])
+
+	ast.Error(goutils.Download(server.URL+"/file", path, goutils.WithParts(0)))
+}
+
+func TestDownloadProxy(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("direct"))
+	}))
+	defer server.Close()
+
+	// a plain HTTP proxy answering for the origin
+	var proxied, proxyAuth atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Store(r.RequestURI)
+		proxyAuth.Store(r.Header.Get("Proxy-Authorization"))
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte("proxied"))
+	}))
+	defer proxy.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	read := func() string {
+		content, err := goutils.ReadText(path)
+		ast.NoError(err)
+		return content
+	}
+
+	ast.NoError(goutils.Download(server.URL+"/file", path, goutils.WithProxyURL(proxy.URL)))
+	ast.Equal("proxied", read())
+	ast.Equal(server.URL+"/file", proxied.Load())
+
+	_, err := goutils.Upload(context.Background(), server.URL+"/upload", path, goutils.WithProxyURL(proxy.URL))
+	ast.NoError(err)
+	ast.Equal(server.URL+"/upload", proxied.Load())
+
+	// the environment proxy is not used for the loopback address
+	ast.NoError(goutils.Download(server.URL+"/file", path, goutils.WithProxyURL(proxy.URL), goutils.WithProxyFromEnv{}))
+	ast.Equal("direct", read())
+
+	ast.Error(goutils.Download(server.URL+"/file", path, goutils.WithProxyURL("proxy:3128")))
+
+	// a custom client
+	var requests atomic.Int64
+	client := &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		requests.Add(1)
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	ast.NoError(goutils.Download(server.URL+"/file", path, goutils.WithHTTPClient{Client: client}))
+	ast.Equal("direct", read())
+	ast.EqualValues(1, requests.Load())
+
+	ast.Error(goutils.Download(server.URL+"/file", path, goutils.WithHTTPClient{}))
+
+	// an authenticated proxy
+	proxyURL, err := neturl.Parse(proxy.URL)
+	ast.NoError(err)
+	proxyURL.User = neturl.UserPassword("alice", "secret")
+	ast.NoError(goutils.Download(server.URL+"/file", path, goutils.WithProxyURL(proxyURL.String())))
+	ast.Equal("Basic YWxpY2U6c2VjcmV0", proxyAuth.Load())
+
+	// a custom transport, through the proxy, with the timeout
+	requests.Store(0)
+	counting := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		requests.Add(1)
+		return http.DefaultTransport.RoundTrip(r)
+	})
+	ast.NoError(goutils.Download(server.URL+"/file", path, goutils.WithTransport{Transport: counting}))
+	ast.Equal("direct", read())
+	ast.EqualValues(1, requests.Load())
+	_, err = goutils.Upload(context.Background(), server.URL+"/upload", path, goutils.WithTransport{Transport: counting})
+	ast.NoError(err)
+	ast.EqualValues(2, requests.Load())
+	ast.NoError(goutils.WaitForURL(context.Background(), server.URL, goutils.WithTransport{Transport: counting}))
+	ast.EqualValues(3, requests.Load())
+
+	transport := &http.Transport{MaxIdleConns: 1}
+	ast.NoError(goutils.Download(server.URL+"/file", path, goutils.WithTransport{Transport: transport},
+		goutils.WithHTTPClient{Client: client}, goutils.WithProxyURL(proxy.URL)))
+	ast.Equal("proxied", read())
+	ast.Nil(transport.Proxy)
+	err = goutils.Download(server.URL+"/slow", path, goutils.WithTransport{Transport: transport},
+		goutils.WithProxyURL(proxy.URL), goutils.WithHTTPTimeout(50*time.Millisecond))
+	ast.ErrorContains(err, "Client.Timeout exceeded")
+
+	ast.ErrorContains(goutils.Download(server.URL+"/file", path, goutils.WithTransport{Transport: counting},
+		goutils.WithProxyURL(proxy.URL)), "need an *http.Transport")
+	ast.Error(goutils.Download(server.URL+"/file", path, goutils.WithTransport{}))
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestDownloadMany(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	var running, maxRunning atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+
+	sum := sha256.Sum256([]byte("/3"))
+	var items []goutils.DownloadItem
+	for _, name := range []string{"1", "2", "missing", "3", "4"} {
+		items = append(items, goutils.DownloadItem{URL: server.URL + "/" + name, Dest: filepath.Join(dir, name)})
+	}
+	items[3].Checksum = &goutils.WithChecksum{Algo: "sha256", Expected: hex.EncodeToString(sum[:])}
+
+	results := goutils.DownloadMany(context.Background(), items, 2, goutils.WithUserAgent("mirror"))
+	ast.Len(results, 5)
+	ast.EqualValues(2, maxRunning.Load())
+	for i, result := range results {
+		ast.Equal(items[i], result.Item)
+		ast.GreaterOrEqual(result.Duration, 20*time.Millisecond)
+		if i == 2 {
+			ast.ErrorContains(result.Err, "404 Not Found")
+			ast.Zero(result.Bytes)
+			ast.NoFileExists(items[i].Dest)
+			continue
+		}
+		ast.NoError(result.Err)
+		ast.EqualValues(2, result.Bytes)
+		content, err := goutils.ReadText(items[i].Dest)
+		ast.NoError(err)
+		ast.Equal("/"+filepath.Base(items[i].Dest), content)
+	}
+
+	// the other downloads are cancelled at the first failure
+	failFast := append([]goutils.DownloadItem{{URL: server.URL + "/missing", Dest: filepath.Join(dir, "missing")}}, items...)
+	results = goutils.DownloadMany(context.Background(), failFast, 1, goutils.WithFailFast{})
+	ast.ErrorContains(results[0].Err, "404 Not Found")
+	for _, result := range results[1:] {
+		ast.ErrorIs(result.Err, context.Canceled)
+	}
+
+	results = goutils.DownloadMany(context.Background(), items, 2, goutils.WithParts(0))
+	for _, result := range results {
+		ast.Error(result.Err)
+	}
+}
+
+func TestDownloadProgress(t *testing.T) {
+	ast := assert.New(t)
+	logs := testutil.CaptureLogs(t)
+
+	chunk := bytes.Repeat([]byte("x"), 10*1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/file" {
+			http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(bytes.Repeat(chunk, 10)))
+			return
+		}
+		// throttled, with a known size for /throttled only
+		if r.URL.Path == "/throttled" {
+			w.Header().Set("Content-Length", strconv.Itoa(10*len(chunk)))
+		}
+		for range 10 {
+			w.Write(chunk)
+			w.(http.Flusher).Flush()
+			time.Sleep(15 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+
+	// the progress of all the parts adds up
+	for _, opts := range [][]goutils.DownloadOption{{}, {goutils.WithResume{}}, {goutils.WithParts(3)}} {
+		var written, total int64
+		var mu sync.Mutex
+		ast.NoError(goutils.Download(server.URL+"/file", filepath.Join(dir, "file"), append(opts, goutils.WithDownloadProgress(func(w, t int64) {
+			mu.Lock()
+			defer mu.Unlock()
+			written, total = w, t
+		}))...))
+		ast.EqualValues(10*len(chunk), written)
+		ast.EqualValues(10*len(chunk), total)
+	}
+
+	ast.NoError(goutils.Download(server.URL+"/throttled", filepath.Join(dir, "throttled"), goutils.WithProgressLog(30*time.Millisecond)))
+	var progress []map[string]any
+	for _, e := range logs.Entries() {
+		if e["message"] == "Download progress" {
+			progress = append(progress, e)
+		}
+	}
+	ast.GreaterOrEqual(len(progress), 2)
+	for _, e := range progress {
+		ast.Equal(server.URL+"/throttled", e["url"])
+		ast.Equal("100KiB", e["total"])
+		_, err := goutils.ParseBytesStr(e["transferred"].(string))
+		ast.NoError(err)
+		ast.Greater(e["percent"], float64(0))
+		ast.LessOrEqual(e["percent"], float64(100))
+		ast.True(strings.HasSuffix(e["avg_speed"].(string), "/s"))
+		_, err = goutils.ParseBytesStr(strings.TrimSuffix(e["speed"].(string), "/s"))
+		ast.NoError(err)
+		ast.NotEmpty(e["eta"])
+	}
+	last := logs.Entries()[len(logs.Entries())-1]
+	ast.Equal("Download complete", last["message"])
+	ast.Equal("100KiB", last["transferred"])
+	ast.NotEmpty(last["elapsed"])
+	ast.NotEmpty(last["avg_speed"])
+
+	// unknown size
+	before := len(logs.Entries())
+	ast.NoError(goutils.Download(server.URL+"/stream", filepath.Join(dir, "stream"), goutils.WithProgressLog(30*time.Millisecond)))
+	entries := logs.Entries()[before:]
+	ast.GreaterOrEqual(len(entries), 3)
+	for _, e := range entries[:len(entries)-1] {
+		ast.Equal("Download progress", e["message"])
+		ast.NotContains(e, "percent")
+		ast.NotContains(e, "eta")
+		ast.NotContains(e, "total")
+		ast.NotEmpty(e["speed"])
+	}
+
+	ast.Error(goutils.Download(server.URL+"/file", filepath.Join(dir, "file"), goutils.WithProgressLog(0)))
+}
+
+func TestDownloadFromMirrors(t *testing.T) {
+	ast := assert.New(t)
+	logs := testutil.CaptureLogs(t)
+
+	content := []byte("artifact")
+	sum := sha256.Sum256(content)
+	checksum := goutils.WithChecksum{Algo: "sha256", Expected: hex.EncodeToString(sum[:])}
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	corrupt := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("corrupt!"))
+	}))
+	defer corrupt.Close()
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer good.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "artifact")
+	urls := []string{missing.URL + "/artifact", corrupt.URL + "/artifact", good.URL + "/artifact"}
+
+	url, err := goutils.DownloadFromMirrors(context.Background(), urls, path, checksum)
+	ast.NoError(err)
+	ast.Equal(good.URL+"/artifact", url)
+	data, err := os.ReadFile(path)
+	ast.NoError(err)
+	ast.Equal(content, data)
+	ast.Equal(2, logs.Count("warn"))
+	ast.NoError(os.Remove(path))
+
+	// all the mirrors fail
+	url, err = goutils.DownloadFromMirrors(context.Background(), urls[:2], path, checksum, goutils.WithShuffleMirrors{})
+	ast.Empty(url)
+	ast.ErrorContains(err, "mirror "+missing.URL+"/artifact")
+	ast.ErrorContains(err, "404 Not Found")
+	var mismatch goutils.ErrChecksumMismatch
+	ast.ErrorAs(err, &mismatch)
+	ast.NoFileExists(path)
+
+	// shuffled, any mirror may be first
+	firsts := map[string]bool{}
+	for range 50 {
+		url, err = goutils.DownloadFromMirrors(context.Background(), []string{corrupt.URL, good.URL}, path, goutils.WithShuffleMirrors{})
+		ast.NoError(err)
+		firsts[url] = true
+	}
+	ast.Len(firsts, 2)
+
+	_, err = goutils.DownloadFromMirrors(context.Background(), nil, path)
+	ast.Error(err)
+}
+
+func TestDownloadRedirects(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		if n > 0 {
+			target := fmt.Sprintf("/%d", n-1)
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusFound)
+			return
+		}
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "file")
+
+	ast.NoError(goutils.Download(server.URL+"/10", path))
+	ast.NoError(goutils.Download(server.URL+"/3", path, goutils.WithMaxRedirects(3)))
+
+	err := goutils.Download(server.URL+"/3", path, goutils.WithMaxRedirects(2))
+	ast.ErrorContains(err, "stopped after 2 redirects")
+	ast.ErrorContains(err, fmt.Sprintf("%s/3 -> %s/2 -> %s/1 -> %s/0", server.URL, server.URL, server.URL, server.URL))
+	ast.Error(goutils.Download(server.URL+"/1", path, goutils.WithMaxRedirects(0)))
+	ast.Error(goutils.Download(server.URL+"/11", path))
+
+	// the signed URLs of the chain are redacted
+	err = goutils.Download(server.URL+"/3?token=secret", path, goutils.WithMaxRedirects(2))
+	ast.ErrorContains(err, "stopped after 2 redirects")
+	ast.ErrorContains(err, server.URL+"/0?token=")
+	ast.NotContains(err.Error(), "secret")
+
+	// with a custom client
+	ast.Error(goutils.Download(server.URL+"/2", path, goutils.WithHTTPClient{Client: &http.Client{}}, goutils.WithMaxRedirects(1)))
+}
+
+func TestDownloadTLS(t *testing.T) {
+	ast := assert.New(t)
+	logs := testutil.CaptureLogs(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	caFile := filepath.Join(dir, "ca.pem")
+	ast.NoError(os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644))
+
+	err := goutils.Download(server.URL, path)
+	ast.ErrorContains(err, "certificate")
+
+	ast.NoError(goutils.Download(server.URL, path, goutils.WithCAFile(caFile)))
+	content, err := goutils.ReadText(path)
+	ast.NoError(err)
+	ast.Equal("content", content)
+
+	// composed with the other options
+	ast.NoError(goutils.Download(server.URL, path, goutils.WithTLSConfig{Config: &tls.Config{MinVersion: tls.VersionTLS12}},
+		goutils.WithCAFile(caFile), goutils.WithHTTPClient{Client: &http.Client{Transport: &http.Transport{}}}))
+	_, err = goutils.Upload(context.Background(), server.URL, path, goutils.WithCAFile(caFile), goutils.WithProxyFromEnv{})
+	ast.NoError(err)
+	ast.Error(goutils.Download(server.URL, path, goutils.WithCAFile(caFile), goutils.WithHTTPClient{Client: &http.Client{
+		Transport: roundTripperFunc(http.DefaultTransport.RoundTrip),
+	}}))
+
+	ast.Error(goutils.Download(server.URL, path, goutils.WithCAFile(filepath.Join(dir, "missing.pem"))))
+	ast.Error(goutils.Download(server.URL, path, goutils.WithCAFile(path)))
+
+	ast.Zero(logs.Count("warn"))
+	ast.NoError(goutils.Download(server.URL, path, goutils.WithInsecureSkipVerify{}))
+	ast.True(logs.Contains("warn", "TLS certificate verification is disabled"))
+}
+
+func TestDownloadToDir(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/download/latest":
+			w.Header().Set("Content-Disposition", `attachment; filename="app.bin"; filename*=UTF-8''%E5%BA%94%E7%94%A8-1.0.bin`)
+		case "/download/evil":
+			w.Header().Set("Content-Disposition", `attachment; filename="../../etc/passwd"`)
+		case "/redirect":
+			http.Redirect(w, r, "/releases/tool-2.0", http.StatusFound)
+			return
+		}
+		w.Write([]byte("binary"))
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+
+	path, err := goutils.DownloadToDir(context.Background(), server.URL+"/download/latest", dir, goutils.WithFileMode(0755))
+	ast.NoError(err)
+	ast.Equal(filepath.Join(dir, "应用-1.0.bin"), path)
+	info, err := os.Stat(path)
+	ast.NoError(err)
+	ast.Equal(os.FileMode(0755), info.Mode().Perm())
+
+	// the URL after the redirects
+	path, err = goutils.DownloadToDir(context.Background(), server.URL+"/redirect", dir)
+	ast.NoError(err)
+	ast.Equal(filepath.Join(dir, "tool-2.0"), path)
+	content, err := goutils.ReadText(path)
+	ast.NoError(err)
+	ast.Equal("binary", content)
+
+	_, err = goutils.DownloadToDir(context.Background(), server.URL+"/download/evil", dir)
+	ast.ErrorContains(err, "invalid file name")
+	ast.NoFileExists(filepath.Join(dir, "..", "..", "etc", "passwd"))
+	_, err = goutils.DownloadToDir(context.Background(), server.URL+"/", dir)
+	ast.ErrorContains(err, "invalid file name")
+
+	_, err = goutils.DownloadToDir(context.Background(), server.URL+"/redirect", dir, goutils.WithNoOverwrite{})
+	ast.ErrorIs(err, goutils.ErrExists)
+}
+
+func TestDownloadFileMode(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "tool", time.Time{}, strings.NewReader("#!/bin/sh\n"))
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+
+	for i, opts := range [][]goutils.DownloadOption{{}, {goutils.WithResume{}}, {goutils.WithParts(2)}} {
+		path := filepath.Join(dir, strconv.Itoa(i))
+		ast.NoError(goutils.Download(server.URL, path, append(opts, goutils.WithFileMode(0700))...))
+		info, err := os.Stat(path)
+		ast.NoError(err)
+		ast.Equal(os.FileMode(0700), info.Mode().Perm())
+	}
+
+	ast.Error(goutils.Download(server.URL, filepath.Join(dir, "0"), goutils.WithNoOverwrite{}))
+	ast.Error(goutils.Download(server.URL, filepath.Join(dir, "0"), goutils.WithFileMode(0)))
 }
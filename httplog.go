@@ -0,0 +1,105 @@
+package goutils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// RequestIDHeader is the header read and set by HTTPMiddleware
+const RequestIDHeader = "X-Request-Id"
+
+// NewRequestLogger returns a child of base with the field request_id, a new UUID7 if requestID is empty.
+// A nil base means the global log.Logger.
+func NewRequestLogger(base *zerolog.Logger, requestID string) zerolog.Logger {
+	if base == nil {
+		base = &log.Logger
+	}
+	if requestID == "" {
+		requestID = UUID7()
+	}
+	return base.With().Str("request_id", requestID).Logger()
+}
+
+// Ctx returns the logger of ctx, as injected by HTTPMiddleware, or the global log.Logger
+func Ctx(ctx context.Context) *zerolog.Logger {
+	if logger := zerolog.Ctx(ctx); logger.GetLevel() != zerolog.Disabled {
+		return logger
+	}
+	return &log.Logger
+}
+
+// statusRecorder records the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// HTTPMiddleware injects a request logger, see NewRequestLogger, into the request context for Ctx.
+// The request id is read from the X-Request-Id header, or generated, and is set on the response header.
+// The method, path, status and duration are logged when the request completes.
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" {
+			requestID = UUID7()
+		}
+		logger := NewRequestLogger(nil, requestID)
+		w.Header().Set(RequestIDHeader, requestID)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(logger.WithContext(r.Context())))
+
+		logger.Info().
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Int("status", rec.status).
+			Dur("duration", time.Since(start)).
+			Msg("HTTP request")
+	})
+}
+
+// LogLevelHandler serves the level of the loggers installed by InitZeroLog, for an admin endpoint:
+// GET returns the level name, PUT sets it from the level name in the body, like "debug".
+func LogLevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			body, err := io.ReadAll(io.LimitReader(r.Body, 64))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			level, err := zerolog.ParseLevel(strings.TrimSpace(string(body)))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			SetLogLevel(level)
+			Logger.Log().Str("level", level.String()).Msg("Log level changed by HTTP")
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		fmt.Fprintln(w, GetLogLevel())
+	})
+}
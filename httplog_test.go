@@ -0,0 +1,114 @@
+package goutils_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.InitZeroLog()
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	goutils.InitZeroLog(goutils.WithLogger{Logger: &logger})
+
+	handler := goutils.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		goutils.Ctx(r.Context()).Info().Msg("in handler")
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/tea", nil)
+	ast.NoError(err)
+	req.Header.Set(goutils.RequestIDHeader, "req-1")
+	resp, err := http.DefaultClient.Do(req)
+	ast.NoError(err)
+	resp.Body.Close()
+	ast.Equal("req-1", resp.Header.Get(goutils.RequestIDHeader))
+	ast.Contains(buf.String(), `"request_id":"req-1","message":"in handler"`)
+	ast.Contains(buf.String(), `"status":418`)
+	ast.Contains(buf.String(), `"path":"/tea"`)
+
+	// a missing request id is generated
+	buf.Reset()
+	resp, err = http.Get(server.URL)
+	ast.NoError(err)
+	resp.Body.Close()
+	requestID := resp.Header.Get(goutils.RequestIDHeader)
+	ast.Len(requestID, 36)
+	ast.Contains(buf.String(), `"request_id":"`+requestID+`"`)
+}
+
+func TestNewRequestLogger(t *testing.T) {
+	ast := assert.New(t)
+
+	var buf bytes.Buffer
+	base := zerolog.New(&buf)
+	logger := goutils.NewRequestLogger(&base, "")
+	logger.Info().Msg("hello")
+	ast.Regexp(`"request_id":"[0-9a-f-]{36}"`, buf.String())
+
+	// without a logger in the context, Ctx returns the global logger
+	ast.Equal(&log.Logger, goutils.Ctx(context.Background()))
+}
+
+func TestLogLevelHandler(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.InitZeroLog()
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	goutils.InitZeroLog(goutils.WithLogger{Logger: &logger}, goutils.WithLevel(zerolog.InfoLevel))
+	child := log.With().Str("child", "1").Logger()
+
+	server := httptest.NewServer(goutils.LogLevelHandler())
+	defer server.Close()
+	do := func(method, body string) (int, string) {
+		req, err := http.NewRequest(method, server.URL, strings.NewReader(body))
+		ast.NoError(err)
+		resp, err := http.DefaultClient.Do(req)
+		ast.NoError(err)
+		defer resp.Body.Close()
+		content, err := io.ReadAll(resp.Body)
+		ast.NoError(err)
+		return resp.StatusCode, strings.TrimSpace(string(content))
+	}
+
+	status, level := do(http.MethodGet, "")
+	ast.Equal(http.StatusOK, status)
+	ast.Equal("info", level)
+	child.Debug().Msg("debug 1")
+	ast.NotContains(buf.String(), "debug 1")
+
+	status, level = do(http.MethodPut, "debug")
+	ast.Equal(http.StatusOK, status)
+	ast.Equal("debug", level)
+	child.Debug().Msg("debug 2")
+	goutils.CommandLogger.Debug().Msg("debug 3")
+	ast.Contains(buf.String(), "debug 2")
+	ast.Contains(buf.String(), "debug 3")
+
+	status, _ = do(http.MethodPut, "warn")
+	ast.Equal(http.StatusOK, status)
+	child.Info().Msg("info 4")
+	ast.NotContains(buf.String(), "info 4")
+
+	status, _ = do(http.MethodPut, "verbose")
+	ast.Equal(http.StatusBadRequest, status)
+	status, _ = do(http.MethodPost, "debug")
+	ast.Equal(http.StatusMethodNotAllowed, status)
+	ast.Equal(zerolog.WarnLevel, goutils.GetLogLevel())
+}
@@ -0,0 +1,98 @@
+package goutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// AppendJSONArray appends item to a file holding a JSON array, the file is created as [item] if missing or empty.
+//
+// The read-modify-write is done under LockFile of filename + ".lock" and the file is replaced with AtomicWriteFile,
+// so concurrent appends through AppendJSONArray are not lost and readers never see a partial file.
+// Existing content which is not a JSON array fails without modifying the file.
+func AppendJSONArray[T any](filename string, item T) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	return WithFileLock(filename+".lock", func() error {
+		var items []json.RawMessage
+		content, err := os.ReadFile(filename)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if len(bytes.TrimSpace(content)) > 0 {
+			if err := json.Unmarshal(content, &items); err != nil {
+				return jsonDecodeError(filename, err)
+			}
+		}
+		items = append(items, data)
+
+		content, err = json.MarshalIndent(items, "", "    ")
+		if err != nil {
+			return err
+		}
+		return AtomicWriteFile(filename, bytes.NewReader(content))
+	})
+}
+
+// MergeJSONFiles deep-merges the JSON objects of srcs into dst, which is created if missing.
+//
+// The existing object of dst is the base, then srcs are merged in order: nested objects are merged key by key,
+// and any other value, arrays included, is replaced by the later one. dst is written with AtomicWriteFile.
+func MergeJSONFiles(dst string, srcs ...string) error {
+	merged := map[string]interface{}{}
+	if PathExists(dst) {
+		if err := readJSONObject(dst, &merged); err != nil {
+			return err
+		}
+	}
+
+	for _, src := range srcs {
+		var obj map[string]interface{}
+		if err := readJSONObject(src, &obj); err != nil {
+			return err
+		}
+		mergeJSONObject(merged, obj)
+	}
+
+	content, err := json.MarshalIndent(merged, "", "    ")
+	if err != nil {
+		return err
+	}
+	return AtomicWriteFile(dst, bytes.NewReader(content))
+}
+
+// readJSONObject decodes the JSON object of filename, numbers are kept as json.Number to avoid losing precision
+func readJSONObject(filename string, obj *map[string]interface{}) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	dec := json.NewDecoder(file)
+	dec.UseNumber()
+	if err := dec.Decode(obj); err != nil {
+		return jsonDecodeError(filename, err)
+	}
+	if *obj == nil {
+		return fmt.Errorf("json file %s is not an object", filename)
+	}
+	return nil
+}
+
+func mergeJSONObject(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcObj, srcIsObj := v.(map[string]interface{})
+		dstObj, dstIsObj := dst[k].(map[string]interface{})
+		if srcIsObj && dstIsObj {
+			mergeJSONObject(dstObj, srcObj)
+			continue
+		}
+		dst[k] = v
+	}
+}
@@ -0,0 +1,91 @@
+package goutils_test
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestAppendJSONArray(t *testing.T) {
+	ast := assert.New(t)
+
+	type record struct {
+		ID int `json:"id"`
+	}
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "db.json")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			ast.NoError(goutils.AppendJSONArray(filename, record{ID: id}))
+		}(i)
+	}
+	wg.Wait()
+
+	var records []record
+	ast.NoError(goutils.ReadJSON(filename, &records))
+	ast.Len(records, 20)
+	var ids []int
+	for _, r := range records {
+		ids = append(ids, r.ID)
+	}
+	sort.Ints(ids)
+	for i, id := range ids {
+		ast.Equal(i, id)
+	}
+
+	// corrupt content is an error and is left untouched
+	corrupt := filepath.Join(dir, "corrupt.json")
+	ast.NoError(goutils.WriteText(corrupt, `[{"id": 1},`))
+	err := goutils.AppendJSONArray(corrupt, record{ID: 2})
+	ast.ErrorContains(err, corrupt)
+	content, err := goutils.ReadText(corrupt)
+	ast.NoError(err)
+	ast.Equal(`[{"id": 1},`, content)
+
+	ast.NoError(goutils.WriteText(corrupt, `{"id": 1}`))
+	ast.Error(goutils.AppendJSONArray(corrupt, record{ID: 2}))
+}
+
+func TestMergeJSONFiles(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "dst.json")
+	a := filepath.Join(dir, "a.json")
+	b := filepath.Join(dir, "b.json")
+	ast.NoError(goutils.WriteText(dst, `{"name": "app", "db": {"host": "localhost", "port": 5432}, "tags": ["a", "b"]}`))
+	ast.NoError(goutils.WriteText(a, `{"db": {"host": "db.internal"}, "tags": ["c"], "id": 12345678901234567890}`))
+	ast.NoError(goutils.WriteText(b, `{"db": {"user": "root"}, "name": "app2"}`))
+
+	ast.NoError(goutils.MergeJSONFiles(dst, a, b))
+	content, err := goutils.ReadText(dst)
+	ast.NoError(err)
+	ast.JSONEq(`{
+		"name": "app2",
+		"db": {"host": "db.internal", "port": 5432, "user": "root"},
+		"tags": ["c"],
+		"id": 12345678901234567890
+	}`, content)
+	ast.Contains(content, "12345678901234567890")
+
+	// a missing dst is created
+	created := filepath.Join(dir, "created.json")
+	ast.NoError(goutils.MergeJSONFiles(created, b))
+	content, err = goutils.ReadText(created)
+	ast.NoError(err)
+	ast.JSONEq(`{"db": {"user": "root"}, "name": "app2"}`, content)
+
+	ast.NoError(goutils.WriteText(a, `[1, 2]`))
+	ast.Error(goutils.MergeJSONFiles(dst, a))
+	ast.Error(goutils.MergeJSONFiles(dst, filepath.Join(dir, "missing.json")))
+}
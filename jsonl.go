@@ -0,0 +1,133 @@
+package goutils
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteJSONLines writes items to a file in JSON Lines format, one JSON document per line
+func WriteJSONLines[T any](filename string, items []T) error {
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, item := range items {
+		if err := enc.Encode(item); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// AppendJSONLine appends item to a JSON Lines file, the file is created if missing
+func AppendJSONLine[T any](filename string, item T) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	return AppendText(filename, string(data)+"\n")
+}
+
+// JSONLinesOption is an option for ReadJSONLines
+type JSONLinesOption interface {
+	applyTo(*jsonLinesOptions) error
+}
+
+type jsonLinesOptions struct {
+	MaxLineSize  int
+	OnParseError func(lineNo int, err error) error
+}
+
+// WithMaxLineSize is an option to set the maximum size of a line, 1MB by default
+type WithMaxLineSize int
+
+func (w WithMaxLineSize) applyTo(o *jsonLinesOptions) error {
+	if w <= 0 {
+		return fmt.Errorf("invalid max line size: %d", w)
+	}
+	o.MaxLineSize = int(w)
+	return nil
+}
+
+// WithOnParseError is an option to handle the lines failing to parse.
+// Returning nil skips the line, returning an error stops the reading with it.
+type WithOnParseError func(lineNo int, err error) error
+
+func (w WithOnParseError) applyTo(o *jsonLinesOptions) error {
+	o.OnParseError = w
+	return nil
+}
+
+// ReadJSONLines streams a JSON Lines file, calling fn with each decoded item and its 1-based line number.
+// Blank lines are skipped.
+//
+// Reading stops at the first error returned by fn. Lines failing to parse don't stop the reading,
+// their errors, with line numbers, are joined and returned at the end, unless handled by WithOnParseError.
+func ReadJSONLines[T any](filename string, fn func(item T, lineNo int) error, opts ...JSONLinesOption) error {
+	opt := &jsonLinesOptions{
+		MaxLineSize: 1024 * 1024,
+	}
+	for _, o := range opts {
+		if err := o.applyTo(opt); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, min(64*1024, opt.MaxLineSize)), opt.MaxLineSize)
+
+	var parseErrs []error
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Bytes()
+		if strings.TrimSpace(string(line)) == "" {
+			continue
+		}
+
+		var item T
+		if err := json.Unmarshal(line, &item); err != nil {
+			err = fmt.Errorf("failed to parse line %d of %s: %w", lineNo, filename, err)
+			if opt.OnParseError != nil {
+				if err := opt.OnParseError(lineNo, err); err != nil {
+					return err
+				}
+			} else {
+				parseErrs = append(parseErrs, err)
+			}
+			continue
+		}
+
+		if err := fn(item, lineNo); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read line %d of %s: %w", lineNo+1, filename, err)
+	}
+
+	return errors.Join(parseErrs...)
+}
@@ -0,0 +1,76 @@
+package goutils_test
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestJSONLines(t *testing.T) {
+	ast := assert.New(t)
+
+	type Record struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	file := filepath.Join(t.TempDir(), "sub", "records.jsonl")
+
+	var records []Record
+	for i := 0; i < 10000; i++ {
+		records = append(records, Record{ID: i, Name: fmt.Sprintf("record-%d", i)})
+	}
+	ast.NoError(goutils.WriteJSONLines(file, records[:9999]))
+	ast.NoError(goutils.AppendJSONLine(file, records[9999]))
+
+	var read []Record
+	err := goutils.ReadJSONLines(file, func(item Record, lineNo int) error {
+		ast.Equal(len(read)+1, lineNo)
+		read = append(read, item)
+		return nil
+	})
+	ast.NoError(err)
+	ast.Equal(records, read)
+
+	// blank lines are skipped, malformed lines are reported without aborting
+	ast.NoError(goutils.WriteText(file, "{\"id\": 1}\n\n{\"id\": \n{\"id\": 4}\n"))
+	var ids []int
+	err = goutils.ReadJSONLines(file, func(item Record, lineNo int) error {
+		ids = append(ids, item.ID)
+		return nil
+	})
+	ast.Error(err)
+	ast.Contains(err.Error(), "line 3")
+	ast.Equal([]int{1, 4}, ids)
+
+	// abort on parse error
+	errAbort := errors.New("abort")
+	ids = nil
+	err = goutils.ReadJSONLines(file, func(item Record, lineNo int) error {
+		ids = append(ids, item.ID)
+		return nil
+	}, goutils.WithOnParseError(func(lineNo int, err error) error {
+		ast.Equal(3, lineNo)
+		return errAbort
+	}))
+	ast.ErrorIs(err, errAbort)
+	ast.Equal([]int{1}, ids)
+
+	// abort from fn
+	err = goutils.ReadJSONLines(file, func(item Record, lineNo int) error {
+		return errAbort
+	})
+	ast.ErrorIs(err, errAbort)
+
+	// lines over the max size
+	ast.NoError(goutils.WriteText(file, "{\"id\": 1, \"name\": \"a long name\"}\n"))
+	err = goutils.ReadJSONLines(file, func(item Record, lineNo int) error {
+		return nil
+	}, goutils.WithMaxLineSize(10))
+	ast.Error(err)
+}
@@ -0,0 +1,130 @@
+package goutils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrFileTooLarge is matched by the FileTooLargeError of the Limit helpers, like ReadTextLimit
+var ErrFileTooLarge = errors.New("file too large")
+
+// FileTooLargeError is returned when a file is larger than the limit of a read
+type FileTooLargeError struct {
+	Path string
+	// Size is the size of the file, or the number of bytes read when the file grew or has no size, like a pipe
+	Size  int64
+	Limit int64
+}
+
+func (e *FileTooLargeError) Error() string {
+	return fmt.Sprintf("file %s is too large: %d bytes, limit %d bytes", e.Path, e.Size, e.Limit)
+}
+
+func (e *FileTooLargeError) Unwrap() error {
+	return ErrFileTooLarge
+}
+
+// limitReader reads at most limit bytes of r, and fails with FileTooLargeError beyond
+type limitReader struct {
+	r     io.Reader
+	path  string
+	limit int64
+	read  int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.read > l.limit {
+		return 0, &FileTooLargeError{Path: l.path, Size: l.read, Limit: l.limit}
+	}
+	// read one byte past the limit to tell a file of exactly limit bytes from a larger one
+	if remaining := l.limit + 1 - l.read; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.limit {
+		return n - int(l.read-l.limit), &FileTooLargeError{Path: l.path, Size: l.read, Limit: l.limit}
+	}
+	return n, err
+}
+
+// openLimited opens filename and checks its size against maxBytes before any read.
+// The returned reader fails with FileTooLargeError if the file grows past maxBytes while reading.
+// A maxBytes <= 0 means unlimited.
+func openLimited(filename string, maxBytes int64) (*os.File, io.Reader, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	if maxBytes <= 0 {
+		return file, file, nil
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if info.Size() > maxBytes {
+		file.Close()
+		return nil, nil, &FileTooLargeError{Path: filename, Size: info.Size(), Limit: maxBytes}
+	}
+	return file, &limitReader{r: file, path: filename, limit: maxBytes}, nil
+}
+
+// ReadTextLimit is like ReadText, but fails with FileTooLargeError instead of reading a file larger than maxBytes.
+// A maxBytes <= 0 means unlimited.
+func ReadTextLimit(filename string, maxBytes int64) (string, error) {
+	filename, err := ExpandPath(filename)
+	if err != nil {
+		return "", err
+	}
+
+	file, r, err := openLimited(filename, maxBytes)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// ReadJSONLimited is like ReadJSON, but fails with FileTooLargeError instead of reading a file larger than maxBytes.
+// A maxBytes <= 0 means unlimited.
+func ReadJSONLimited[T any](filename string, data *T, maxBytes int64) error {
+	file, r, err := openLimited(filename, maxBytes)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(r).Decode(data); err != nil {
+		return jsonDecodeError(filename, err)
+	}
+	return nil
+}
+
+// ReadYAMLLimited is like ReadYAML, but fails with FileTooLargeError instead of reading a file larger than maxBytes.
+// A maxBytes <= 0 means unlimited.
+func ReadYAMLLimited[T any](filename string, data *T, maxBytes int64) error {
+	file, r, err := openLimited(filename, maxBytes)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// an empty file decodes to the zero value
+	if err := yaml.NewDecoder(r).Decode(data); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to decode yaml file %s: %w", filename, err)
+	}
+	return nil
+}
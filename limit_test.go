@@ -0,0 +1,65 @@
+package goutils_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestReadTextLimit(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+	under := filepath.Join(dir, "under.txt")
+	over := filepath.Join(dir, "over.txt")
+	ast.NoError(goutils.WriteText(under, strings.Repeat("a", 100)))
+	ast.NoError(goutils.WriteText(over, strings.Repeat("a", 101)))
+
+	content, err := goutils.ReadTextLimit(under, 100)
+	ast.NoError(err)
+	ast.Len(content, 100)
+
+	_, err = goutils.ReadTextLimit(over, 100)
+	ast.ErrorIs(err, goutils.ErrFileTooLarge)
+	var tooLarge *goutils.FileTooLargeError
+	ast.True(errors.As(err, &tooLarge))
+	ast.Equal(int64(101), tooLarge.Size)
+	ast.Equal(int64(100), tooLarge.Limit)
+	ast.Contains(err.Error(), "101")
+
+	// zero or negative limits are unlimited
+	content, err = goutils.ReadTextLimit(over, 0)
+	ast.NoError(err)
+	ast.Len(content, 101)
+	_, err = goutils.ReadTextLimit(over, -1)
+	ast.NoError(err)
+}
+
+func TestReadJSONLimited(t *testing.T) {
+	ast := assert.New(t)
+
+	type config struct {
+		Name string `json:"name" yaml:"name"`
+	}
+
+	dir := t.TempDir()
+	jsonFile := filepath.Join(dir, "config.json")
+	yamlFile := filepath.Join(dir, "config.yaml")
+	ast.NoError(goutils.WriteText(jsonFile, `{"name": "app"}`))
+	ast.NoError(goutils.WriteText(yamlFile, "name: app\n"))
+
+	var cfg config
+	ast.NoError(goutils.ReadJSONLimited(jsonFile, &cfg, 15))
+	ast.Equal("app", cfg.Name)
+	ast.ErrorIs(goutils.ReadJSONLimited(jsonFile, &cfg, 14), goutils.ErrFileTooLarge)
+
+	cfg = config{}
+	ast.NoError(goutils.ReadYAMLLimited(yamlFile, &cfg, 10))
+	ast.Equal("app", cfg.Name)
+	ast.ErrorIs(goutils.ReadYAMLLimited(yamlFile, &cfg, 9), goutils.ErrFileTooLarge)
+}
@@ -0,0 +1,64 @@
+package goutils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrLocked is returned by TryLockFile when the lock is held by someone else
+var ErrLocked = errors.New("file is locked")
+
+// LockFile acquires an exclusive advisory lock on path, blocking until it is available.
+// The lock file is created if missing and is not removed by unlock.
+//
+// The lock is held by the open file, so it is released by the OS when the process exits,
+// and a lock file left by a crashed process never blocks. The lock only excludes other users of LockFile,
+// not plain reads and writes.
+func LockFile(path string) (unlock func() error, err error) {
+	return lockFile(path, true)
+}
+
+// TryLockFile is like LockFile, but returns ErrLocked immediately if the lock is held
+func TryLockFile(path string) (unlock func() error, err error) {
+	return lockFile(path, false)
+}
+
+// WithFileLock runs fn while holding the lock on path, the lock is released even if fn panics
+func WithFileLock(path string, fn func() error) (err error) {
+	unlock, err := LockFile(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := unlock(); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}()
+
+	return fn()
+}
+
+func lockFile(path string, block bool) (func() error, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFd(f, block); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() error {
+		if err := unlockFd(f); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}, nil
+}
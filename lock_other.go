@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly && !windows
+
+package goutils
+
+import (
+	"errors"
+	"os"
+)
+
+func lockFd(f *os.File, block bool) error {
+	return errors.ErrUnsupported
+}
+
+func unlockFd(f *os.File) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,102 @@
+package goutils_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestLockFile(t *testing.T) {
+	ast := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "state.lock")
+
+	unlock, err := goutils.LockFile(path)
+	ast.NoError(err)
+
+	// the lock is held by the open file, so a second lock in the same process conflicts
+	_, err = goutils.TryLockFile(path)
+	ast.ErrorIs(err, goutils.ErrLocked)
+
+	acquired := make(chan struct{})
+	go func() {
+		unlock2, err := goutils.LockFile(path)
+		ast.NoError(err)
+		close(acquired)
+		ast.NoError(unlock2())
+	}()
+
+	select {
+	case <-acquired:
+		ast.Fail("lock acquired while held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	ast.NoError(unlock())
+	select {
+	case <-acquired:
+	case <-time.After(2 * time.Second):
+		ast.Fail("lock not acquired after unlock")
+	}
+}
+
+func TestWithFileLock(t *testing.T) {
+	ast := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "state.lock")
+
+	// mutual exclusion of a read-modify-write of a counter file, the race detector can't see the lock,
+	// so the overlaps are counted with atomics
+	counterPath := filepath.Join(filepath.Dir(path), "counter")
+	ast.NoError(goutils.WriteText(counterPath, "0"))
+	var inside, overlaps atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := goutils.WithFileLock(path, func() error {
+				if inside.Add(1) > 1 {
+					overlaps.Add(1)
+				}
+				defer inside.Add(-1)
+
+				text, err := goutils.ReadText(counterPath)
+				if err != nil {
+					return err
+				}
+				v, err := strconv.Atoi(text)
+				if err != nil {
+					return err
+				}
+				time.Sleep(time.Millisecond)
+				return goutils.WriteText(counterPath, strconv.Itoa(v+1))
+			})
+			ast.NoError(err)
+		}()
+	}
+	wg.Wait()
+	ast.Zero(overlaps.Load())
+	counter, err := goutils.ReadText(counterPath)
+	ast.NoError(err)
+	ast.Equal("20", counter)
+
+	errFn := errors.New("fn failed")
+	ast.ErrorIs(goutils.WithFileLock(path, func() error { return errFn }), errFn)
+
+	// the lock is released on panic
+	ast.Panics(func() {
+		goutils.WithFileLock(path, func() error { panic("boom") })
+	})
+	unlock, err := goutils.TryLockFile(path)
+	ast.NoError(err)
+	ast.NoError(unlock())
+}
@@ -1,8 +1,19 @@
 package goutils
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -11,14 +22,159 @@ import (
 var Logger = log.With().Str("module", "goutils").Logger()
 
 type logOptions struct {
-	NoColor bool
-	Logger  *zerolog.Logger
+	NoColor    bool
+	Color      bool
+	AutoFormat bool
+	Logger     *zerolog.Logger
+	Level      *zerolog.Level
+	Hooks      []zerolog.Hook
+
+	// Writers are the outputs besides stdout, like the files of WithProduction.
+	// When set, the logger writes JSON with timestamp and caller to all of them, and to a console writer on stdout.
+	Writers []io.Writer
+	// JSONStdout writes raw JSON to stdout instead of the console writer
+	JSONStdout bool
+	TimeFormat string
+	Sampler    zerolog.Sampler
+
+	SIGHUPToggle  bool
+	resolvedLevel zerolog.Level
+	DirBuild      string
+
+	Async        *WithAsyncWriter
+	asyncWriters []*asyncWriter
+	// pruners are the retention sweeps of WithProduction, started when the logger is installed
+	pruners []*logPruner
+	// closers are closed by CloseLogs, or when another logger is installed
+	closers []io.Closer
+
+	// LogFiles are the paths of the log files opened by WithProduction
+	LogFiles []string
+
+	Redactor *redactor
+
+	TimeLocation *time.Location
+
+	// Fields are added to the context of every event, like the ones of WithServiceInfo
+	Fields map[string]any
+
+	Console *WithConsoleParts
+
+	BuildInfoLog bool
 }
 
 type logOption interface {
 	applyTo(*logOptions) error
 }
 
+// StdoutIsTerminal reports whether stdout is a terminal, InitZeroLog disables colors when it is not.
+// It can be replaced, e.g. in tests.
+var StdoutIsTerminal = func() bool {
+	return isTerminal(os.Stdout.Fd())
+}
+
+// WithAutoFormat is a log option to write JSON to stdout, as WithJSONStdout, when stdout is not a terminal
+type WithAutoFormat struct {
+}
+
+func (w WithAutoFormat) applyTo(o *logOptions) error {
+	o.AutoFormat = true
+	return nil
+}
+
+// WithTimeLocation is a log option to write the timestamps in Location instead of the local time zone
+type WithTimeLocation struct {
+	Location *time.Location
+}
+
+func (w WithTimeLocation) applyTo(o *logOptions) error {
+	if w.Location == nil {
+		return fmt.Errorf("time location is nil")
+	}
+	o.TimeLocation = w.Location
+	return nil
+}
+
+// WithUTC is a log option to write the timestamps in UTC
+type WithUTC struct {
+}
+
+func (w WithUTC) applyTo(o *logOptions) error {
+	o.TimeLocation = time.UTC
+	return nil
+}
+
+// WithServiceInfo is a log option to add the service, version, host and pid fields to every event,
+// to tell apart the logs of several instances. Empty fields are omitted, as is the host if it can't be resolved.
+type WithServiceInfo struct {
+	Name        string
+	Version     string
+	IncludeHost bool
+	IncludePID  bool
+}
+
+func (w WithServiceInfo) applyTo(o *logOptions) error {
+	if o.Fields == nil {
+		o.Fields = map[string]any{}
+	}
+	if w.Name != "" {
+		o.Fields["service"] = w.Name
+	}
+	if w.Version != "" {
+		o.Fields["version"] = w.Version
+	}
+	if w.IncludeHost {
+		if host, err := os.Hostname(); err == nil {
+			o.Fields["host"] = host
+		}
+	}
+	if w.IncludePID {
+		o.Fields["pid"] = os.Getpid()
+	}
+	return nil
+}
+
+// consoleParts are the part names of zerolog.ConsoleWriter
+var consoleParts = []string{
+	zerolog.TimestampFieldName,
+	zerolog.LevelFieldName,
+	zerolog.CallerFieldName,
+	zerolog.MessageFieldName,
+}
+
+// WithConsoleParts is a log option to customize the console output on stdout.
+// Order and Exclude are part names of zerolog.ConsoleWriter: "time", "level", "caller" and "message".
+// FieldsExclude are the names of the fields not to print, like noisy structured fields.
+type WithConsoleParts struct {
+	Order         []string
+	Exclude       []string
+	FieldsExclude []string
+}
+
+func (w WithConsoleParts) applyTo(o *logOptions) error {
+	for _, part := range append(append([]string{}, w.Order...), w.Exclude...) {
+		if !slices.Contains(consoleParts, part) {
+			return fmt.Errorf("unknown console part %q, expected one of %v", part, consoleParts)
+		}
+	}
+	o.Console = &w
+	return nil
+}
+
+// newConsoleWriter returns the console writer on stdout of the logger
+func newConsoleWriter(opt *logOptions) zerolog.ConsoleWriter {
+	w := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: "2006-01-02 15:04:05.000", TimeLocation: opt.TimeLocation, NoColor: opt.NoColor}
+	if opt.Console != nil {
+		w.PartsOrder = opt.Console.Order
+		w.PartsExclude = opt.Console.Exclude
+		w.FieldsExclude = opt.Console.FieldsExclude
+	}
+	return w
+}
+
+// WithNoColor is a log option to disable the colors of the console output.
+// Colors are also disabled when stdout is not a terminal without WithColor, or when the NO_COLOR environment variable
+// is set.
 type WithNoColor struct {
 }
 
@@ -27,6 +183,18 @@ func (w WithNoColor) applyTo(o *logOptions) error {
 	return nil
 }
 
+// WithColor is a log option to keep the colors of the console output when stdout is not a terminal, like in CI logs
+// rendering them. WithNoColor and the NO_COLOR environment variable take precedence.
+type WithColor struct {
+}
+
+func (w WithColor) applyTo(o *logOptions) error {
+	o.Color = true
+	return nil
+}
+
+// WithLogger is a log option to install this logger instead of building one. Its sampler is replaced by the runtime
+// level check, so set it with WithSampling.
 type WithLogger struct {
 	Logger *zerolog.Logger
 }
@@ -36,11 +204,201 @@ func (w WithLogger) applyTo(o *logOptions) error {
 	return nil
 }
 
+// WithLevel is a log option to set the minimum level of the logger, Debug by default for the console logger.
+// It applies to the logger built by the other options, like WithProduction.
+type WithLevel zerolog.Level
+
+func (w WithLevel) applyTo(o *logOptions) error {
+	level := zerolog.Level(w)
+	o.Level = &level
+	return nil
+}
+
+// WithLevelName is like WithLevel, with a level name parsed by zerolog.ParseLevel, like "info"
+type WithLevelName string
+
+func (w WithLevelName) applyTo(o *logOptions) error {
+	level, err := zerolog.ParseLevel(string(w))
+	if err != nil {
+		return err
+	}
+	o.Level = &level
+	return nil
+}
+
+// WithSampling is a log option to sample the events at or below MaxLevel, Debug by default,
+// so events above, like Warn and Error, are never sampled away
+type WithSampling struct {
+	Sampler  zerolog.Sampler
+	MaxLevel zerolog.Level
+}
+
+func (w WithSampling) applyTo(o *logOptions) error {
+	if w.Sampler == nil {
+		return fmt.Errorf("sampler is nil")
+	}
+	o.Sampler = levelThresholdSampler{sampler: w.Sampler, maxLevel: w.MaxLevel}
+	return nil
+}
+
+// WithBurstSampling is a log option to let Burst events at or below MaxLevel, Debug by default, pass per Per,
+// then one every ThenEvery events, or none if ThenEvery is 0
+type WithBurstSampling struct {
+	Burst     int
+	Per       time.Duration
+	ThenEvery int
+	MaxLevel  zerolog.Level
+}
+
+func (w WithBurstSampling) applyTo(o *logOptions) error {
+	if w.Burst < 0 || w.ThenEvery < 0 {
+		return fmt.Errorf("invalid burst sampling: burst %d, then every %d", w.Burst, w.ThenEvery)
+	}
+	sampler := &zerolog.BurstSampler{Burst: uint32(w.Burst), Period: w.Per}
+	if w.ThenEvery > 0 {
+		sampler.NextSampler = &zerolog.BasicSampler{N: uint32(w.ThenEvery)}
+	}
+	o.Sampler = levelThresholdSampler{sampler: sampler, maxLevel: w.MaxLevel}
+	return nil
+}
+
+// levelThresholdSampler applies sampler to the events at or below maxLevel, and keeps the others
+type levelThresholdSampler struct {
+	sampler  zerolog.Sampler
+	maxLevel zerolog.Level
+}
+
+func (s levelThresholdSampler) Sample(lvl zerolog.Level) bool {
+	if lvl > s.maxLevel {
+		return true
+	}
+	return s.sampler.Sample(lvl)
+}
+
 // WithProduction is a log option, which is aimed to be used in production environment.
 type WithProduction struct {
 	DirLog   string
-	FileName string
-	Append   bool // Append to existing log file, if false, it will overwrite the existing log file.
+	FileName string // Name of the log file without extension, takes precedence over FileNamePattern.
+	// FileNamePattern is the name of the log file without extension, with the tokens {time} for TimeStrSec(),
+	// {pid} for the process id and {name} for the program name, "{time}" by default.
+	FileNamePattern string
+	// SymlinkLatest maintains a latest.jsonl symlink in DirLog to the active log file, or a copy made at init
+	// where symlinks are not supported. Rotation keeps the name of the active file, so the link stays valid.
+	SymlinkLatest bool
+	Append        bool // Append to existing log file, if false, it will overwrite the existing log file.
+
+	// Rotation of the log file, see RotatingFile. All zero means no rotation.
+	MaxSizeMB  int  // Rotate the log file when it would exceed this size.
+	MaxAgeDays int  // Remove log files older than this, on rotation and by the retention sweep.
+	MaxBackups int  // Keep at most this number of rotated log files, on rotation.
+	Compress   bool // Gzip rotated log files.
+
+	// Retention of the log files in DirLog, see PruneLogs, which also considers the files named by FileName or
+	// FileNamePattern. It runs at init, then hourly, if any of MaxAgeDays, MaxTotalSizeMB and CompressAfterDays is set.
+	MaxTotalSizeMB    int // Remove the oldest log files beyond this total size.
+	CompressAfterDays int // Gzip log files older than this.
+
+	// SplitErrorFile also writes the Warn and above events to <name>.error.log next to the log file,
+	// with the same rotation.
+	SplitErrorFile bool
+}
+
+// logFiles are the paths of the log files opened by the WithProduction options of the installed logger
+var logFiles atomic.Value
+
+// ActiveLogFile returns the path of the log file written by the logger of the last WithProduction,
+// or "" if none was applied. The error file of SplitErrorFile is not returned.
+func ActiveLogFile() string {
+	files, _ := logFiles.Load().([]string)
+	for i := len(files) - 1; i >= 0; i-- {
+		if !strings.HasSuffix(files[i], ".error.log") {
+			return files[i]
+		}
+	}
+	return ""
+}
+
+// LogFilePaths returns the paths of the log files of the installed logger, the active files of WithProduction
+// followed by their rotated backups, newest first. It is empty before InitZeroLog.
+func LogFilePaths() []string {
+	files, _ := logFiles.Load().([]string)
+	paths := append([]string{}, files...)
+	for _, f := range files {
+		backups, err := rotatedFiles(f)
+		if err != nil {
+			continue
+		}
+		for _, b := range backups {
+			paths = append(paths, b.path)
+		}
+	}
+	return paths
+}
+
+// linkLatest replaces link with a symlink to target, or with a copy of target if symlinks are not supported
+func linkLatest(target, link string) error {
+	rel, err := filepath.Rel(filepath.Dir(link), target)
+	if err != nil {
+		return err
+	}
+
+	// replace the link atomically, so it never is missing for a reader
+	tmp := link + ".tmp"
+	os.Remove(tmp)
+	if err := os.Symlink(rel, tmp); err != nil {
+		Logger.Debug().Err(err).Str("link", link).Msg("Failed to create symlink, copying instead")
+		return CopyFile(target, link)
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// logPruneInterval is the interval of the retention sweep of WithProduction
+const logPruneInterval = time.Hour
+
+// logPruner is the retention sweep of WithProduction, running PruneLogs at start then every logPruneInterval
+// until Close, on the log files matching pattern
+type logPruner struct {
+	dir     string
+	policy  PrunePolicy
+	pattern *regexp.Regexp
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newLogPruner(dir string, policy PrunePolicy, pattern *regexp.Regexp) *logPruner {
+	return &logPruner{dir: dir, policy: policy, pattern: pattern, stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+func (p *logPruner) start() {
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(logPruneInterval)
+		defer ticker.Stop()
+		for {
+			if _, err := pruneLogs(p.dir, p.policy, p.pattern); err != nil {
+				Logger.Warn().Err(err).Str("dir", p.dir).Msg("Failed to prune log files")
+			}
+			select {
+			case <-ticker.C:
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the sweep, and waits for a running PruneLogs
+func (p *logPruner) Close() error {
+	p.once.Do(func() {
+		close(p.stop)
+		<-p.done
+	})
+	return nil
 }
 
 func (w WithProduction) applyTo(o *logOptions) error {
@@ -53,15 +411,30 @@ func (w WithProduction) applyTo(o *logOptions) error {
 		return err
 	}
 
-	fileName := w.FileName
+	// namePattern matches the file names of the pattern, for the retention sweep
+	fileName, namePattern := w.FileName, regexp.QuoteMeta(w.FileName)
 	if fileName == "" {
-		fileName = TimeStrSec()
+		pattern := w.FileNamePattern
+		if pattern == "" {
+			pattern = "{time}"
+		}
+		program := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+		fileName = strings.NewReplacer(
+			"{time}", TimeStrSec(),
+			"{pid}", strconv.Itoa(os.Getpid()),
+			"{name}", program,
+		).Replace(pattern)
+		namePattern = strings.NewReplacer(
+			`\{time\}`, `\d{8}\.\d{6}`,
+			`\{pid\}`, `\d+`,
+			`\{name\}`, regexp.QuoteMeta(program),
+		).Replace(regexp.QuoteMeta(pattern))
 	}
 
 	logFilePath := fmt.Sprintf("%s/%v.jsonl", w.DirLog, fileName)
 
 	// Check whether the file valid
-	checkFile := func() error {
+	checkFile := func(logFilePath string) error {
 		fs, err := os.Stat(logFilePath)
 		if err != nil {
 			if !os.IsNotExist(err) {
@@ -83,51 +456,317 @@ func (w WithProduction) applyTo(o *logOptions) error {
 		return nil
 	}
 
-	if err = checkFile(); err != nil {
+	if err = checkFile(logFilePath); err != nil {
 		return err
 	}
 
-	logFile, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	newLogFile := func(path string) (*RotatingFile, error) {
+		logFile := &RotatingFile{
+			Filename:   path,
+			MaxSize:    int64(w.MaxSizeMB) * 1024 * 1024,
+			MaxAge:     time.Duration(w.MaxAgeDays) * 24 * time.Hour,
+			MaxBackups: w.MaxBackups,
+			Compress:   w.Compress,
+		}
+		// open the file now, to report errors through the option
+		if _, err := logFile.Write(nil); err != nil {
+			return nil, err
+		}
+		o.closers = append(o.closers, logFile)
+		return logFile, nil
+	}
+
+	logFile, err := newLogFile(logFilePath)
 	if err != nil {
 		return err
 	}
 
-	multiWriter := zerolog.MultiLevelWriter(
-		zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: "2006-01-02 15:04:05.000"},
-		logFile,
-	)
+	if w.SymlinkLatest {
+		if err := linkLatest(logFilePath, filepath.Join(w.DirLog, "latest.jsonl")); err != nil {
+			return err
+		}
+	}
+	o.LogFiles = append(o.LogFiles, logFilePath)
+
+	policy := PrunePolicy{
+		MaxAge:        time.Duration(w.MaxAgeDays) * 24 * time.Hour,
+		MaxTotalSize:  int64(w.MaxTotalSizeMB) * 1024 * 1024,
+		CompressAfter: time.Duration(w.CompressAfterDays) * 24 * time.Hour,
+	}
+	if policy != (PrunePolicy{}) {
+		o.pruners = append(o.pruners, newLogPruner(w.DirLog, policy, newLogFilePattern(namePattern)))
+	}
+
+	o.Writers = append(o.Writers, logFile)
+
+	if w.SplitErrorFile {
+		errorFilePath := fmt.Sprintf("%s/%v.error.log", w.DirLog, fileName)
+		if err = checkFile(errorFilePath); err != nil {
+			return err
+		}
+		errorFile, err := newLogFile(errorFilePath)
+		if err != nil {
+			return err
+		}
+		o.LogFiles = append(o.LogFiles, errorFilePath)
+		o.Writers = append(o.Writers, &zerolog.FilteredLevelWriter{
+			Writer: zerolog.LevelWriterAdapter{Writer: errorFile},
+			Level:  zerolog.WarnLevel,
+		})
+	}
+	return nil
+}
+
+// WithJSONStdout is a log option to write raw JSON with timestamp and caller to stdout, for log collectors.
+// Combined with WithProduction, the log files are written too, and stdout gets JSON instead of the console format.
+type WithJSONStdout struct {
+}
+
+func (w WithJSONStdout) applyTo(o *logOptions) error {
+	o.JSONStdout = true
+	return nil
+}
 
-	logger := zerolog.New(multiWriter).With().
-		Timestamp().
-		Caller().
-		Logger()
+// WithK8s is a log option preset for containers: WithJSONStdout, with RFC 3339 timestamps
+type WithK8s struct {
+}
 
-	o.Logger = &logger
+func (w WithK8s) applyTo(o *logOptions) error {
+	o.JSONStdout = true
+	o.TimeFormat = time.RFC3339Nano
 	return nil
 }
 
+// logLevel is the level of the loggers installed by InitZeroLog, it can be changed at runtime
+var logLevel atomic.Int32
+
+// configuredLevel is the level configured by the last InitZeroLog, restored by WithSIGHUPLevelToggle
+var configuredLevel atomic.Int32
+
+// stopSIGHUPToggle stops the WithSIGHUPLevelToggle of the installed loggers, if any
+var (
+	sighupMu         sync.Mutex
+	stopSIGHUPToggle func()
+)
+
+// SetLogLevel sets the minimum level of the loggers installed by InitZeroLog, and of their children, at runtime
+func SetLogLevel(level zerolog.Level) {
+	logLevel.Store(int32(level))
+}
+
+// GetLogLevel returns the minimum level of the loggers installed by InitZeroLog
+func GetLogLevel() zerolog.Level {
+	return zerolog.Level(logLevel.Load())
+}
+
+// levelSampler drops the events below GetLogLevel before they are built, then applies next, if any,
+// so the dropped events don't count in the sampling of WithSampling
+type levelSampler struct {
+	next zerolog.Sampler
+}
+
+func (s levelSampler) Sample(lvl zerolog.Level) bool {
+	if lvl < GetLogLevel() {
+		return false
+	}
+	return s.next == nil || s.next.Sample(lvl)
+}
+
+// WithSIGHUPLevelToggle is a log option to switch the level between the configured one and Debug on SIGHUP.
+// It does nothing on the platforms without SIGHUP, like Windows.
+type WithSIGHUPLevelToggle struct {
+}
+
+func (w WithSIGHUPLevelToggle) applyTo(o *logOptions) error {
+	o.SIGHUPToggle = true
+	return nil
+}
+
+// LoggerIntoContext returns a copy of ctx carrying l, for LoggerFromContext. A nil ctx means context.Background().
+func LoggerIntoContext(ctx context.Context, l zerolog.Logger) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return l.WithContext(ctx)
+}
+
+// LoggerFromContext returns the logger carried by ctx, or the package Logger if none. A nil ctx is allowed.
+func LoggerFromContext(ctx context.Context) *zerolog.Logger {
+	if ctx == nil {
+		return &Logger
+	}
+	if l := zerolog.Ctx(ctx); l.GetLevel() != zerolog.Disabled {
+		return l
+	}
+	return &Logger
+}
+
+// InitZeroLog builds a logger from the options and installs it as the global loggers.
+// An option failing to apply is logged and skipped, see InitZeroLogE to handle the errors.
 func InitZeroLog(options ...logOption) {
+	logger, opt, errs := buildZeroLog(options)
+	for _, err := range errs {
+		Logger.Error().Err(err).Msg("Failed to apply log option")
+	}
+	installZeroLog(logger, opt)
+}
+
+// InitZeroLogE is like InitZeroLog, but returns the joined errors of the options failing to apply,
+// in which case the global loggers are left unchanged. It also returns the built logger, at the configured level:
+// unlike the global loggers, it can't go below this level with SetLogLevel.
+func InitZeroLogE(options ...logOption) (zerolog.Logger, error) {
+	logger, opt, errs := buildZeroLog(options)
+	if len(errs) > 0 {
+		for _, w := range opt.asyncWriters {
+			w.Close()
+		}
+		for _, c := range opt.closers {
+			c.Close()
+		}
+		return logger.Level(opt.resolvedLevel), errors.Join(errs...)
+	}
+	installZeroLog(logger, opt)
+	return logger.Level(opt.resolvedLevel), nil
+}
+
+// buildZeroLog applies the options and builds the logger, skipping the options failing to apply
+func buildZeroLog(options []logOption) (zerolog.Logger, *logOptions, []error) {
 	opt := &logOptions{
 		NoColor: false,
 	}
 
+	var errs []error
 	for _, o := range options {
-		err := o.applyTo(opt)
-		if err != nil {
-			Logger.Error().Err(err).Msg("Failed to apply log option")
+		if err := o.applyTo(opt); err != nil {
+			errs = append(errs, fmt.Errorf("failed to apply log option %T: %w", o, err))
 		}
 	}
 
-	zerolog.TimeFieldFormat = "2006-01-02 15:04:05.000"
+	// plain output when stdout is not a terminal unless colors were enabled explicitly, or when NO_COLOR is set
+	tty := StdoutIsTerminal()
+	if (!tty && !opt.Color) || os.Getenv("NO_COLOR") != "" {
+		opt.NoColor = true
+	}
+	if opt.AutoFormat && !tty {
+		opt.JSONStdout = true
+	}
 
 	var logger zerolog.Logger
-	if opt.Logger == nil {
-		logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: "2006-01-02 15:04:05.000", NoColor: opt.NoColor}).Level(zerolog.DebugLevel).With().Caller().Logger()
-	} else {
+	switch {
+	case opt.Logger != nil:
 		logger = *opt.Logger
+	case opt.JSONStdout || len(opt.Writers) > 0:
+		var stdout io.Writer = newConsoleWriter(opt)
+		if opt.JSONStdout {
+			stdout = os.Stdout
+		}
+		writers := []io.Writer{stdout}
+		for _, w := range opt.Writers {
+			if opt.Async != nil {
+				// the queue drops the levels, so filter them before it
+				fw, filtered := w.(*zerolog.FilteredLevelWriter)
+				if filtered {
+					w = fw.Writer
+				}
+				aw := newAsyncWriter(w, opt.Async)
+				opt.asyncWriters = append(opt.asyncWriters, aw)
+				w = aw
+				if filtered {
+					w = &zerolog.FilteredLevelWriter{Writer: aw, Level: fw.Level}
+				}
+			}
+			writers = append(writers, w)
+		}
+		var writer io.Writer = zerolog.MultiLevelWriter(writers...)
+		if opt.Redactor != nil {
+			writer = newRedactWriter(writer, opt.Redactor)
+		}
+		logger = zerolog.New(writer).With().
+			Timestamp().
+			Caller().
+			Logger()
+	default:
+		var writer io.Writer = newConsoleWriter(opt)
+		if opt.Redactor != nil {
+			writer = newRedactWriter(writer, opt.Redactor)
+		}
+		// a fresh logger, not log.Output, which would keep the hooks, fields and sampler of the previous one
+		logger = zerolog.New(writer).Level(zerolog.DebugLevel).With().Timestamp().Caller().Logger()
+	}
+	if len(opt.Fields) > 0 {
+		logger = logger.With().Fields(opt.Fields).Logger()
+	}
+	if opt.Level != nil {
+		logger = logger.Level(*opt.Level)
+	}
+	for _, h := range opt.Hooks {
+		logger = logger.Hook(h)
+	}
+	// the level is checked by levelSampler instead, before the events are built, so it can be changed at runtime
+	// by SetLogLevel for the child loggers too. Sample the final logger, so that Logger and CommandLogger inherit it.
+	opt.resolvedLevel = logger.GetLevel()
+	logger = logger.Level(zerolog.TraceLevel).Sample(levelSampler{next: opt.Sampler})
+	return logger, opt, errs
+}
+
+// installZeroLog sets logger as the global loggers
+func installZeroLog(logger zerolog.Logger, opt *logOptions) {
+	zerolog.TimeFieldFormat = "2006-01-02 15:04:05.000"
+	if opt.TimeFormat != "" {
+		zerolog.TimeFieldFormat = opt.TimeFormat
+	}
+	zerolog.TimestampFunc = time.Now
+	if loc := opt.TimeLocation; loc != nil {
+		zerolog.TimestampFunc = func() time.Time {
+			return time.Now().In(loc)
+		}
+	}
+
+	SetLogLevel(opt.resolvedLevel)
+	configuredLevel.Store(int32(opt.resolvedLevel))
+	// make the caller paths relative to the module root, in JSON and console outputs
+	dirBuild := opt.DirBuild
+	if dirBuild == "" {
+		dirBuild = detectDirBuild()
+	}
+	module := mainModulePath()
+	zerolog.CallerMarshalFunc = func(pc uintptr, file string, line int) string {
+		return trimCallerPath(file, dirBuild, module) + ":" + strconv.Itoa(line)
 	}
 
 	log.Logger = logger
 	Logger = logger.With().Str("module", "goutils").Logger()
 	CommandLogger = logger.With().Str("module", "goutils.command").Logger()
+
+	closers := opt.closers
+	for _, p := range opt.pruners {
+		p.start()
+		closers = append(closers, p)
+	}
+	setLogOutputs(opt.asyncWriters, closers)
+
+	sighupMu.Lock()
+	if stopSIGHUPToggle != nil {
+		stopSIGHUPToggle()
+		stopSIGHUPToggle = nil
+	}
+	if opt.SIGHUPToggle {
+		stopSIGHUPToggle = toggleLevelOnSIGHUP(Logger)
+	}
+	sighupMu.Unlock()
+
+	logFiles.Store(opt.LogFiles)
+	if len(opt.LogFiles) > 0 {
+		Logger.Info().Strs("files", opt.LogFiles).Msg("Logging to files")
+	}
+	if opt.BuildInfoLog {
+		info := BuildInfo()
+		Logger.Info().
+			Str("version", info.ModuleVersion).
+			Str("revision", info.VCSRevision).
+			Str("vcs_time", info.VCSTime).
+			Bool("modified", info.Modified).
+			Str("go", info.GoVersion).
+			Msg("Build info")
+	}
 }
@@ -1,9 +1,20 @@
 package goutils_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
 
 	"github.com/117503445/goutils"
 )
@@ -18,3 +29,487 @@ func TestInitZeroLog(t *testing.T) {
 	goutils.InitZeroLog(goutils.WithProduction{DirLog: "./data/logs"})
 	log.Info().Msg("InitZeroLog WithProduction")
 }
+
+func TestInitZeroLogWithRotation(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+
+	// ~3MB of events, without flooding the console
+	stdout := os.Stdout
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	ast.NoError(err)
+	defer devNull.Close()
+	os.Stdout = devNull
+	goutils.InitZeroLog(goutils.WithProduction{DirLog: dir, FileName: "app", MaxSizeMB: 1, MaxBackups: 2})
+	os.Stdout = stdout
+	defer goutils.InitZeroLog()
+
+	event := strings.Repeat("x", 64*1024)
+	for i := 0; i < 48; i++ {
+		log.Info().Msg(event)
+	}
+	log.Info().Msg("newest")
+
+	// the backups are pruned in the background
+	ast.Eventually(func() bool {
+		matches, err := filepath.Glob(filepath.Join(dir, "app-*.jsonl"))
+		return err == nil && len(matches) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	lines, err := goutils.ReadLastNLines(filepath.Join(dir, "app.jsonl"), 1)
+	ast.NoError(err)
+	ast.Contains(lines[0], "newest")
+}
+
+func TestInitZeroLogWithLevel(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.InitZeroLog()
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	goutils.InitZeroLog(goutils.WithLogger{Logger: &logger}, goutils.WithLevelName("info"))
+	log.Debug().Msg("dropped")
+	log.Warn().Msg("kept")
+	ast.NotContains(buf.String(), "dropped")
+	ast.Contains(buf.String(), "kept")
+
+	buf.Reset()
+	goutils.InitZeroLog(goutils.WithLevel(zerolog.WarnLevel), goutils.WithLogger{Logger: &logger})
+	log.Info().Msg("dropped")
+	log.Error().Msg("kept")
+	ast.NotContains(buf.String(), "dropped")
+	ast.Contains(buf.String(), "kept")
+
+	// an invalid level is reported and ignored
+	buf.Reset()
+	goutils.InitZeroLog(goutils.WithLogger{Logger: &logger}, goutils.WithLevelName("verbose"))
+	ast.Contains(buf.String(), "Failed to apply log option")
+	log.Debug().Msg("kept")
+	ast.Contains(buf.String(), "kept")
+}
+
+func TestInitZeroLogSymlinkLatest(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.InitZeroLog()
+
+	dir := t.TempDir()
+	latest := filepath.Join(dir, "latest.jsonl")
+	pid := strconv.Itoa(os.Getpid())
+
+	goutils.InitZeroLog(goutils.WithProduction{DirLog: dir, FileNamePattern: "a-{pid}", SymlinkLatest: true})
+	log.Info().Msg("first")
+	ast.Equal(filepath.Join(dir, "a-"+pid+".jsonl"), goutils.ActiveLogFile())
+	target, err := os.Readlink(latest)
+	ast.NoError(err)
+	ast.Equal("a-"+pid+".jsonl", target)
+
+	goutils.InitZeroLog(goutils.WithProduction{DirLog: dir, FileNamePattern: "b-{pid}-{time}", SymlinkLatest: true})
+	log.Info().Msg("second")
+	ast.True(strings.HasPrefix(filepath.Base(goutils.ActiveLogFile()), "b-"+pid+"-"))
+	target, err = os.Readlink(latest)
+	ast.NoError(err)
+	ast.Equal(filepath.Base(goutils.ActiveLogFile()), target)
+
+	content, err := goutils.ReadText(latest)
+	ast.NoError(err)
+	ast.Contains(content, "second")
+	ast.NotContains(content, "first")
+}
+
+// captureStdout returns what is written to the stdout captured by the logger of init, while fn runs
+func captureStdout(t *testing.T, init func(), fn func()) string {
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	init()
+	os.Stdout = stdout
+
+	fn()
+	w.Close()
+	out, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	return string(out)
+}
+
+func TestInitZeroLogWithJSONStdout(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.InitZeroLog()
+
+	out := captureStdout(t, func() {
+		goutils.InitZeroLog(goutils.WithJSONStdout{}, goutils.WithLevel(zerolog.InfoLevel))
+	}, func() {
+		log.Debug().Msg("dropped")
+		log.Info().Str("key", "value").Msg("hello")
+	})
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	ast.Len(lines, 1)
+	var event map[string]string
+	ast.NoError(json.Unmarshal([]byte(lines[0]), &event))
+	ast.Equal("info", event["level"])
+	ast.Equal("hello", event["message"])
+	ast.Equal("value", event["key"])
+	ast.Contains(event["caller"], "logger_test.go")
+	_, err := time.ParseInLocation("2006-01-02 15:04:05.000", event["time"], time.Local)
+	ast.NoError(err)
+
+	// with WithProduction, stdout gets JSON and the file too
+	dir := t.TempDir()
+	out = captureStdout(t, func() {
+		goutils.InitZeroLog(goutils.WithK8s{}, goutils.WithProduction{DirLog: dir, FileName: "app"})
+	}, func() {
+		log.Warn().Msg("both")
+	})
+	event = nil
+	lines = strings.Split(strings.TrimSpace(out), "\n")
+	ast.Len(lines, 2)
+	ast.NoError(json.Unmarshal([]byte(lines[1]), &event))
+	ast.Equal("both", event["message"])
+	_, err = time.Parse(time.RFC3339Nano, event["time"])
+	ast.NoError(err)
+	content, err := goutils.ReadText(filepath.Join(dir, "app.jsonl"))
+	ast.NoError(err)
+	ast.Equal(strings.TrimSpace(out), strings.TrimSpace(content))
+}
+
+func TestInitZeroLogWithSampling(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.InitZeroLog()
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	goutils.InitZeroLog(goutils.WithLogger{Logger: &logger}, goutils.WithBurstSampling{Burst: 10, Per: time.Hour, ThenEvery: 100})
+	for i := 0; i < 1000; i++ {
+		log.Debug().Int("i", i).Msg("hot loop")
+		if i%100 == 0 {
+			log.Error().Int("i", i).Msg("failure")
+		}
+	}
+	// 10 in the burst, then 1 in 100 of the 990 others
+	ast.InDelta(20, strings.Count(buf.String(), "hot loop"), 1)
+	ast.Equal(10, strings.Count(buf.String(), "failure"))
+
+	buf.Reset()
+	goutils.InitZeroLog(goutils.WithLogger{Logger: &logger}, goutils.WithSampling{Sampler: &zerolog.BasicSampler{N: 10}, MaxLevel: zerolog.InfoLevel})
+	for i := 0; i < 100; i++ {
+		goutils.CommandLogger.Info().Msg("info event")
+		goutils.CommandLogger.Warn().Msg("warn event")
+	}
+	ast.Equal(10, strings.Count(buf.String(), "info event"))
+	ast.Equal(100, strings.Count(buf.String(), "warn event"))
+
+	// the events below the level don't use up the burst
+	buf.Reset()
+	goutils.InitZeroLog(goutils.WithLogger{Logger: &logger}, goutils.WithLevel(zerolog.InfoLevel),
+		goutils.WithBurstSampling{Burst: 5, Per: time.Hour, MaxLevel: zerolog.InfoLevel})
+	for range 10 {
+		log.Debug().Msg("debug event")
+	}
+	for range 5 {
+		log.Info().Msg("info event")
+	}
+	ast.Equal(0, strings.Count(buf.String(), "debug event"))
+	ast.Equal(5, strings.Count(buf.String(), "info event"))
+}
+
+func TestInitZeroLogWithRetention(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.InitZeroLog()
+
+	dir := t.TempDir()
+	old := filepath.Join(dir, "20240901.221219.jsonl")
+	ast.NoError(goutils.WriteText(old, "{}\n"))
+	ast.NoError(os.Chtimes(old, time.Now().Add(-72*time.Hour), time.Now().Add(-72*time.Hour)))
+	newer := filepath.Join(dir, "20240902.221219.jsonl")
+	ast.NoError(goutils.WriteText(newer, "{}\n"))
+
+	// the sweep starts once the logger is installed, and a re-init or CloseLogs stops it
+	before := runtime.NumGoroutine()
+	_, err := goutils.InitZeroLogE(goutils.WithProduction{DirLog: dir, FileName: "app", MaxAgeDays: 1}, goutils.WithLevelName("verbose"))
+	ast.Error(err)
+	ast.FileExists(old)
+	for range 3 {
+		goutils.InitZeroLog(goutils.WithProduction{DirLog: dir, FileName: "app", Append: true, MaxAgeDays: 1})
+	}
+	ast.Eventually(func() bool { return !goutils.PathExists(old) }, 5*time.Second, 10*time.Millisecond)
+	ast.NoError(goutils.CloseLogs())
+	ast.LessOrEqual(runtime.NumGoroutine(), before)
+	ast.FileExists(newer)
+
+	// the files named by FileNamePattern are swept too, and only them
+	program := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	oldNamed := filepath.Join(dir, program+"-123.jsonl")
+	other := filepath.Join(dir, "notes.jsonl")
+	for _, path := range []string{oldNamed, other} {
+		ast.NoError(goutils.WriteText(path, "{}\n"))
+		ast.NoError(os.Chtimes(path, time.Now().Add(-72*time.Hour), time.Now().Add(-72*time.Hour)))
+	}
+	goutils.InitZeroLog(goutils.WithProduction{DirLog: dir, FileNamePattern: "{name}-{pid}", MaxAgeDays: 1})
+	ast.Eventually(func() bool { return !goutils.PathExists(oldNamed) }, 5*time.Second, 10*time.Millisecond)
+	ast.NoError(goutils.CloseLogs())
+	ast.FileExists(other)
+	ast.FileExists(goutils.ActiveLogFile())
+}
+
+func TestInitZeroLogE(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.InitZeroLog()
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	_, err := goutils.InitZeroLogE(goutils.WithLogger{Logger: &logger})
+	ast.NoError(err)
+
+	// a log dir which is a file fails, and the global logger is unchanged
+	notDir := filepath.Join(t.TempDir(), "file")
+	ast.NoError(goutils.WriteText(notDir, ""))
+	_, err = goutils.InitZeroLogE(goutils.WithProduction{DirLog: notDir}, goutils.WithLevelName("verbose"))
+	ast.Error(err)
+	ast.Contains(err.Error(), "WithProduction")
+	ast.Contains(err.Error(), "WithLevelName")
+
+	log.Info().Msg("still here")
+	ast.Contains(buf.String(), "still here")
+
+	built, err := goutils.InitZeroLogE(goutils.WithLevel(zerolog.WarnLevel), goutils.WithLogger{Logger: &logger})
+	ast.NoError(err)
+	ast.Equal(zerolog.WarnLevel, built.GetLevel())
+	ast.Equal(zerolog.WarnLevel, goutils.GetLogLevel())
+	built.Info().Msg("dropped")
+	log.Info().Msg("dropped")
+	ast.NotContains(buf.String(), "dropped")
+}
+
+func TestInitZeroLogCallerPath(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.InitZeroLog()
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf).With().Caller().Logger()
+	goutils.InitZeroLog(goutils.WithLogger{Logger: &logger})
+	log.Info().Msg("hello")
+	var event map[string]string
+	ast.NoError(json.Unmarshal(buf.Bytes(), &event))
+	ast.True(strings.HasPrefix(event["caller"], "logger_test.go:"), event["caller"])
+
+	// an explicit DirBuild which does not match keeps absolute paths
+	buf.Reset()
+	goutils.InitZeroLog(goutils.WithLogger{Logger: &logger}, goutils.WithDirBuild("/nonexistent"))
+	log.Info().Msg("hello")
+	event = nil
+	ast.NoError(json.Unmarshal(buf.Bytes(), &event))
+	ast.True(filepath.IsAbs(event["caller"]), event["caller"])
+}
+
+func TestInitZeroLogTerminalDetection(t *testing.T) {
+	ast := assert.New(t)
+	isTerminal := goutils.StdoutIsTerminal
+	defer func() {
+		goutils.StdoutIsTerminal = isTerminal
+		goutils.InitZeroLog()
+	}()
+
+	logWith := func(tty bool, init func()) string {
+		goutils.StdoutIsTerminal = func() bool { return tty }
+		return captureStdout(t, init, func() {
+			log.Info().Msg("hello")
+		})
+	}
+
+	out := logWith(true, func() { goutils.InitZeroLog() })
+	ast.Contains(out, "\x1b[")
+	out = logWith(true, func() { goutils.InitZeroLog(goutils.WithAutoFormat{}) })
+	ast.Contains(out, "\x1b[")
+
+	// explicit options win over the detection
+	out = logWith(true, func() { goutils.InitZeroLog(goutils.WithNoColor{}) })
+	ast.NotContains(out, "\x1b[")
+	ast.Contains(out, "hello")
+
+	out = logWith(false, func() { goutils.InitZeroLog() })
+	ast.NotContains(out, "\x1b[")
+	ast.False(strings.HasPrefix(out, "{"))
+
+	out = logWith(false, func() { goutils.InitZeroLog(goutils.WithAutoFormat{}) })
+	ast.True(json.Valid([]byte(out)), out)
+
+	// colors can be forced, under CI
+	out = logWith(false, func() { goutils.InitZeroLog(goutils.WithColor{}) })
+	ast.Contains(out, "\x1b[")
+	out = logWith(false, func() { goutils.InitZeroLog(goutils.WithColor{}, goutils.WithNoColor{}) })
+	ast.NotContains(out, "\x1b[")
+
+	t.Setenv("NO_COLOR", "1")
+	out = logWith(true, func() { goutils.InitZeroLog() })
+	ast.NotContains(out, "\x1b[")
+	out = logWith(false, func() { goutils.InitZeroLog(goutils.WithColor{}) })
+	ast.NotContains(out, "\x1b[")
+}
+
+func TestLogFilePaths(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.InitZeroLog()
+
+	goutils.InitZeroLog()
+	ast.Empty(goutils.LogFilePaths())
+
+	dir := t.TempDir()
+	goutils.InitZeroLog(goutils.WithProduction{DirLog: dir, FileName: "app"}, goutils.WithProduction{DirLog: dir, FileName: "audit"})
+	ast.Equal([]string{filepath.Join(dir, "app.jsonl"), filepath.Join(dir, "audit.jsonl")}, goutils.LogFilePaths())
+	for _, path := range goutils.LogFilePaths() {
+		ast.FileExists(path)
+	}
+
+	// the initialization event lists the files
+	content, err := goutils.ReadText(filepath.Join(dir, "app.jsonl"))
+	ast.NoError(err)
+	ast.Contains(content, `"files":["`+filepath.Join(dir, "app.jsonl"))
+
+	// rotated files are included
+	dir = t.TempDir()
+	goutils.InitZeroLog(goutils.WithProduction{DirLog: dir, FileName: "app", MaxSizeMB: 1})
+	paths := goutils.LogFilePaths()
+	ast.Len(paths, 1)
+	ast.NoError(goutils.WriteText(filepath.Join(dir, "app-20240915.221219.123.jsonl"), ""))
+	ast.Equal([]string{filepath.Join(dir, "app.jsonl"), filepath.Join(dir, "app-20240915.221219.123.jsonl")}, goutils.LogFilePaths())
+}
+
+func TestInitZeroLogWithTimeLocation(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.InitZeroLog()
+
+	// an offset unlikely to be the local one
+	loc := time.FixedZone("UTC+13", 13*60*60)
+	for _, tc := range []struct {
+		init func()
+		loc  *time.Location
+	}{
+		{func() { goutils.InitZeroLog(goutils.WithJSONStdout{}, goutils.WithTimeLocation{Location: loc}) }, loc},
+		{func() { goutils.InitZeroLog(goutils.WithJSONStdout{}, goutils.WithUTC{}) }, time.UTC},
+	} {
+		out := captureStdout(t, tc.init, func() {
+			log.Info().Msg("hello")
+		})
+		var event map[string]string
+		ast.NoError(json.Unmarshal([]byte(out), &event))
+		ts, err := time.ParseInLocation("2006-01-02 15:04:05.000", event["time"], tc.loc)
+		ast.NoError(err)
+		ast.WithinDuration(time.Now(), ts, 5*time.Second)
+	}
+
+	// the console output uses the same location
+	out := captureStdout(t, func() {
+		goutils.InitZeroLog(goutils.WithNoColor{}, goutils.WithTimeLocation{Location: loc})
+	}, func() {
+		log.Info().Msg("hello")
+	})
+	ast.True(strings.HasPrefix(out, time.Now().In(loc).Format("2006-01-02 15")), out)
+}
+
+func TestInitZeroLogSplitErrorFile(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.InitZeroLog()
+
+	dir := t.TempDir()
+	captureStdout(t, func() {
+		goutils.InitZeroLog(goutils.WithProduction{DirLog: dir, FileName: "app", SplitErrorFile: true}, goutils.WithLevel(zerolog.DebugLevel))
+	}, func() {
+		log.Debug().Msg("debug event")
+		log.Info().Msg("info event")
+		log.Error().Msg("error event")
+	})
+
+	errorFile := filepath.Join(dir, "app.error.log")
+	ast.Equal([]string{filepath.Join(dir, "app.jsonl"), errorFile}, goutils.LogFilePaths())
+	ast.Equal(filepath.Join(dir, "app.jsonl"), goutils.ActiveLogFile())
+
+	content, err := goutils.ReadText(errorFile)
+	ast.NoError(err)
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+	ast.Len(lines, 1)
+	ast.Contains(lines[0], `"level":"error"`)
+	ast.Contains(lines[0], "error event")
+
+	// the combined file keeps everything
+	content, err = goutils.ReadText(filepath.Join(dir, "app.jsonl"))
+	ast.NoError(err)
+	for _, msg := range []string{"debug event", "info event", "error event"} {
+		ast.Contains(content, msg)
+	}
+
+	// the levels are kept through the async queue
+	dir = t.TempDir()
+	captureStdout(t, func() {
+		goutils.InitZeroLog(goutils.WithProduction{DirLog: dir, FileName: "app", SplitErrorFile: true}, goutils.WithAsyncWriter{})
+	}, func() {
+		log.Info().Msg("info event")
+		log.Warn().Msg("warn event")
+		goutils.FlushLogs()
+	})
+	content, err = goutils.ReadText(filepath.Join(dir, "app.error.log"))
+	ast.NoError(err)
+	ast.NotContains(content, "info event")
+	ast.Contains(content, "warn event")
+}
+
+func TestInitZeroLogWithServiceInfo(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.InitZeroLog()
+
+	out := captureStdout(t, func() {
+		goutils.InitZeroLog(goutils.WithJSONStdout{}, goutils.WithServiceInfo{Name: "api", Version: "1.2.3", IncludeHost: true, IncludePID: true})
+	}, func() {
+		goutils.Logger.Info().Msg("hello")
+	})
+	var event map[string]any
+	ast.NoError(json.Unmarshal([]byte(out), &event))
+	host, err := os.Hostname()
+	ast.NoError(err)
+	ast.Equal("api", event["service"])
+	ast.Equal("1.2.3", event["version"])
+	ast.Equal(host, event["host"])
+	ast.EqualValues(os.Getpid(), event["pid"])
+	ast.Equal("goutils", event["module"])
+
+	// only the requested fields are added
+	out = captureStdout(t, func() {
+		goutils.InitZeroLog(goutils.WithJSONStdout{}, goutils.WithServiceInfo{Name: "api"})
+	}, func() {
+		log.Info().Msg("hello")
+	})
+	event = nil
+	ast.NoError(json.Unmarshal([]byte(out), &event))
+	ast.Equal("api", event["service"])
+	ast.NotContains(event, "version")
+	ast.NotContains(event, "host")
+	ast.NotContains(event, "pid")
+}
+
+func TestInitZeroLogWithConsoleParts(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.InitZeroLog()
+
+	out := captureStdout(t, func() {
+		goutils.InitZeroLog(goutils.WithNoColor{})
+	}, func() {
+		log.Info().Msg("hello")
+	})
+	ast.Contains(out, ".go:")
+
+	out = captureStdout(t, func() {
+		goutils.InitZeroLog(goutils.WithNoColor{}, goutils.WithConsoleParts{
+			Order:         []string{"level", "message", "time"},
+			Exclude:       []string{"caller"},
+			FieldsExclude: []string{"noisy"},
+		})
+	}, func() {
+		log.Info().Str("noisy", "x").Str("kept", "y").Msg("hello")
+	})
+	ast.NotContains(out, ".go:")
+	ast.NotContains(out, "noisy")
+	ast.Contains(out, "kept=y")
+	ast.True(strings.HasPrefix(out, "INF hello"), out)
+
+	_, err := goutils.InitZeroLogE(goutils.WithConsoleParts{Exclude: []string{"caler"}})
+	ast.ErrorContains(err, `unknown console part "caler"`)
+}
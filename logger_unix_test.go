@@ -0,0 +1,30 @@
+//go:build linux || darwin
+
+package goutils_test
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestInitZeroLogWithSIGHUPLevelToggle(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.InitZeroLog()
+
+	// a re-init replaces the toggle, a single SIGHUP would toggle twice otherwise
+	goutils.InitZeroLog(goutils.WithLevel(zerolog.WarnLevel), goutils.WithSIGHUPLevelToggle{})
+	goutils.InitZeroLog(goutils.WithLevel(zerolog.WarnLevel), goutils.WithSIGHUPLevelToggle{})
+	ast.Equal(zerolog.WarnLevel, goutils.GetLogLevel())
+
+	ast.NoError(syscall.Kill(syscall.Getpid(), syscall.SIGHUP))
+	ast.Eventually(func() bool { return goutils.GetLogLevel() == zerolog.DebugLevel }, 5*time.Second, 10*time.Millisecond)
+
+	ast.NoError(syscall.Kill(syscall.Getpid(), syscall.SIGHUP))
+	ast.Eventually(func() bool { return goutils.GetLogLevel() == zerolog.WarnLevel }, 5*time.Second, 10*time.Millisecond)
+}
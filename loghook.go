@@ -0,0 +1,750 @@
+package goutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+)
+
+// HookOption is an option for NewAlertHook
+type HookOption interface {
+	applyToHook(*hookOptions) error
+}
+
+type hookOptions struct {
+	QueueSize         int
+	MinInterval       time.Duration
+	Title             string
+	RateLimit         int
+	RateLimitBehavior RateLimitBehavior
+	MaxSize           int
+	OversizePolicy    OversizePolicy
+	BeforeSend        []WithBeforeSend
+	OnResult          []WithOnResult
+	Retry             WithSendRetry
+	Fallback          *WithFallback
+	Keyword           string
+	KeywordPolicy     KeywordPolicy
+}
+
+// WithQueueSize is a hook option to set the number of alerts waiting to be sent, 100 by default.
+// Alerts beyond are dropped, and counted in the next sent alert.
+type WithQueueSize int
+
+func (w WithQueueSize) applyToHook(o *hookOptions) error {
+	if w <= 0 {
+		return fmt.Errorf("invalid queue size: %d", w)
+	}
+	o.QueueSize = int(w)
+	return nil
+}
+
+// WithMinInterval is a hook option to set the minimum interval between two sent alerts, 3s by default
+type WithMinInterval time.Duration
+
+func (w WithMinInterval) applyToHook(o *hookOptions) error {
+	if w < 0 {
+		return fmt.Errorf("invalid interval: %v", time.Duration(w))
+	}
+	o.MinInterval = time.Duration(w)
+	return nil
+}
+
+// ErrEmptyTitle is the error of a blank WithAlertTitle, as the alerting channels require a title
+var ErrEmptyTitle = errors.New("empty title")
+
+// WithAlertTitle is a hook option to set the title of the alerts, like the service name, "log alert" by default
+type WithAlertTitle string
+
+func (w WithAlertTitle) applyToHook(o *hookOptions) error {
+	if strings.TrimSpace(string(w)) == "" {
+		return fmt.Errorf("%w: WithAlertTitle %q", ErrEmptyTitle, string(w))
+	}
+	o.Title = string(w)
+	return nil
+}
+
+// WithRateLimit is a hook option to send at most this many alerts per minute, 20 by default like a DingTalk
+// robot, which is blocked for 10 minutes beyond. 0 disables the limit. See WithRateLimitBehavior.
+type WithRateLimit int
+
+func (w WithRateLimit) applyToHook(o *hookOptions) error {
+	if w < 0 {
+		return fmt.Errorf("invalid rate limit: %d", w)
+	}
+	o.RateLimit = int(w)
+	return nil
+}
+
+// RateLimitBehavior is what an AlertHook does with the alerts beyond its rate limit
+type RateLimitBehavior int
+
+const (
+	// RateLimitBlock waits for the rate limit, the alerts queue up meanwhile
+	RateLimitBlock RateLimitBehavior = iota
+	// RateLimitDrop drops the alert, counted in the next sent alert
+	RateLimitDrop
+	// RateLimitError drops the alert like RateLimitDrop, and logs ErrRateLimited locally
+	RateLimitError
+)
+
+// WithRateLimitBehavior is a hook option to set what to do with the alerts beyond the rate limit,
+// RateLimitBlock by default
+type WithRateLimitBehavior RateLimitBehavior
+
+func (w WithRateLimitBehavior) applyToHook(o *hookOptions) error {
+	if w < WithRateLimitBehavior(RateLimitBlock) || w > WithRateLimitBehavior(RateLimitError) {
+		return fmt.Errorf("invalid rate limit behavior: %d", w)
+	}
+	o.RateLimitBehavior = RateLimitBehavior(w)
+	return nil
+}
+
+// ErrMessageTooLarge is the error of an alert larger than the size set by WithMaxAlertSize, with OversizeError
+var ErrMessageTooLarge = errors.New("message too large")
+
+// minAlertSize is the smallest size of WithMaxAlertSize, leaving room for the part indicators and code fences
+const minAlertSize = 128
+
+// WithMaxAlertSize is a hook option to set the maximum size of an alert in bytes, 20000 by default like the
+// text of a DingTalk robot. See WithOversizePolicy.
+type WithMaxAlertSize int
+
+func (w WithMaxAlertSize) applyToHook(o *hookOptions) error {
+	if w < minAlertSize {
+		return fmt.Errorf("invalid max alert size: %d, the minimum is %d", w, minAlertSize)
+	}
+	o.MaxSize = int(w)
+	return nil
+}
+
+// OversizePolicy is what an AlertHook does with the alerts larger than WithMaxAlertSize
+type OversizePolicy int
+
+const (
+	// OversizeTruncate cuts the alert, with a marker telling how many bytes are omitted
+	OversizeTruncate OversizePolicy = iota
+	// OversizeSplit sends the alert in several parts, numbered, cut outside the code blocks when feasible
+	OversizeSplit
+	// OversizeError drops the alert, and logs ErrMessageTooLarge locally
+	OversizeError
+)
+
+// WithOversizePolicy is a hook option to set what to do with the alerts larger than WithMaxAlertSize,
+// OversizeTruncate by default
+type WithOversizePolicy OversizePolicy
+
+func (w WithOversizePolicy) applyToHook(o *hookOptions) error {
+	if w < WithOversizePolicy(OversizeTruncate) || w > WithOversizePolicy(OversizeError) {
+		return fmt.Errorf("invalid oversize policy: %d", w)
+	}
+	o.OversizePolicy = OversizePolicy(w)
+	return nil
+}
+
+// WithBeforeSend is a hook option to change each alert before it is sent, like prefixing the environment name,
+// or to refuse it by returning an error, which is reported to WithOnResult. It is called once per alert,
+// before the size check and the split, in the order of the options.
+// It is called from the background goroutine sending the alerts, like WithOnResult.
+type WithBeforeSend func(markdown string) (string, error)
+
+func (w WithBeforeSend) applyToHook(o *hookOptions) error {
+	o.BeforeSend = append(o.BeforeSend, w)
+	return nil
+}
+
+// WithOnResult is a hook option to call a func with the result of each alert: nil once sent, the error of the send,
+// ErrRateLimited when dropped by the rate limit, ErrMessageTooLarge, or the error of WithBeforeSend.
+// The funcs of several options are called in their order. They are called from the background goroutine
+// sending the alerts, so they must be quick, and must not log at the level of the hook.
+type WithOnResult func(markdown string, err error)
+
+func (w WithOnResult) applyToHook(o *hookOptions) error {
+	o.OnResult = append(o.OnResult, w)
+	return nil
+}
+
+// WithSendRetry is a hook option to retry the failed sends with these retry options, like
+// WithSendRetry{WithAttempts(3), WithBackoff{Initial: time.Second, Factor: 1, Increment: time.Second}}.
+// Sends are not retried by default. The retries hold up the next alerts, and stop after CloseContext gives up.
+type WithSendRetry []RetryOption
+
+func (w WithSendRetry) applyToHook(o *hookOptions) error {
+	// check the options now rather than at each send
+	if _, err := RetryV(context.Background(), func() (struct{}, error) { return struct{}{}, nil }, w...); err != nil {
+		return err
+	}
+	o.Retry = w
+	return nil
+}
+
+// ErrMissingKeyword is the error of an alert without the keyword of WithKeyword, with KeywordError
+var ErrMissingKeyword = errors.New("missing keyword")
+
+// maxKeywordSize is the maximum size of the keyword of WithKeyword
+const maxKeywordSize = 64
+
+// WithKeyword is a hook option to make sure that the alerts contain a keyword, for the robots whose security
+// setting is a custom keyword, which reject the messages without it. See WithKeywordPolicy.
+type WithKeyword string
+
+func (w WithKeyword) applyToHook(o *hookOptions) error {
+	if w == "" || len(w) > maxKeywordSize {
+		return fmt.Errorf("invalid keyword: %q", string(w))
+	}
+	o.Keyword = string(w)
+	return nil
+}
+
+// KeywordPolicy is what an AlertHook does with the alerts without the keyword of WithKeyword
+type KeywordPolicy int
+
+const (
+	// KeywordPrepend adds the keyword at the start of the alert, after the heading marker if it starts with a
+	// heading, and at the start of each part with OversizeSplit
+	KeywordPrepend KeywordPolicy = iota
+	// KeywordError drops the alert, and logs ErrMissingKeyword locally
+	KeywordError
+)
+
+// WithKeywordPolicy is a hook option to set what to do with the alerts without the keyword of WithKeyword,
+// KeywordPrepend by default
+type WithKeywordPolicy KeywordPolicy
+
+func (w WithKeywordPolicy) applyToHook(o *hookOptions) error {
+	if w < WithKeywordPolicy(KeywordPrepend) || w > WithKeywordPolicy(KeywordError) {
+		return fmt.Errorf("invalid keyword policy: %d", w)
+	}
+	o.KeywordPolicy = KeywordPolicy(w)
+	return nil
+}
+
+// maxFallbackDepth is the maximum number of fallbacks tried for an alert, which also breaks the cycles
+const maxFallbackDepth = 3
+
+// WithFallback is a hook option to send the alerts that failed, after the retries of WithSendRetry, with the send
+// func of Hook, like a backup robot in another group, when If returns true for the error, or for any error if If is
+// nil. The fallback of Hook is tried in turn if it fails too, up to 3 fallbacks.
+//
+// The alerts go straight to the send func of Hook, which must be safe for concurrent use, without its queue,
+// min interval and rate limit, and are reported to its WithOnResult funcs, to tell when the fallback was used.
+type WithFallback struct {
+	Hook *AlertHook
+	If   func(error) bool
+}
+
+func (w WithFallback) applyToHook(o *hookOptions) error {
+	if w.Hook == nil {
+		return errors.New("missing fallback hook")
+	}
+	o.Fallback = &w
+	return nil
+}
+
+var (
+	// ErrQueueFull is the error of an alert refused by AlertHook.Alert because the queue is full, see WithQueueSize
+	ErrQueueFull = errors.New("alert queue full")
+	// ErrHookClosed is the error of an alert refused by AlertHook.Alert after Close
+	ErrHookClosed = errors.New("alert hook closed")
+)
+
+// hookSendFailedMsg is the message of the local log of a failed send, never forwarded to avoid recursion
+const hookSendFailedMsg = "Failed to send log alert"
+
+// AlertHook is a zerolog.Hook forwarding the events at or above a level to an alerting channel,
+// like a DingTalk or Slack robot, as Markdown.
+//
+// Events are queued and sent by a background goroutine, so logging never blocks on the channel,
+// and sends are spaced by WithMinInterval and limited by WithRateLimit so a log storm can't flood the channel.
+// Send failures are only logged locally, and reported to WithOnResult. Alert queues other alerts the same way.
+//
+// A zerolog hook can't read the fields of the events, so the alerts have the message, level, time and caller only.
+// To alert with the fields, write the JSON events to an AlertWriter instead.
+type AlertHook struct {
+	send     func(title, markdown string) error
+	minLevel zerolog.Level
+	opt      *hookOptions
+	limiter  *RateLimiter
+
+	// ctx is cancelled when CloseContext gives up, to stop the retries
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	queue   chan hookAlert
+	mu      sync.Mutex
+	dropped int
+	closed  bool
+	done    chan struct{}
+
+	stats alertCounters
+}
+
+// NewAlertHook returns a started AlertHook sending the events at or above minLevel with send.
+// Stop it with Close to flush the queued alerts.
+func NewAlertHook(send func(title, markdown string) error, minLevel zerolog.Level, opts ...HookOption) (*AlertHook, error) {
+	opt := &hookOptions{
+		QueueSize:   100,
+		MinInterval: 3 * time.Second,
+		Title:       "log alert",
+		RateLimit:   20,
+		MaxSize:     20000,
+	}
+	for _, o := range opts {
+		if err := o.applyToHook(opt); err != nil {
+			return nil, err
+		}
+	}
+
+	h := &AlertHook{
+		send:     send,
+		minLevel: minLevel,
+		opt:      opt,
+		queue:    make(chan hookAlert, opt.QueueSize),
+		done:     make(chan struct{}),
+	}
+	h.ctx, h.cancel = context.WithCancel(context.Background())
+	if opt.RateLimit > 0 {
+		limiter, err := NewRateLimiter(opt.RateLimit, time.Minute)
+		if err != nil {
+			return nil, err
+		}
+		h.limiter = limiter
+	}
+	go h.run()
+	return h, nil
+}
+
+// Run implements zerolog.Hook
+func (h *AlertHook) Run(e *zerolog.Event, level zerolog.Level, msg string) {
+	if level < h.minLevel || level == zerolog.NoLevel || level == zerolog.Disabled || msg == hookSendFailedMsg {
+		return
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "### %s\n\n", h.opt.Title)
+	fmt.Fprintf(&sb, "- **level**: %s\n", level)
+	fmt.Fprintf(&sb, "- **time**: %s\n", time.Now().Format("2006-01-02 15:04:05.000"))
+	if caller := hookCaller(); caller != "" {
+		fmt.Fprintf(&sb, "- **caller**: %s\n", caller)
+	}
+	fmt.Fprintf(&sb, "\n%s\n", msg)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.enqueue(hookAlert{text: sb.String()}); errors.Is(err, ErrQueueFull) {
+		h.dropped++
+	}
+}
+
+// Alert queues a Markdown alert to send like the logged events, without blocking.
+// It returns ErrQueueFull when the queue is full, and ErrHookClosed after Close.
+func (h *AlertHook) Alert(markdown string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.enqueue(hookAlert{text: markdown})
+}
+
+// Send queues a Markdown alert like Alert, and waits until it is sent, to return the error of the send, joined
+// with the errors of the other parts with OversizeSplit. It stops waiting when ctx is done and returns ctx.Err(),
+// the alert is still sent.
+func (h *AlertHook) Send(ctx context.Context, markdown string) error {
+	alert := hookAlert{text: markdown, result: make(chan error, 1)}
+	h.mu.Lock()
+	err := h.enqueue(alert)
+	h.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case err := <-alert.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// AlertTemplate is like Alert, with the Markdown rendered by RenderTemplate.
+// The errors of the template are returned at once.
+func (h *AlertHook) AlertTemplate(name, text string, data any, opts ...TemplateOption) error {
+	markdown, err := RenderTemplate(name, text, data, opts...)
+	if err != nil {
+		return err
+	}
+	return h.Alert(markdown)
+}
+
+// hookAlert is a queued alert, with the channel of its result for Send
+type hookAlert struct {
+	text   string
+	result chan error
+}
+
+// enqueue queues alert, h.mu must be held
+func (h *AlertHook) enqueue(alert hookAlert) error {
+	if h.closed {
+		return ErrHookClosed
+	}
+	select {
+	case h.queue <- alert:
+		return nil
+	default:
+		h.stats.dropped.Add(1)
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting alerts, and waits until the queued ones are sent
+func (h *AlertHook) Close() {
+	_ = h.CloseContext(context.Background())
+}
+
+// CloseContext is like Close, but stops waiting when ctx is done and returns ctx.Err().
+// The queued alerts are still sent in the background, without the retries of WithSendRetry.
+func (h *AlertHook) CloseContext(ctx context.Context) error {
+	h.mu.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.queue)
+	}
+	h.mu.Unlock()
+
+	select {
+	case <-h.done:
+		h.cancel()
+		return nil
+	case <-ctx.Done():
+		h.cancel()
+		return ctx.Err()
+	}
+}
+
+func (h *AlertHook) run() {
+	defer close(h.done)
+
+	var last time.Time
+	for alert := range h.queue {
+		err := h.process(alert.text, &last)
+		if alert.result != nil {
+			alert.result <- err
+		}
+	}
+}
+
+// process sends the alert text, last being the time of the last send, and returns the errors of its parts
+func (h *AlertHook) process(text string, last *time.Time) error {
+	h.mu.Lock()
+	dropped := h.dropped
+	h.dropped = 0
+	h.mu.Unlock()
+	if dropped > 0 {
+		text += fmt.Sprintf("\n> %d alerts dropped\n", dropped)
+	}
+
+	text, err := h.beforeSend(text)
+	if err != nil {
+		Logger.Warn().Err(err).Msg(hookSendFailedMsg)
+		h.stats.refused.Add(1)
+		h.report(text, err)
+		return err
+	}
+	if h.opt.Keyword != "" && h.opt.KeywordPolicy == KeywordError && !strings.Contains(text, h.opt.Keyword) {
+		err := fmt.Errorf("%w: %q", ErrMissingKeyword, h.opt.Keyword)
+		Logger.Warn().Err(err).Msg(hookSendFailedMsg)
+		h.stats.refused.Add(1)
+		h.report(text, err)
+		return err
+	}
+	parts, err := h.fit(text)
+	if err != nil {
+		Logger.Warn().Err(err).Int("size", len(text)).Msg(hookSendFailedMsg)
+		h.stats.refused.Add(1)
+		h.report(text, err)
+		return err
+	}
+	var errs []error
+	for _, part := range parts {
+		if wait := h.opt.MinInterval - time.Since(*last); wait > 0 {
+			time.Sleep(wait)
+		}
+		if !h.takeRateLimit() {
+			h.stats.rateLimited.Add(1)
+			h.report(part, ErrRateLimited)
+			errs = append(errs, ErrRateLimited)
+			continue
+		}
+
+		err := h.deliver(part, 0)
+		if err != nil {
+			Logger.Warn().Err(err).Msg(hookSendFailedMsg)
+			errs = append(errs, err)
+		}
+		h.count(err)
+		h.report(part, err)
+		*last = time.Now()
+	}
+	return errors.Join(errs...)
+}
+
+// sendRetry sends markdown, retried as set by WithSendRetry until CloseContext gives up
+func (h *AlertHook) sendRetry(markdown string) error {
+	if len(h.opt.Retry) == 0 || h.ctx.Err() != nil {
+		return h.send(h.opt.Title, markdown)
+	}
+	attempts := 0
+	err := Retry(h.ctx, func() error {
+		attempts++
+		return h.send(h.opt.Title, markdown)
+	}, h.opt.Retry...)
+	if attempts > 1 {
+		h.stats.retries.Add(int64(attempts - 1))
+	}
+	return err
+}
+
+// deliver sends markdown, with the fallbacks of WithFallback if it fails, depth being the number of fallbacks
+// already tried
+func (h *AlertHook) deliver(markdown string, depth int) error {
+	err := h.sendRetry(markdown)
+	fallback := h.opt.Fallback
+	if err == nil || fallback == nil || (fallback.If != nil && !fallback.If(err)) {
+		return err
+	}
+	if depth >= maxFallbackDepth {
+		return fmt.Errorf("%w, and no more fallbacks after %d", err, depth)
+	}
+
+	Logger.Warn().Err(err).Str("fallback", fallback.Hook.opt.Title).Msg(hookSendFailedMsg)
+	h.stats.fallbacks.Add(1)
+	fallbackErr := fallback.Hook.deliver(markdown, depth+1)
+	fallback.Hook.count(fallbackErr)
+	fallback.Hook.report(markdown, fallbackErr)
+	if fallbackErr != nil {
+		return fmt.Errorf("%w, fallback: %w", err, fallbackErr)
+	}
+	return nil
+}
+
+// beforeSend returns text changed by the WithBeforeSend funcs, or the text and the error of the one refusing it
+func (h *AlertHook) beforeSend(text string) (string, error) {
+	for _, fn := range h.opt.BeforeSend {
+		changed, err := fn(text)
+		if err != nil {
+			return text, err
+		}
+		text = changed
+	}
+	return text, nil
+}
+
+// count counts a delivered alert in the stats, sent if err is nil
+func (h *AlertHook) count(err error) {
+	if err == nil {
+		h.stats.sent.Add(1)
+	} else {
+		h.stats.fail(err)
+	}
+}
+
+// report calls the WithOnResult funcs
+func (h *AlertHook) report(markdown string, err error) {
+	for _, fn := range h.opt.OnResult {
+		fn(markdown, err)
+	}
+}
+
+// fit returns the alerts to send for text, as set by WithMaxAlertSize and WithOversizePolicy
+func (h *AlertHook) fit(text string) ([]string, error) {
+	maxSize := h.opt.MaxSize
+	if h.opt.Keyword != "" {
+		// room for the keyword
+		maxSize -= len(h.opt.Keyword) + 1
+	}
+
+	var parts []string
+	switch {
+	case len(text) <= maxSize:
+		parts = []string{text}
+	case h.opt.OversizePolicy == OversizeSplit:
+		// room for the part indicators
+		parts = splitMarkdown(text, maxSize-32)
+		for i := range parts {
+			parts[i] += fmt.Sprintf("\n\n(part %d/%d)", i+1, len(parts))
+		}
+	case h.opt.OversizePolicy == OversizeError:
+		return nil, fmt.Errorf("%w: %d bytes, the maximum is %d", ErrMessageTooLarge, len(text), maxSize)
+	default:
+		parts = []string{truncateMessage(text, maxSize)}
+	}
+	if h.opt.Keyword != "" {
+		for i, part := range parts {
+			parts[i] = prependKeyword(part, h.opt.Keyword)
+		}
+	}
+	return parts, nil
+}
+
+// prependKeyword returns text starting with keyword, after the heading marker if text starts with a heading,
+// unless text contains it already
+func prependKeyword(text, keyword string) string {
+	if strings.Contains(text, keyword) {
+		return text
+	}
+	if level := len(text) - len(strings.TrimLeft(text, "#")); level > 0 && strings.HasPrefix(text[level:], " ") {
+		return text[:level+1] + keyword + " " + text[level+1:]
+	}
+	return keyword + " " + text
+}
+
+// truncateMessage cuts text to at most max bytes, on a rune boundary, with a marker of the bytes omitted
+func truncateMessage(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	marker := func(omitted int) string {
+		return fmt.Sprintf("\n…(truncated, %d bytes omitted)", omitted)
+	}
+	// the marker of the actual number of bytes omitted is not longer
+	keep := max - len(marker(len(text)))
+	for keep > 0 && !utf8.RuneStart(text[keep]) {
+		keep--
+	}
+	return text[:keep] + marker(len(text)-keep)
+}
+
+// splitMarkdown splits text into parts of at most max bytes at line ends, outside the code fences when
+// feasible, or else closing the fence at the end of a part and opening it again in the next one
+func splitMarkdown(text string, max int) []string {
+	const closing = "```\n"
+	var (
+		parts []string
+		cur   []string
+		size  int
+		// fence is the opening line of the code fence cur ends in, and fenceStart its index in cur
+		fence      string
+		fenceStart int
+	)
+	flush := func(n int) {
+		if n > 0 {
+			parts = append(parts, strings.TrimRight(strings.Join(cur[:n], ""), "\n"))
+		}
+		cur = cur[n:]
+		size = 0
+		for _, line := range cur {
+			size += len(line)
+		}
+	}
+
+	for _, line := range splitLines(text, max/2) {
+		for {
+			reserved := 0
+			if fence != "" {
+				reserved = len(closing)
+			}
+			if size+len(line)+reserved <= max {
+				break
+			}
+			if fence != "" && fenceStart > 0 {
+				// the part ends before the code block
+				flush(fenceStart)
+				fenceStart = 0
+				continue
+			}
+			if fence != "" {
+				cur = append(cur, closing)
+				flush(len(cur))
+				cur = []string{fence}
+				size = len(fence)
+				continue
+			}
+			flush(len(cur))
+			break
+		}
+
+		cur = append(cur, line)
+		size += len(line)
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if fence == "" {
+				fence = strings.TrimRight(line, "\n") + "\n"
+				fenceStart = len(cur) - 1
+			} else {
+				fence = ""
+			}
+		}
+	}
+	flush(len(cur))
+	return parts
+}
+
+// splitLines splits text after its line ends, and the lines longer than max on rune boundaries
+func splitLines(text string, max int) []string {
+	var lines []string
+	for _, line := range strings.SplitAfter(text, "\n") {
+		for len(line) > max {
+			cut := max
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			lines = append(lines, line[:cut])
+			line = line[cut:]
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// takeRateLimit returns true if the next alert can be sent, waiting for the rate limit with RateLimitBlock
+func (h *AlertHook) takeRateLimit() bool {
+	if h.limiter == nil {
+		return true
+	}
+	if h.opt.RateLimitBehavior == RateLimitBlock {
+		return h.limiter.Wait(context.Background()) == nil
+	}
+	if h.limiter.Allow() {
+		return true
+	}
+
+	h.mu.Lock()
+	h.dropped++
+	h.mu.Unlock()
+	if h.opt.RateLimitBehavior == RateLimitError {
+		Logger.Warn().Err(ErrRateLimited).Msg(hookSendFailedMsg)
+	}
+	return false
+}
+
+// hookCaller returns the file:line of the code logging the event, outside zerolog and the hook
+func hookCaller() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "github.com/rs/zerolog") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// WithHook is a log option to add a hook, like an AlertHook, to the logger built by the other options
+type WithHook struct {
+	Hook zerolog.Hook
+}
+
+func (w WithHook) applyTo(o *logOptions) error {
+	o.Hooks = append(o.Hooks, w.Hook)
+	return nil
+}
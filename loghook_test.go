@@ -0,0 +1,570 @@
+package goutils_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+	"github.com/117503445/goutils/testutil"
+)
+
+func TestAlertHook(t *testing.T) {
+	ast := assert.New(t)
+
+	webhook := testutil.NewWebhookRecorder(t, nil)
+	hook, err := goutils.NewAlertHook(webhook.Send, zerolog.ErrorLevel, goutils.WithMinInterval(0), goutils.WithAlertTitle("svc"))
+	ast.NoError(err)
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf).Hook(hook)
+	logger.Info().Msg("not forwarded")
+	logger.Warn().Msg("not forwarded")
+	logger.Error().Msg("disk full")
+	logger.WithLevel(zerolog.PanicLevel).Msg("panic level")
+	hook.Close()
+
+	bodies := webhook.Markdowns()
+	ast.Len(bodies, 2)
+	ast.Equal([]string{"svc", "svc"}, webhook.Titles())
+	ast.Equal("application/json", webhook.Requests()[0].Header.Get("Content-Type"))
+	ast.Contains(bodies[0], "### svc")
+	ast.Contains(bodies[0], "**level**: error")
+	ast.Contains(bodies[0], "disk full")
+	ast.Contains(bodies[0], "loghook_test.go")
+	ast.Contains(bodies[1], "panic level")
+
+	// events after Close are ignored
+	logger.Error().Msg("after close")
+	ast.Len(webhook.Requests(), 2)
+
+	_, err = goutils.NewAlertHook(webhook.Send, zerolog.ErrorLevel, goutils.WithAlertTitle(" "))
+	ast.ErrorIs(err, goutils.ErrEmptyTitle)
+}
+
+func TestInitZeroLogWithHook(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.InitZeroLog()
+
+	var runs atomic.Int64
+	hook := zerolog.HookFunc(func(e *zerolog.Event, level zerolog.Level, msg string) {
+		if msg == "counted" {
+			runs.Add(1)
+		}
+	})
+	// each init builds a fresh logger, without the hooks and sampler of the previous one
+	goutils.InitZeroLog(goutils.WithHook{Hook: hook}, goutils.WithSampling{Sampler: &zerolog.BasicSampler{N: 1000}})
+	goutils.InitZeroLog(goutils.WithHook{Hook: hook})
+	goutils.Logger.Error().Msg("counted")
+	ast.EqualValues(1, runs.Load())
+
+	goutils.InitZeroLog()
+	for range 10 {
+		goutils.Logger.Debug().Msg("counted")
+	}
+	ast.EqualValues(1, runs.Load())
+}
+
+func TestAlertHookQueue(t *testing.T) {
+	ast := assert.New(t)
+
+	var mu sync.Mutex
+	var texts []string
+	started := make(chan struct{}, 1)
+	block := make(chan struct{})
+	send := func(title, markdown string) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-block
+		mu.Lock()
+		texts = append(texts, markdown)
+		mu.Unlock()
+		return errors.New("robot unavailable")
+	}
+	hook, err := goutils.NewAlertHook(send, zerolog.WarnLevel, goutils.WithMinInterval(0), goutils.WithQueueSize(2))
+	ast.NoError(err)
+
+	// logging never blocks, even while a send is stuck
+	logger := zerolog.New(io.Discard).Hook(hook)
+	logger.Error().Msg("first")
+	<-started
+	for i := 0; i < 10; i++ {
+		logger.Error().Int("i", i).Msg("storm")
+	}
+	close(block)
+	hook.Close()
+
+	// the first alert was being sent, 2 were queued, the others were dropped and counted
+	ast.Len(texts, 3)
+	ast.Contains(texts[0], "first")
+	ast.Contains(texts[1], "8 alerts dropped")
+
+	_, err = goutils.NewAlertHook(send, zerolog.WarnLevel, goutils.WithQueueSize(0))
+	ast.Error(err)
+}
+
+func TestAlertHookRateLimit(t *testing.T) {
+	ast := assert.New(t)
+	logs := testutil.CaptureLogs(t)
+
+	clock := testutil.NewFakeClock(time.Date(2024, 9, 15, 22, 12, 19, 0, time.UTC))
+	defer goutils.SetClock(goutils.SetClock(clock))
+
+	var sent atomic.Int64
+	send := func(title, markdown string) error {
+		sent.Add(1)
+		return nil
+	}
+	storm := func(hook *goutils.AlertHook) {
+		logger := zerolog.New(io.Discard).Hook(hook)
+		for i := range 25 {
+			logger.Error().Int("i", i).Msg("disk full")
+		}
+	}
+
+	// blocked until the tokens come back, one every 3s
+	hook, err := goutils.NewAlertHook(send, zerolog.ErrorLevel, goutils.WithMinInterval(0))
+	ast.NoError(err)
+	storm(hook)
+	clock.BlockUntil(1)
+	ast.EqualValues(20, sent.Load())
+	clock.Advance(3 * time.Second)
+	clock.BlockUntil(1)
+	ast.EqualValues(21, sent.Load())
+	for range 4 {
+		clock.BlockUntil(1)
+		clock.Advance(3 * time.Second)
+	}
+	hook.Close()
+	ast.EqualValues(25, sent.Load())
+
+	// dropped
+	for _, behavior := range []goutils.RateLimitBehavior{goutils.RateLimitDrop, goutils.RateLimitError} {
+		sent.Store(0)
+		hook, err = goutils.NewAlertHook(send, zerolog.ErrorLevel, goutils.WithMinInterval(0),
+			goutils.WithRateLimit(10), goutils.WithRateLimitBehavior(behavior))
+		ast.NoError(err)
+		storm(hook)
+		hook.Close()
+		ast.EqualValues(10, sent.Load())
+	}
+	ast.Equal(15, logs.Count("warn"))
+	ast.True(logs.Contains("warn", goutils.ErrRateLimited.Error()))
+
+	// unlimited
+	sent.Store(0)
+	hook, err = goutils.NewAlertHook(send, zerolog.ErrorLevel, goutils.WithMinInterval(0), goutils.WithRateLimit(0))
+	ast.NoError(err)
+	storm(hook)
+	hook.Close()
+	ast.EqualValues(25, sent.Load())
+
+	_, err = goutils.NewAlertHook(send, zerolog.ErrorLevel, goutils.WithRateLimitBehavior(3))
+	ast.Error(err)
+}
+
+func TestAlertHookOversize(t *testing.T) {
+	ast := assert.New(t)
+	logs := testutil.CaptureLogs(t)
+
+	var (
+		mu    sync.Mutex
+		texts []string
+	)
+	send := func(title, markdown string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		texts = append(texts, markdown)
+		return nil
+	}
+	const maxSize = 300
+	msg := "日志" + strings.Repeat("line of the stack trace\n", 10) +
+		"```\n" + strings.Repeat("goroutine 1 [running]:\n", 8) + "```\n" +
+		strings.Repeat("日志", 60)
+	alert := func(policy goutils.OversizePolicy) []string {
+		texts = nil
+		hook, err := goutils.NewAlertHook(send, zerolog.ErrorLevel, goutils.WithMinInterval(0), goutils.WithRateLimit(0),
+			goutils.WithMaxAlertSize(maxSize), goutils.WithOversizePolicy(policy))
+		ast.NoError(err)
+		logger := zerolog.New(io.Discard).Hook(hook)
+		logger.Error().Msg(msg)
+		hook.Close()
+		return texts
+	}
+
+	// truncated
+	sent := alert(goutils.OversizeTruncate)
+	if ast.Len(sent, 1) {
+		ast.LessOrEqual(len(sent[0]), maxSize)
+		ast.True(utf8.ValidString(sent[0]))
+		ast.Regexp(`…\(truncated, \d+ bytes omitted\)$`, sent[0])
+	}
+
+	// split
+	sent = alert(goutils.OversizeSplit)
+	ast.Greater(len(sent), 1)
+	var joined strings.Builder
+	for i, text := range sent {
+		ast.LessOrEqual(len(text), maxSize)
+		ast.True(utf8.ValidString(text))
+		ast.Zero(strings.Count(text, "```")%2, "part %d breaks a code block: %s", i, text)
+		ast.True(strings.HasSuffix(text, fmt.Sprintf("(part %d/%d)", i+1, len(sent))))
+		joined.WriteString(text)
+	}
+	ast.Equal(strings.Count(msg, "goroutine 1"), strings.Count(joined.String(), "goroutine 1"))
+	ast.Equal(strings.Count(msg, "日志"), strings.Count(joined.String(), "日志"))
+
+	// error
+	sent = alert(goutils.OversizeError)
+	ast.Empty(sent)
+	ast.True(logs.Contains("warn", goutils.ErrMessageTooLarge.Error()))
+
+	// small enough
+	msg = "disk full"
+	ast.Len(alert(goutils.OversizeError), 1)
+
+	_, err := goutils.NewAlertHook(send, zerolog.ErrorLevel, goutils.WithMaxAlertSize(10))
+	ast.Error(err)
+	_, err = goutils.NewAlertHook(send, zerolog.ErrorLevel, goutils.WithOversizePolicy(3))
+	ast.Error(err)
+}
+
+func TestAlertHookAlert(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	// slower than the alerts come
+	var received atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		received.Add(1)
+	}))
+	defer server.Close()
+	send := func(title, markdown string) error {
+		resp, err := http.Post(server.URL, "text/markdown", strings.NewReader(markdown))
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	var (
+		mu      sync.Mutex
+		results = map[string]error{}
+	)
+	onResult := func(markdown string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[markdown] = err
+	}
+	hook, err := goutils.NewAlertHook(send, zerolog.ErrorLevel, goutils.WithMinInterval(0), goutils.WithRateLimit(0),
+		goutils.WithOnResult(onResult))
+	ast.NoError(err)
+	for i := range 30 {
+		ast.NoError(hook.Alert(fmt.Sprintf("alert %d", i)))
+	}
+	ast.NoError(hook.CloseContext(context.Background()))
+	ast.EqualValues(30, received.Load())
+	ast.Len(results, 30)
+	for _, err := range results {
+		ast.NoError(err)
+	}
+	ast.ErrorIs(hook.Alert("late"), goutils.ErrHookClosed)
+
+	// full
+	release := make(chan struct{})
+	blocked := func(title, markdown string) error {
+		<-release
+		return errors.New("webhook down")
+	}
+	results = map[string]error{}
+	hook, err = goutils.NewAlertHook(blocked, zerolog.ErrorLevel, goutils.WithMinInterval(0), goutils.WithRateLimit(0),
+		goutils.WithQueueSize(2), goutils.WithOnResult(onResult))
+	ast.NoError(err)
+	var full error
+	for i := 0; full == nil && i < 10; i++ {
+		full = hook.Alert(fmt.Sprintf("alert %d", i))
+	}
+	ast.ErrorIs(full, goutils.ErrQueueFull)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	ast.ErrorIs(hook.CloseContext(ctx), context.DeadlineExceeded)
+	close(release)
+	hook.Close()
+	ast.NotEmpty(results)
+	for _, err := range results {
+		ast.EqualError(err, "webhook down")
+	}
+}
+
+func TestAlertHookBeforeSend(t *testing.T) {
+	ast := assert.New(t)
+	logs := testutil.CaptureLogs(t)
+
+	var (
+		mu       sync.Mutex
+		received []string
+		calls    []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, string(body))
+	}))
+	defer server.Close()
+	send := func(title, markdown string) error {
+		resp, err := http.Post(server.URL, "text/markdown", strings.NewReader(markdown))
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}
+
+	hook, err := goutils.NewAlertHook(send, zerolog.ErrorLevel, goutils.WithMinInterval(0), goutils.WithRateLimit(0),
+		goutils.WithBeforeSend(func(markdown string) (string, error) {
+			record("before 1: " + markdown)
+			if strings.Contains(markdown, "secret") {
+				return "", errors.New("refused")
+			}
+			return "[prod] " + markdown, nil
+		}),
+		goutils.WithBeforeSend(func(markdown string) (string, error) {
+			record("before 2: " + markdown)
+			return markdown, nil
+		}),
+		goutils.WithOnResult(func(markdown string, err error) {
+			record(fmt.Sprintf("result 1: %s %v", markdown, err))
+		}),
+		goutils.WithOnResult(func(markdown string, err error) {
+			record(fmt.Sprintf("result 2: %s %v", markdown, err))
+		}),
+	)
+	ast.NoError(err)
+	ast.NoError(hook.Alert("disk full"))
+	ast.NoError(hook.Alert("the secret is 42"))
+	hook.Close()
+
+	ast.Equal([]string{"[prod] disk full"}, received)
+	ast.Equal([]string{
+		"before 1: disk full",
+		"before 2: [prod] disk full",
+		"result 1: [prod] disk full <nil>",
+		"result 2: [prod] disk full <nil>",
+		"before 1: the secret is 42",
+		"result 1: the secret is 42 refused",
+		"result 2: the secret is 42 refused",
+	}, calls)
+	ast.True(logs.Contains("warn", "refused"))
+}
+
+func TestAlertHookSendRetry(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	clock := testutil.NewFakeClock(time.Date(2024, 9, 15, 22, 12, 19, 0, time.UTC))
+	defer goutils.SetClock(goutils.SetClock(clock))
+
+	var calls atomic.Int64
+	send := func(title, markdown string) error {
+		if calls.Add(1)%3 != 0 {
+			return errors.New("webhook down")
+		}
+		return nil
+	}
+	var results []error
+	hook, err := goutils.NewAlertHook(send, zerolog.ErrorLevel, goutils.WithMinInterval(0), goutils.WithRateLimit(0),
+		goutils.WithSendRetry{goutils.WithAttempts(3), goutils.WithBackoff{Initial: time.Second, Factor: 1, Increment: time.Second}},
+		goutils.WithOnResult(func(markdown string, err error) { results = append(results, err) }))
+	ast.NoError(err)
+	ast.NoError(hook.Alert("disk full"))
+	for _, wait := range []time.Duration{time.Second, 2 * time.Second} {
+		clock.BlockUntil(1)
+		clock.Advance(wait)
+	}
+	hook.Close()
+	ast.EqualValues(3, calls.Load())
+	ast.Equal([]error{nil}, results)
+
+	// the retries stop when CloseContext gives up, the queued alerts are sent once
+	calls.Store(0)
+	results = nil
+	hook, err = goutils.NewAlertHook(send, zerolog.ErrorLevel, goutils.WithMinInterval(0), goutils.WithRateLimit(0),
+		goutils.WithSendRetry{goutils.WithAttempts(3), goutils.WithBackoff{Initial: time.Hour, Factor: 1}},
+		goutils.WithOnResult(func(markdown string, err error) { results = append(results, err) }))
+	ast.NoError(err)
+	ast.NoError(hook.Alert("disk full"))
+	ast.NoError(hook.Alert("disk still full"))
+	clock.BlockUntil(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ast.ErrorIs(hook.CloseContext(ctx), context.Canceled)
+	hook.Close()
+	ast.EqualValues(2, calls.Load())
+	if ast.Len(results, 2) {
+		ast.ErrorIs(results[0], context.Canceled)
+		ast.EqualError(results[1], "webhook down")
+	}
+
+	_, err = goutils.NewAlertHook(send, zerolog.ErrorLevel, goutils.WithSendRetry{goutils.WithAttempts(0)})
+	ast.Error(err)
+}
+
+func TestAlertHookFallback(t *testing.T) {
+	ast := assert.New(t)
+	logs := testutil.CaptureLogs(t)
+
+	var primaryRequests, backupRequests atomic.Int64
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryRequests.Add(1)
+		http.Error(w, `{"errcode":400101,"errmsg":"access_token not exist"}`, http.StatusBadRequest)
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backupRequests.Add(1)
+	}))
+	defer backup.Close()
+	sendTo := func(url string) func(title, markdown string) error {
+		return func(title, markdown string) error {
+			resp, err := http.Post(url, "text/markdown", strings.NewReader(markdown))
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return errors.New(resp.Status)
+			}
+			return nil
+		}
+	}
+
+	var mu sync.Mutex
+	var results []string
+	record := func(name string) goutils.WithOnResult {
+		return func(markdown string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, fmt.Sprintf("%s: %s %v", name, markdown, err))
+		}
+	}
+	backupHook, err := goutils.NewAlertHook(sendTo(backup.URL), zerolog.ErrorLevel, goutils.WithAlertTitle("backup"),
+		record("backup"))
+	ast.NoError(err)
+	defer backupHook.Close()
+	hook, err := goutils.NewAlertHook(sendTo(primary.URL), zerolog.ErrorLevel, goutils.WithMinInterval(0),
+		goutils.WithFallback{Hook: backupHook}, record("primary"))
+	ast.NoError(err)
+	ast.NoError(hook.Alert("disk full"))
+	hook.Close()
+
+	ast.EqualValues(1, primaryRequests.Load())
+	ast.EqualValues(1, backupRequests.Load())
+	ast.Equal([]string{"backup: disk full <nil>", "primary: disk full <nil>"}, results)
+	ast.True(logs.Contains("warn", "400 Bad Request"))
+
+	// only the errors matched by If fall back
+	primaryRequests.Store(0)
+	backupRequests.Store(0)
+	results = nil
+	hook, err = goutils.NewAlertHook(sendTo(primary.URL), zerolog.ErrorLevel, goutils.WithMinInterval(0),
+		goutils.WithFallback{Hook: backupHook, If: func(err error) bool { return strings.Contains(err.Error(), "429") }},
+		record("primary"))
+	ast.NoError(err)
+	ast.NoError(hook.Alert("disk full"))
+	hook.Close()
+	ast.EqualValues(0, backupRequests.Load())
+	ast.Equal([]string{"primary: disk full 400 Bad Request"}, results)
+
+	// the chain stops after 3 fallbacks
+	primaryRequests.Store(0)
+	results = nil
+	var chain *goutils.AlertHook
+	for range 5 {
+		var opts []goutils.HookOption
+		if chain != nil {
+			opts = append(opts, goutils.WithFallback{Hook: chain})
+		}
+		chain, err = goutils.NewAlertHook(sendTo(primary.URL), zerolog.ErrorLevel, append(opts, goutils.WithMinInterval(0))...)
+		ast.NoError(err)
+		defer chain.Close()
+	}
+	hook, err = goutils.NewAlertHook(sendTo(primary.URL), zerolog.ErrorLevel, goutils.WithMinInterval(0),
+		goutils.WithFallback{Hook: chain}, record("primary"))
+	ast.NoError(err)
+	ast.NoError(hook.Alert("disk full"))
+	hook.Close()
+	ast.EqualValues(4, primaryRequests.Load())
+	if ast.Len(results, 1) {
+		ast.Contains(results[0], "no more fallbacks after 3")
+	}
+
+	_, err = goutils.NewAlertHook(sendTo(primary.URL), zerolog.ErrorLevel, goutils.WithFallback{})
+	ast.Error(err)
+}
+
+func TestAlertHookKeyword(t *testing.T) {
+	ast := assert.New(t)
+	logs := testutil.CaptureLogs(t)
+
+	webhook := testutil.NewWebhookRecorder(t, nil)
+	hook, err := goutils.NewAlertHook(webhook.Send, zerolog.ErrorLevel, goutils.WithMinInterval(0), goutils.WithRateLimit(0),
+		goutils.WithKeyword("告警"), goutils.WithMaxAlertSize(200), goutils.WithOversizePolicy(goutils.OversizeSplit))
+	ast.NoError(err)
+	ast.NoError(hook.Alert("disk full"))
+	ast.NoError(hook.Alert("### svc\n\ndisk full"))
+	ast.NoError(hook.Alert("告警: disk full"))
+	ast.NoError(hook.Alert(strings.Repeat("disk full\n", 50)))
+	logger := zerolog.New(io.Discard).Hook(hook)
+	logger.Error().Msg("db down")
+	hook.Close()
+
+	markdowns := webhook.Markdowns()
+	if ast.Greater(len(markdowns), 5) {
+		ast.Equal("告警 disk full", markdowns[0])
+		ast.Equal("### 告警 svc\n\ndisk full", markdowns[1])
+		ast.Equal("告警: disk full", markdowns[2])
+		for _, part := range markdowns[3 : len(markdowns)-1] {
+			ast.True(strings.HasPrefix(part, "告警 disk full\n"), part)
+			ast.Contains(part, "(part ")
+			ast.LessOrEqual(len(part), 200)
+		}
+		ast.True(strings.HasPrefix(markdowns[len(markdowns)-1], "### 告警 log alert\n"))
+	}
+
+	// refused before any request
+	webhook = testutil.NewWebhookRecorder(t, nil)
+	hook, err = goutils.NewAlertHook(webhook.Send, zerolog.ErrorLevel, goutils.WithMinInterval(0),
+		goutils.WithKeyword("告警"), goutils.WithKeywordPolicy(goutils.KeywordError))
+	ast.NoError(err)
+	ast.ErrorIs(hook.Send(context.Background(), "disk full"), goutils.ErrMissingKeyword)
+	ast.NoError(hook.Send(context.Background(), "告警: disk full"))
+	hook.Close()
+	ast.Equal([]string{"告警: disk full"}, webhook.Markdowns())
+	ast.True(logs.Contains("warn", goutils.ErrMissingKeyword.Error()))
+
+	_, err = goutils.NewAlertHook(webhook.Send, zerolog.ErrorLevel, goutils.WithKeyword(""))
+	ast.Error(err)
+	_, err = goutils.NewAlertHook(webhook.Send, zerolog.ErrorLevel, goutils.WithKeywordPolicy(2))
+	ast.Error(err)
+}
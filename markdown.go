@@ -0,0 +1,209 @@
+package goutils
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// MDBuilder builds the Markdown of an alert, as rendered by the DingTalk and Slack robots, escaping the text it is
+// given. The block methods, like H2, Code or Table, start a paragraph, the inline ones, like Bold or Link, continue
+// the current one. The methods return the builder to chain them:
+//
+//	md := NewMDBuilder().H2("Disk full").Text("on ").Bold(host).Code("", df)
+//	hook.Alert(md.String())
+type MDBuilder struct {
+	sb strings.Builder
+}
+
+// NewMDBuilder returns an empty MDBuilder
+func NewMDBuilder() *MDBuilder {
+	return &MDBuilder{}
+}
+
+// mdEscaper escapes the characters of the Markdown syntax in text
+var mdEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", "&lt;", ">", "&gt;", "#", `\#`, "|", `\|`,
+)
+
+// block starts a paragraph, the block methods end it with a blank line too, so that the next text is a paragraph
+func (b *MDBuilder) block() {
+	if b.sb.Len() == 0 {
+		return
+	}
+	s := b.sb.String()
+	switch {
+	case strings.HasSuffix(s, "\n\n"):
+	case strings.HasSuffix(s, "\n"):
+		b.sb.WriteString("\n")
+	default:
+		b.sb.WriteString("\n\n")
+	}
+}
+
+// H1 adds a level 1 heading
+func (b *MDBuilder) H1(text string) *MDBuilder {
+	return b.heading(1, text)
+}
+
+// H2 adds a level 2 heading
+func (b *MDBuilder) H2(text string) *MDBuilder {
+	return b.heading(2, text)
+}
+
+// H3 adds a level 3 heading
+func (b *MDBuilder) H3(text string) *MDBuilder {
+	return b.heading(3, text)
+}
+
+func (b *MDBuilder) heading(level int, text string) *MDBuilder {
+	b.block()
+	fmt.Fprintf(&b.sb, "%s %s\n\n", strings.Repeat("#", level), mdEscaper.Replace(oneLine(text)))
+	return b
+}
+
+// Text adds text to the current paragraph
+func (b *MDBuilder) Text(text string) *MDBuilder {
+	b.sb.WriteString(mdEscaper.Replace(text))
+	return b
+}
+
+// Bold adds bold text to the current paragraph
+func (b *MDBuilder) Bold(text string) *MDBuilder {
+	fmt.Fprintf(&b.sb, "**%s**", mdEscaper.Replace(text))
+	return b
+}
+
+// Color adds text in a color, like "#ff0000" or "ff0000", to the current paragraph
+func (b *MDBuilder) Color(text, hex string) *MDBuilder {
+	hex = strings.TrimPrefix(hex, "#")
+	fmt.Fprintf(&b.sb, `<font color="#%s">%s</font>`, mdEscaper.Replace(hex), mdEscaper.Replace(text))
+	return b
+}
+
+// Link adds a link to the current paragraph
+func (b *MDBuilder) Link(text, url string) *MDBuilder {
+	fmt.Fprintf(&b.sb, "[%s](%s)", mdEscaper.Replace(text), escapeMDURL(url))
+	return b
+}
+
+// Image adds an image, in a paragraph
+func (b *MDBuilder) Image(alt, url string) *MDBuilder {
+	b.block()
+	fmt.Fprintf(&b.sb, "![%s](%s)\n\n", mdEscaper.Replace(oneLine(alt)), escapeMDURL(url))
+	return b
+}
+
+// Line ends the current paragraph
+func (b *MDBuilder) Line() *MDBuilder {
+	b.block()
+	return b
+}
+
+// Quote adds a quote of text, which may have several lines
+func (b *MDBuilder) Quote(text string) *MDBuilder {
+	b.block()
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		fmt.Fprintf(&b.sb, "> %s\n", mdEscaper.Replace(line))
+	}
+	b.sb.WriteString("\n")
+	return b
+}
+
+// List adds a bulleted list of items
+func (b *MDBuilder) List(items ...string) *MDBuilder {
+	if len(items) == 0 {
+		return b
+	}
+	b.block()
+	for _, item := range items {
+		fmt.Fprintf(&b.sb, "- %s\n", mdEscaper.Replace(oneLine(item)))
+	}
+	b.sb.WriteString("\n")
+	return b
+}
+
+// Code adds a code block of code, not escaped, highlighted as lang if not empty.
+// The fence is longer than the backquotes in code.
+func (b *MDBuilder) Code(lang, code string) *MDBuilder {
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	b.block()
+	fmt.Fprintf(&b.sb, "%s%s\n%s\n%s\n\n", fence, oneLine(lang), strings.TrimRight(code, "\n"), fence)
+	return b
+}
+
+// Table adds a table, with the columns padded to align them in plain text. The rows shorter than headers are
+// completed with empty cells, and the headers of longer rows with empty headers. The pipes in the cells are
+// escaped, and their line breaks are replaced with <br>.
+func (b *MDBuilder) Table(headers []string, rows [][]string) *MDBuilder {
+	columns := len(headers)
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	if columns == 0 {
+		return b
+	}
+
+	cells := make([][]string, 0, len(rows)+1)
+	widths := make([]int, columns)
+	for _, row := range append([][]string{headers}, rows...) {
+		escaped := make([]string, columns)
+		for i := range columns {
+			if i < len(row) {
+				escaped[i] = mdEscaper.Replace(strings.ReplaceAll(strings.ReplaceAll(row[i], "\r\n", "\n"), "\n", "<br>"))
+				// the line breaks are the only HTML of the cells
+				escaped[i] = strings.ReplaceAll(escaped[i], "&lt;br&gt;", "<br>")
+			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(escaped[i]), 3)
+		}
+		cells = append(cells, escaped)
+	}
+
+	b.block()
+	writeRow := func(row []string) {
+		b.sb.WriteString("|")
+		for i, cell := range row {
+			fmt.Fprintf(&b.sb, " %s%s |", cell, strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
+		}
+		b.sb.WriteString("\n")
+	}
+	writeRow(cells[0])
+	separator := make([]string, columns)
+	for i, width := range widths {
+		separator[i] = strings.Repeat("-", width)
+	}
+	writeRow(separator)
+	for _, row := range cells[1:] {
+		writeRow(row)
+	}
+	b.sb.WriteString("\n")
+	return b
+}
+
+// Append adds the content of md, in a paragraph
+func (b *MDBuilder) Append(md *MDBuilder) *MDBuilder {
+	if md == nil || md.sb.Len() == 0 {
+		return b
+	}
+	b.block()
+	b.sb.WriteString(md.sb.String())
+	return b
+}
+
+// String returns the Markdown
+func (b *MDBuilder) String() string {
+	return strings.TrimRight(b.sb.String(), "\n")
+}
+
+// oneLine replaces the line breaks of text with spaces, for the single line elements
+func oneLine(text string) string {
+	return strings.TrimSpace(strings.NewReplacer("\r\n", " ", "\n", " ").Replace(text))
+}
+
+// escapeMDURL escapes the characters ending a Markdown link in url
+func escapeMDURL(url string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29", "<", "%3C", ">", "%3E").Replace(url)
+}
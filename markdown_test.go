@@ -0,0 +1,80 @@
+package goutils_test
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+	"github.com/117503445/goutils/testutil"
+)
+
+func TestMDBuilder(t *testing.T) {
+	ast := assert.New(t)
+
+	ast.Equal("# Disk full", goutils.NewMDBuilder().H1("Disk\nfull").String())
+	ast.Equal("## Disk \\#3 full\n\n### on web\\_1", goutils.NewMDBuilder().H2("Disk #3 full").H3("on web_1").String())
+	ast.Equal("host **web\\_1** is **down**", goutils.NewMDBuilder().Text("host ").Bold("web_1").Text(" is ").Bold("down").String())
+	ast.Equal(`<font color="#ff0000">error</font> and <font color="#00ff00">ok</font>`,
+		goutils.NewMDBuilder().Color("error", "#ff0000").Text(" and ").Color("ok", "00ff00").String())
+	ast.Equal("see [the \\[runbook\\]](https://wiki/a%20b%28c%29)",
+		goutils.NewMDBuilder().Text("see ").Link("the [runbook]", "https://wiki/a b(c)").String())
+	ast.Equal("graph:\n\n![cpu &lt;90%](https://grafana/cpu.png)",
+		goutils.NewMDBuilder().Text("graph:").Image("cpu <90%", "https://grafana/cpu.png").String())
+	ast.Equal("> first \\*line\\*\n> second line", goutils.NewMDBuilder().Quote("first *line*\nsecond line\n").String())
+	ast.Equal("- web1\n- web2", goutils.NewMDBuilder().List("web1", "web2").String())
+	ast.Equal("one\n\ntwo", goutils.NewMDBuilder().Text("one").Line().Text("two").String())
+	ast.Equal("## Alert\n\n- web1\n\nis down", goutils.NewMDBuilder().H2("Alert").List("web1").Text("is down").String())
+}
+
+func TestMDBuilderCode(t *testing.T) {
+	ast := assert.New(t)
+
+	ast.Equal("trace:\n\n```go\npanic: *nil* [recovered]\n```",
+		goutils.NewMDBuilder().Text("trace:").Code("go", "panic: *nil* [recovered]\n").String())
+	// the fence is longer than the code's
+	ast.Equal("````md\n```sh\nls\n```\n````", goutils.NewMDBuilder().Code("md", "```sh\nls\n```").String())
+}
+
+func TestMDBuilderTable(t *testing.T) {
+	ast := assert.New(t)
+
+	md := goutils.NewMDBuilder().Table([]string{"host", "status"}, [][]string{
+		{"web1", "ok"},
+		{"web2", "a|b", "extra"},
+		{"数据库"},
+		{"db2", "line1\nline2"},
+	})
+	ast.Equal(`| host | status         |       |
+| ---- | -------------- | ----- |
+| web1 | ok             |       |
+| web2 | a\|b           | extra |
+| 数据库  |                |       |
+| db2  | line1<br>line2 |       |`, md.String())
+
+	ast.Empty(goutils.NewMDBuilder().Table(nil, nil).String())
+}
+
+func TestMDBuilderAppend(t *testing.T) {
+	ast := assert.New(t)
+
+	webhook := testutil.NewWebhookRecorder(t, nil)
+	details := goutils.NewMDBuilder().Table([]string{"disk", "used"}, [][]string{{"/data", "98%"}})
+	md := goutils.NewMDBuilder().H2("Disk full").Text("on ").Bold("web1").Append(details).Append(nil).Quote("cleanup scheduled")
+
+	hook, err := goutils.NewAlertHook(webhook.Send, zerolog.ErrorLevel, goutils.WithMinInterval(0), goutils.WithRateLimit(0))
+	ast.NoError(err)
+	ast.NoError(hook.Alert(md.String()))
+	hook.Close()
+
+	ast.Equal([]string{`## Disk full
+
+on **web1**
+
+| disk  | used |
+| ----- | ---- |
+| /data | 98%  |
+
+> cleanup scheduled`}, webhook.Markdowns())
+}
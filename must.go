@@ -0,0 +1,57 @@
+package goutils
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// mustFail logs err at Fatal, which exits, with the location of the caller skip frames above mustFail
+func mustFail(skip int, err error) {
+	event := Logger.Fatal().CallerSkipFrame(skip + 1).Err(err)
+	if _, file, line, ok := runtime.Caller(skip + 1); ok {
+		event = event.Str("location", fmt.Sprintf("%s:%d", file, line))
+	}
+	event.Msg("Must failed")
+}
+
+// Must exits with a Fatal log of err, with the location of the call, if err is not nil.
+// It is meant for scripts and cmd tools, like
+//
+//	goutils.Must(goutils.WriteText("out.txt", text))
+func Must(err error) {
+	if err != nil {
+		mustFail(1, err)
+	}
+}
+
+// MustV returns v, or exits like Must if err is not nil, like
+//
+//	text := goutils.MustV(goutils.ReadText("in.txt"))
+func MustV[T any](v T, err error) T {
+	if err != nil {
+		mustFail(1, err)
+	}
+	return v
+}
+
+// MustOk returns v, or exits like Must if ok is false, for the funcs returning a value and an ok bool, like
+//
+//	home := goutils.MustOk(os.LookupEnv("HOME"))
+func MustOk[T any](v T, ok bool) T {
+	if !ok {
+		mustFail(1, fmt.Errorf("%T value not ok", v))
+	}
+	return v
+}
+
+// MustCtx returns a Must prefixing the errors with msg, like
+//
+//	must := goutils.MustCtx("load config")
+//	must(err) // load config: <err>
+func MustCtx(msg string) func(err error) {
+	return func(err error) {
+		if err != nil {
+			mustFail(1, fmt.Errorf("%s: %w", msg, err))
+		}
+	}
+}
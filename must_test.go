@@ -0,0 +1,72 @@
+package goutils_test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestMust(t *testing.T) {
+	ast := assert.New(t)
+
+	goutils.Must(nil)
+	ast.Equal("text", goutils.MustV("text", nil))
+	t.Setenv("GOUTILS_TEST_MUST", "text")
+	ast.Equal("text", goutils.MustOk(os.LookupEnv("GOUTILS_TEST_MUST")))
+	goutils.MustCtx("load config")(nil)
+}
+
+func TestMustFatal(t *testing.T) {
+	ast := assert.New(t)
+
+	switch os.Getenv("GOUTILS_TEST_MUST_FATAL") {
+	case "must":
+		goutils.InitZeroLog(goutils.WithJSONStdout{})
+		goutils.Must(errors.New("boom"))
+		return
+	case "ctx":
+		goutils.InitZeroLog(goutils.WithJSONStdout{})
+		goutils.MustCtx("load config")(errors.New("boom"))
+		return
+	case "env":
+		goutils.InitZeroLog(goutils.WithJSONStdout{})
+		goutils.MustEnv("GOUTILS_TEST_MUST_MISSING")
+		return
+	case "ok":
+		goutils.InitZeroLog(goutils.WithJSONStdout{})
+		goutils.MustOk(os.LookupEnv("GOUTILS_TEST_MUST_MISSING"))
+		return
+	}
+
+	run := func(mode string) string {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestMustFatal$")
+		cmd.Env = append(os.Environ(), "GOUTILS_TEST_MUST_FATAL="+mode)
+		out, err := cmd.CombinedOutput()
+		ast.Error(err, string(out))
+		return string(out)
+	}
+
+	out := run("must")
+	ast.Contains(out, `"level":"fatal"`)
+	ast.Contains(out, `"error":"boom"`)
+	// the location is the call site, not the helper
+	ast.Regexp(`"location":"[^"]*must_test.go:\d+"`, out)
+	ast.Regexp(`"caller":"[^"]*must_test.go:\d+"`, out)
+	ast.NotContains(out, "must.go")
+
+	out = run("ctx")
+	ast.Contains(out, `"error":"load config: boom"`)
+	ast.Regexp(`"location":"[^"]*must_test.go:\d+"`, out)
+
+	out = run("ok")
+	ast.Contains(out, `"error":"string value not ok"`)
+
+	out = run("env")
+	ast.Contains(out, `"error":"environment variable not set: GOUTILS_TEST_MUST_MISSING"`)
+	ast.Regexp(`"location":"[^"]*must_test.go:\d+"`, out)
+}
@@ -0,0 +1,92 @@
+package goutils
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// GetOutboundIP returns the local IP used to reach the internet, the one peers see on a flat network.
+// It is GetOutboundIPVia with 8.8.8.8:80, no packet is sent.
+func GetOutboundIP() (net.IP, error) {
+	return GetOutboundIPVia("8.8.8.8:80")
+}
+
+// GetOutboundIPVia returns the local IP of the route to probeAddr, like "10.0.0.1:80".
+// Connecting a UDP socket only selects the route, no packet is sent, so it works offline
+// as long as a route exists.
+func GetOutboundIPVia(probeAddr string) (net.IP, error) {
+	conn, err := net.Dial("udp", probeAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+// GetFreePort returns a TCP port free on all the interfaces, by binding the port 0
+func GetFreePort() (int, error) {
+	ports, err := GetFreePorts(1)
+	if err != nil {
+		return 0, err
+	}
+	return ports[0], nil
+}
+
+// GetFreePorts returns n distinct free TCP ports. They are all bound before being released,
+// so the system doesn't return the same port twice.
+func GetFreePorts(n int) ([]int, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("invalid number of ports %d", n)
+	}
+	listeners := make([]net.Listener, 0, n)
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+
+	ports := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		l, err := net.Listen("tcp", ":0")
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, l)
+		ports = append(ports, l.Addr().(*net.TCPAddr).Port)
+	}
+	return ports, nil
+}
+
+// HostnameFQDN returns the fully qualified domain name of the host, from the reverse lookup of its addresses,
+// or os.Hostname if none is found within a few seconds
+func HostnameFQDN() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, hostname)
+	if err != nil {
+		Logger.Debug().Err(err).Str("hostname", hostname).Msg("Failed to look up the hostname")
+		return hostname, nil
+	}
+	for _, addr := range addrs {
+		names, err := net.DefaultResolver.LookupAddr(ctx, addr)
+		if err != nil {
+			continue
+		}
+		for _, name := range names {
+			name = strings.TrimSuffix(name, ".")
+			if strings.Contains(name, ".") && !strings.HasPrefix(name, "localhost") {
+				return name, nil
+			}
+		}
+	}
+	return hostname, nil
+}
@@ -0,0 +1,61 @@
+package goutils_test
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestGetFreePorts(t *testing.T) {
+	ast := assert.New(t)
+
+	port, err := goutils.GetFreePort()
+	ast.NoError(err)
+	l, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	ast.NoError(err)
+	l.Close()
+
+	ports, err := goutils.GetFreePorts(5)
+	ast.NoError(err)
+	ast.Len(ports, 5)
+	ast.Len(goutils.Unique(ports), 5)
+	for _, port := range ports {
+		l, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+		if ast.NoError(err) {
+			defer l.Close()
+		}
+	}
+
+	ports, err = goutils.GetFreePorts(0)
+	ast.NoError(err)
+	ast.Empty(ports)
+	_, err = goutils.GetFreePorts(-1)
+	ast.Error(err)
+}
+
+func TestGetOutboundIP(t *testing.T) {
+	ast := assert.New(t)
+
+	ip, err := goutils.GetOutboundIPVia("127.0.0.1:80")
+	ast.NoError(err)
+	ast.True(ip.IsLoopback(), ip)
+
+	ip, err = goutils.GetOutboundIP()
+	if err != nil {
+		t.Skipf("no route to the internet: %v", err)
+	}
+	ast.False(ip.IsLoopback(), ip)
+	ast.False(ip.IsUnspecified(), ip)
+}
+
+func TestHostnameFQDN(t *testing.T) {
+	ast := assert.New(t)
+
+	fqdn, err := goutils.HostnameFQDN()
+	ast.NoError(err)
+	ast.NotEmpty(fqdn)
+}
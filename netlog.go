@@ -0,0 +1,149 @@
+package goutils
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// netDropped counts the events dropped by the writers of WithNetWriter
+var netDropped atomic.Int64
+
+// NetWriterDropped returns the number of events dropped by the writers of WithNetWriter,
+// because their queue was full or the connection failed without Reconnect
+func NetWriterDropped() int64 {
+	return netDropped.Load()
+}
+
+// WithNetWriter is a log option to also send the events as JSON to a log collector, like Vector or Fluent Bit,
+// one line per event over TCP, or one datagram per event over UDP.
+//
+// Events are queued and sent by a background goroutine, so logging never blocks on the network.
+// With Reconnect, a lost connection is dialed again with backoff, keeping the event being sent,
+// otherwise the events are dropped after the first failure. Timeout applies to dials and writes, 5s by default.
+// CloseLogs, or the next init, sends the queued events and closes the connection.
+type WithNetWriter struct {
+	Network   string
+	Addr      string
+	Timeout   time.Duration
+	Reconnect bool
+}
+
+func (w WithNetWriter) applyTo(o *logOptions) error {
+	switch w.Network {
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6", "unix", "unixgram":
+	default:
+		return fmt.Errorf("unsupported network: %q", w.Network)
+	}
+	if w.Timeout <= 0 {
+		w.Timeout = 5 * time.Second
+	}
+
+	nw := &netWriter{opt: w, queue: make(chan []byte, 1024), stop: make(chan struct{}), done: make(chan struct{})}
+	go nw.run()
+	o.Writers = append(o.Writers, nw)
+	o.closers = append(o.closers, nw)
+	return nil
+}
+
+// netWriter queues the events for a background goroutine sending them
+type netWriter struct {
+	opt   WithNetWriter
+	queue chan []byte
+	// stop interrupts the reconnection when closing, done is closed when run returns
+	stop chan struct{}
+	done chan struct{}
+
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+func (w *netWriter) Write(p []byte) (int, error) {
+	w.closeMu.RLock()
+	defer w.closeMu.RUnlock()
+	if w.closed {
+		netDropped.Add(1)
+		return len(p), nil
+	}
+
+	// p is reused by zerolog after Write returns
+	event := make([]byte, len(p))
+	copy(event, p)
+	select {
+	case w.queue <- event:
+	default:
+		netDropped.Add(1)
+	}
+	return len(p), nil
+}
+
+// Close sends the queued events, unless the connection is down, and closes the connection.
+// Later events are dropped.
+func (w *netWriter) Close() error {
+	w.closeMu.Lock()
+	if w.closed {
+		w.closeMu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	close(w.stop)
+	w.closeMu.Unlock()
+
+	<-w.done
+	return nil
+}
+
+func (w *netWriter) run() {
+	const minBackoff, maxBackoff = 100 * time.Millisecond, 5 * time.Second
+
+	defer close(w.done)
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	failed := false
+	backoff := minBackoff
+	for event := range w.queue {
+		for {
+			if failed {
+				netDropped.Add(1)
+				break
+			}
+
+			var err error
+			if conn == nil {
+				conn, err = net.DialTimeout(w.opt.Network, w.opt.Addr, w.opt.Timeout)
+			}
+			if err == nil {
+				conn.SetWriteDeadline(time.Now().Add(w.opt.Timeout))
+				_, err = conn.Write(event)
+			}
+			if err == nil {
+				backoff = minBackoff
+				break
+			}
+
+			if conn != nil {
+				conn.Close()
+				conn = nil
+			}
+			if !w.opt.Reconnect {
+				failed = true
+				continue
+			}
+			select {
+			case <-time.After(backoff):
+			case <-w.stop:
+				// closing, drop the events instead of reconnecting
+				failed = true
+				continue
+			}
+			backoff = min(2*backoff, maxBackoff)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package goutils_test
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+// lineServer collects the lines received by a TCP listener
+type lineServer struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	lines []string
+	conns []net.Conn
+}
+
+func startLineServer(t *testing.T, addr string) *lineServer {
+	ln, err := net.Listen("tcp", addr)
+	assert.NoError(t, err)
+	s := &lineServer{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+			go func() {
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					s.mu.Lock()
+					s.lines = append(s.lines, scanner.Text())
+					s.mu.Unlock()
+				}
+			}()
+		}
+	}()
+	return s
+}
+
+func (s *lineServer) received(substr string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, line := range s.lines {
+		if strings.Contains(line, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *lineServer) stop() {
+	s.ln.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func TestInitZeroLogWithNetWriter(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.InitZeroLog()
+
+	server := startLineServer(t, "127.0.0.1:0")
+	addr := server.ln.Addr().String()
+
+	captureStdout(t, func() {
+		goutils.InitZeroLog(goutils.WithJSONStdout{}, goutils.WithNetWriter{Network: "tcp", Addr: addr, Timeout: time.Second, Reconnect: true})
+	}, func() {
+		log.Info().Msg("before restart")
+		ast.Eventually(func() bool { return server.received("before restart") }, 5*time.Second, 10*time.Millisecond)
+
+		server.stop()
+		log.Info().Msg("while down")
+
+		server = startLineServer(t, addr)
+		defer server.stop()
+		ast.Eventually(func() bool {
+			log.Info().Msg("after restart")
+			return server.received("after restart")
+		}, 10*time.Second, 50*time.Millisecond)
+	})
+
+	_, err := goutils.InitZeroLogE(goutils.WithNetWriter{Network: "http", Addr: addr})
+	ast.Error(err)
+
+	// without Reconnect, the events are dropped once the connection failed
+	server.stop()
+	dropped := goutils.NetWriterDropped()
+	captureStdout(t, func() {
+		goutils.InitZeroLog(goutils.WithJSONStdout{}, goutils.WithNetWriter{Network: "tcp", Addr: addr, Timeout: time.Second})
+	}, func() {
+		for i := 0; i < 3; i++ {
+			log.Info().Msg("dropped")
+		}
+	})
+	ast.Eventually(func() bool { return goutils.NetWriterDropped() >= dropped+3 }, 5*time.Second, 10*time.Millisecond)
+
+	// CloseLogs sends the queued events, and stops reconnecting
+	server = startLineServer(t, "127.0.0.1:0")
+	defer server.stop()
+	captureStdout(t, func() {
+		goutils.InitZeroLog(goutils.WithJSONStdout{}, goutils.WithNetWriter{Network: "tcp", Addr: server.ln.Addr().String(), Reconnect: true})
+	}, func() {
+		log.Info().Msg("before close")
+		ast.NoError(goutils.CloseLogs())
+		ast.Eventually(func() bool { return server.received("before close") }, 5*time.Second, 10*time.Millisecond)
+	})
+	captureStdout(t, func() {
+		goutils.InitZeroLog(goutils.WithJSONStdout{}, goutils.WithNetWriter{Network: "tcp", Addr: addr, Reconnect: true})
+	}, func() {
+		log.Info().Msg("never sent")
+		start := time.Now()
+		ast.NoError(goutils.CloseLogs())
+		ast.Less(time.Since(start), 5*time.Second)
+	})
+}
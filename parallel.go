@@ -0,0 +1,107 @@
+package goutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ParallelOption is an option for ParallelMap
+type ParallelOption interface {
+	applyTo(*parallelOptions) error
+}
+
+type parallelOptions struct {
+	CollectAll bool
+}
+
+// WithCollectAll is a parallel option to run all the items despite errors, and return all their errors joined,
+// instead of cancelling the others at the first error
+type WithCollectAll struct {
+}
+
+func (w WithCollectAll) applyTo(o *parallelOptions) error {
+	o.CollectAll = true
+	return nil
+}
+
+// ParallelMap calls fn on items, with at most limit calls at once, or all at once if limit <= 0,
+// and returns the results in the order of items.
+//
+// At the first error, the context of the running calls is cancelled, no more calls start, and the error is
+// returned, unless WithCollectAll is set. The errors and the panics of fn are wrapped with the index of the item.
+// When ctx is done, no more calls start and ctx.Err() is returned. The results of the failed items are zero.
+func ParallelMap[T, R any](ctx context.Context, items []T, limit int, fn func(context.Context, T) (R, error), opts ...ParallelOption) ([]R, error) {
+	opt := &parallelOptions{}
+	for _, o := range opts {
+		if err := o.applyTo(opt); err != nil {
+			return nil, err
+		}
+	}
+	if limit <= 0 || limit > len(items) {
+		limit = len(items)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]R, len(items))
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	sem := make(chan struct{}, limit)
+
+	call := func(i int) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("item %d: panic: %v", i, r)
+			}
+		}()
+		results[i], err = fn(ctx, items[i])
+		if err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+		return nil
+	}
+
+dispatch:
+	for i := range items {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break dispatch
+		}
+		// a slot may be free although ctx is already done
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := call(i); err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				// after the first error, the others are likely the cancellation of the context
+				if opt.CollectAll || len(errs) == 0 {
+					errs = append(errs, err)
+				}
+				if !opt.CollectAll {
+					cancel()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// without errors, or when collecting them all, a done context is the parent one
+	if err := ctx.Err(); err != nil && (len(errs) == 0 || opt.CollectAll) {
+		errs = append(errs, err)
+	}
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,109 @@
+package goutils_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestParallelMap(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	items := make([]int, 50)
+	for i := range items {
+		items[i] = i
+	}
+
+	// the results are in order, with at most limit calls at once
+	var running, highWater atomic.Int32
+	results, err := goutils.ParallelMap(ctx, items, 4, func(ctx context.Context, v int) (string, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			high := highWater.Load()
+			if n <= high || highWater.CompareAndSwap(high, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return fmt.Sprint(v * 2), nil
+	})
+	ast.NoError(err)
+	ast.Len(results, 50)
+	for i, r := range results {
+		ast.Equal(fmt.Sprint(i*2), r)
+	}
+	ast.LessOrEqual(highWater.Load(), int32(4))
+	ast.Greater(highWater.Load(), int32(1))
+
+	empty, err := goutils.ParallelMap(ctx, []int{}, 0, func(ctx context.Context, v int) (int, error) { return v, nil })
+	ast.NoError(err)
+	ast.Empty(empty)
+}
+
+func TestParallelMapErrors(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	errOdd := errors.New("odd")
+
+	// fail fast: the running calls are cancelled, no more calls start
+	var started atomic.Int32
+	_, err := goutils.ParallelMap(ctx, []int{0, 1, 2, 3, 4, 5, 6, 7}, 2, func(ctx context.Context, v int) (int, error) {
+		started.Add(1)
+		if v == 1 {
+			return 0, errOdd
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(10 * time.Second):
+			return v, nil
+		}
+	})
+	ast.ErrorIs(err, errOdd)
+	ast.EqualError(err, "item 1: odd")
+	ast.Less(started.Load(), int32(8))
+
+	// collect all
+	results, err := goutils.ParallelMap(ctx, []int{0, 1, 2, 3}, 2, func(ctx context.Context, v int) (int, error) {
+		if v%2 == 1 {
+			return 0, errOdd
+		}
+		return v * 10, nil
+	}, goutils.WithCollectAll{})
+	ast.ErrorContains(err, "item 1: odd")
+	ast.ErrorContains(err, "item 3: odd")
+	ast.Equal([]int{0, 0, 20, 0}, results)
+
+	// panics are errors
+	_, err = goutils.ParallelMap(ctx, []string{"a", "b"}, 0, func(ctx context.Context, v string) (int, error) {
+		if v == "b" {
+			panic("boom")
+		}
+		return 1, nil
+	})
+	ast.EqualError(err, "item 1: panic: boom")
+}
+
+func TestParallelMapCancel(t *testing.T) {
+	ast := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var started atomic.Int32
+	_, err := goutils.ParallelMap(ctx, make([]int, 10), 1, func(ctx context.Context, v int) (int, error) {
+		if started.Add(1) == 2 {
+			cancel()
+		}
+		return v, nil
+	})
+	ast.ErrorIs(err, context.Canceled)
+	ast.Equal(int32(2), started.Load())
+}
@@ -0,0 +1,48 @@
+package goutils
+
+// Ptr returns a pointer to a copy of v, for the SDK fields taking pointers, like Ptr("name") or Ptr(3)
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// Deref returns the value p points to, or def if p is nil.
+// For a pointer to a pointer, only the outer one is dereferenced: the result may still be a nil pointer.
+func Deref[T any](p *T, def T) T {
+	if p == nil {
+		return def
+	}
+	return *p
+}
+
+// DerefOr returns the value p points to and true, or the zero value and false if p is nil
+func DerefOr[T any](p *T) (T, bool) {
+	if p == nil {
+		var zero T
+		return zero, false
+	}
+	return *p, true
+}
+
+// PtrSlice returns pointers to copies of the elements of s, or nil if s is nil
+func PtrSlice[T any](s []T) []*T {
+	if s == nil {
+		return nil
+	}
+	ps := make([]*T, len(s))
+	for i := range s {
+		ps[i] = Ptr(s[i])
+	}
+	return ps
+}
+
+// DerefSlice returns the values the elements of ps point to, def for the nil ones, or nil if ps is nil
+func DerefSlice[T any](ps []*T, def T) []T {
+	if ps == nil {
+		return nil
+	}
+	s := make([]T, len(ps))
+	for i, p := range ps {
+		s[i] = Deref(p, def)
+	}
+	return s
+}
@@ -0,0 +1,59 @@
+package goutils_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestPtr(t *testing.T) {
+	ast := assert.New(t)
+
+	type point struct{ X, Y int }
+
+	ast.Equal(3, *goutils.Ptr(3))
+	ast.Equal("name", *goutils.Ptr("name"))
+	ast.Equal(point{1, 2}, *goutils.Ptr(point{1, 2}))
+
+	// the pointer is to a copy
+	v := 1
+	p := goutils.Ptr(v)
+	*p = 2
+	ast.Equal(1, v)
+
+	ast.Equal(3, goutils.Deref(goutils.Ptr(3), 7))
+	ast.Equal(7, goutils.Deref(nil, 7))
+	ast.Equal("", goutils.Deref[string](nil, ""))
+	ast.Equal(point{}, goutils.Deref(nil, point{}))
+
+	s, ok := goutils.DerefOr(goutils.Ptr("name"))
+	ast.True(ok)
+	ast.Equal("name", s)
+	s, ok = goutils.DerefOr[string](nil)
+	ast.False(ok)
+	ast.Equal("", s)
+
+	// only the outer pointer is dereferenced
+	var inner *int
+	ast.Nil(goutils.Deref(&inner, goutils.Ptr(5)))
+	ast.Equal(5, *goutils.Deref(nil, goutils.Ptr(5)))
+}
+
+func TestPtrSlice(t *testing.T) {
+	ast := assert.New(t)
+
+	ast.Nil(goutils.PtrSlice[int](nil))
+	ast.Nil(goutils.DerefSlice[int](nil, 0))
+	ast.Equal([]*int{}, goutils.PtrSlice([]int{}))
+
+	s := []string{"a", "b"}
+	ps := goutils.PtrSlice(s)
+	ast.Len(ps, 2)
+	ast.Equal("a", *ps[0])
+	*ps[0] = "c"
+	ast.Equal("a", s[0])
+
+	ast.Equal([]string{"c", "default", "b"}, goutils.DerefSlice([]*string{ps[0], nil, ps[1]}, "default"))
+}
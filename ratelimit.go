@@ -0,0 +1,71 @@
+package goutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is the error of an action refused by a RateLimiter
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimiter is a token bucket allowing limit actions per window, in bursts of up to limit,
+// like the 20 messages per minute of a DingTalk robot. It is safe for concurrent use, and reads the time
+// through the package Clock.
+type RateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a RateLimiter allowing limit actions per window, starting full
+func NewRateLimiter(limit int, window time.Duration) (*RateLimiter, error) {
+	if limit <= 0 || window <= 0 {
+		return nil, fmt.Errorf("invalid rate limit: %d per %v", limit, window)
+	}
+	return &RateLimiter{limit: limit, window: window, tokens: float64(limit), last: GetClock().Now()}, nil
+}
+
+// refillLocked adds the tokens earned since the last refill
+func (l *RateLimiter) refillLocked() {
+	now := GetClock().Now()
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = min(float64(l.limit), l.tokens+float64(l.limit)*float64(elapsed)/float64(l.window))
+	}
+	l.last = now
+}
+
+// Allow takes a token and returns true if one is available, or else returns false at once
+func (l *RateLimiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refillLocked()
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// Wait takes a token, waiting until one is available or ctx is done, and then returns ctx.Err()
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		l.refillLocked()
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - l.tokens) * float64(l.window) / float64(l.limit))
+		l.mu.Unlock()
+
+		if err := GetClock().Sleep(ctx, max(wait, time.Millisecond)); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package goutils_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+	"github.com/117503445/goutils/testutil"
+)
+
+func TestRateLimiter(t *testing.T) {
+	ast := assert.New(t)
+
+	clock := testutil.NewFakeClock(time.Date(2024, 9, 15, 22, 12, 19, 0, time.UTC))
+	defer goutils.SetClock(goutils.SetClock(clock))
+
+	limiter, err := goutils.NewRateLimiter(20, time.Minute)
+	ast.NoError(err)
+
+	// a burst of 20, then a token every 3s
+	allowed := 0
+	for range 25 {
+		if limiter.Allow() {
+			allowed++
+		}
+	}
+	ast.Equal(20, allowed)
+	clock.Advance(2 * time.Second)
+	ast.False(limiter.Allow())
+	clock.Advance(time.Second)
+	ast.True(limiter.Allow())
+	ast.False(limiter.Allow())
+
+	// the bucket holds at most the limit
+	clock.Advance(time.Hour)
+	allowed = 0
+	for range 25 {
+		if limiter.Allow() {
+			allowed++
+		}
+	}
+	ast.Equal(20, allowed)
+
+	_, err = goutils.NewRateLimiter(0, time.Minute)
+	ast.Error(err)
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	ast := assert.New(t)
+
+	// 25 concurrent waits against a shrunken window
+	limiter, err := goutils.NewRateLimiter(20, 200*time.Millisecond)
+	ast.NoError(err)
+	var done atomic.Int64
+	var wg sync.WaitGroup
+	start := time.Now()
+	for range 25 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ast.NoError(limiter.Wait(context.Background()))
+			done.Add(1)
+		}()
+	}
+	wg.Wait()
+	ast.EqualValues(25, done.Load())
+	// the last 5 wait for 5 tokens, 10ms each
+	ast.GreaterOrEqual(time.Since(start), 40*time.Millisecond)
+
+	// cancelled while waiting
+	clock := testutil.NewFakeClock(time.Now())
+	defer goutils.SetClock(goutils.SetClock(clock))
+	limiter, err = goutils.NewRateLimiter(1, time.Hour)
+	ast.NoError(err)
+	ast.NoError(limiter.Wait(context.Background()))
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() {
+		errs <- limiter.Wait(ctx)
+	}()
+	clock.BlockUntil(1)
+	cancel()
+	ast.ErrorIs(<-errs, context.Canceled)
+}
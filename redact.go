@@ -0,0 +1,130 @@
+package goutils
+
+import (
+	"io"
+	neturl "net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// defaultRedactedKeys are the field names redacted by WithRedaction, unless NoDefaultKeys is set
+var defaultRedactedKeys = []string{"password", "passwd", "token", "secret", "authorization", "api_key", "apikey"}
+
+// urlSignatureKeys are the query keys of URL signatures, redacted by RedactURL besides defaultRedactedKeys
+var urlSignatureKeys = []string{"sign", "signature", "sig", "x-amz-signature"}
+
+// RedactURL returns rawURL with "***" instead of the password of its user info, and of the values of the query
+// parameters whose names contain one of the default keys of WithRedaction, or are a signature like "sign",
+// as in the signed webhook URLs. An unparsable rawURL is returned as "***".
+func RedactURL(rawURL string) string {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return "***"
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = neturl.UserPassword(u.User.Username(), "***")
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key, values := range query {
+			lower := strings.ToLower(key)
+			sensitive := slices.Contains(urlSignatureKeys, lower) || slices.ContainsFunc(defaultRedactedKeys, func(k string) bool {
+				return strings.Contains(lower, k)
+			})
+			if sensitive {
+				for i := range values {
+					values[i] = "***"
+				}
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	// keep the stars readable
+	return strings.ReplaceAll(u.String(), "%2A%2A%2A", "***")
+}
+
+// WithRedaction is a log option to replace sensitive values with "***" in all outputs.
+//
+// The values of the fields whose names contain one of Keys, case-insensitively, are redacted,
+// as well as the matches of Patterns inside any string, like the message.
+// Keys include password, token, secret, authorization and api keys, unless NoDefaultKeys is set.
+// The logger of WithLogger is not redacted, as its writer can't be wrapped.
+type WithRedaction struct {
+	Keys          []string
+	Patterns      []*regexp.Regexp
+	NoDefaultKeys bool
+}
+
+func (w WithRedaction) applyTo(o *logOptions) error {
+	keys := w.Keys
+	if !w.NoDefaultKeys {
+		keys = append(append([]string{}, defaultRedactedKeys...), keys...)
+	}
+
+	r := &redactor{patterns: w.Patterns}
+	if len(keys) > 0 {
+		quoted := make([]string, len(keys))
+		for i, k := range keys {
+			quoted[i] = regexp.QuoteMeta(k)
+		}
+		// a JSON key containing one of the keys, and its string, number, boolean or null value
+		r.keys = regexp.MustCompile(`("[^"]*(?i:` + strings.Join(quoted, "|") + `)[^"]*"\s*:\s*)` +
+			`(?:"(?:[^"\\]|\\.)*"|-?[0-9][^,}\]]*|true|false|null)`)
+	}
+	o.Redactor = r
+	return nil
+}
+
+// jsonString matches a JSON string
+var jsonString = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`)
+
+// redactor redacts the serialized JSON events
+type redactor struct {
+	keys     *regexp.Regexp
+	patterns []*regexp.Regexp
+}
+
+func (r *redactor) redact(p []byte) []byte {
+	if r.keys != nil {
+		p = r.keys.ReplaceAll(p, []byte(`${1}"***"`))
+	}
+	if len(r.patterns) > 0 {
+		// only inside strings, so the JSON stays valid
+		p = jsonString.ReplaceAllFunc(p, func(s []byte) []byte {
+			inner := s[1 : len(s)-1]
+			for _, pattern := range r.patterns {
+				inner = pattern.ReplaceAll(inner, []byte("***"))
+			}
+			return append(append([]byte{'"'}, inner...), '"')
+		})
+	}
+	return p
+}
+
+// redactWriter redacts the events before writing them to w
+type redactWriter struct {
+	w        zerolog.LevelWriter
+	redactor *redactor
+}
+
+func newRedactWriter(w io.Writer, r *redactor) *redactWriter {
+	lw, ok := w.(zerolog.LevelWriter)
+	if !ok {
+		lw = zerolog.MultiLevelWriter(w)
+	}
+	return &redactWriter{w: lw, redactor: r}
+}
+
+func (w *redactWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+func (w *redactWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if _, err := w.w.WriteLevel(level, w.redactor.redact(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,18 @@
+package goutils
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflinkFile clones src to dst with clonefile, which requires dst to be missing
+func reflinkFile(src, dst string, mode os.FileMode) error {
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW); err != nil {
+		return err
+	}
+	return os.Chmod(dst, mode.Perm())
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package goutils
+
+import (
+	"errors"
+	"os"
+)
+
+func reflinkFile(src, dst string, mode os.FileMode) error {
+	return errors.ErrUnsupported
+}
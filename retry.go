@@ -0,0 +1,150 @@
+package goutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryOption is an option for Retry and RetryV
+type RetryOption interface {
+	applyTo(*retryOptions) error
+}
+
+type retryOptions struct {
+	Attempts int
+	Backoff  WithBackoff
+	Jitter   float64
+	RetryIf  func(error) bool
+	Clock    Clock
+}
+
+// WithAttempts is a retry option to set the maximum number of attempts, 3 by default
+type WithAttempts int
+
+func (w WithAttempts) applyTo(o *retryOptions) error {
+	if w <= 0 {
+		return fmt.Errorf("invalid attempts: %d", w)
+	}
+	o.Attempts = int(w)
+	return nil
+}
+
+// WithBackoff is a retry option to wait Initial after the first failed attempt, then Factor times longer
+// plus Increment after each one, up to Max. By default, 100ms doubled up to 10s.
+// A Factor of 1 waits a constant Initial, or linearly longer with an Increment, like 1s, 2s, 3s for both 1s.
+type WithBackoff struct {
+	Initial   time.Duration
+	Max       time.Duration
+	Factor    float64
+	Increment time.Duration
+}
+
+func (w WithBackoff) applyTo(o *retryOptions) error {
+	if w.Initial < 0 || w.Max < 0 || w.Factor < 1 || w.Increment < 0 {
+		return fmt.Errorf("invalid backoff: initial %v, max %v, factor %v, increment %v", w.Initial, w.Max, w.Factor, w.Increment)
+	}
+	o.Backoff = w
+	return nil
+}
+
+// WithJitter is a retry option to randomize the waits by up to this fraction, e.g. 0.2 for ±20%,
+// so that clients failing together don't retry together
+type WithJitter float64
+
+func (w WithJitter) applyTo(o *retryOptions) error {
+	if w < 0 || w > 1 {
+		return fmt.Errorf("invalid jitter: %v", w)
+	}
+	o.Jitter = float64(w)
+	return nil
+}
+
+// WithRetryIf is a retry option to retry only the errors it returns true for, the others are returned at once
+type WithRetryIf func(error) bool
+
+func (w WithRetryIf) applyTo(o *retryOptions) error {
+	o.RetryIf = w
+	return nil
+}
+
+// RetryAfterError is an error telling Retry how long to wait before the next attempt
+type RetryAfterError interface {
+	error
+	RetryAfter() time.Duration
+}
+
+// Retry calls fn until it succeeds, or the attempts are exhausted, waiting between the attempts as set by
+// WithBackoff. The last error is returned wrapped with the number of attempts.
+//
+// An error of fn wrapping a RetryAfterError sets the next wait instead of the backoff, e.g. from the
+// Retry-After header of an HTTP response.
+//
+// Retry stops when ctx is done, between the attempts or during a wait, and the error then wraps both ctx.Err()
+// and the last error of fn.
+func Retry(ctx context.Context, fn func() error, opts ...RetryOption) error {
+	_, err := RetryV(ctx, func() (struct{}, error) {
+		return struct{}{}, fn()
+	}, opts...)
+	return err
+}
+
+// RetryV is like Retry, for a fn returning a value, which is returned on success
+func RetryV[T any](ctx context.Context, fn func() (T, error), opts ...RetryOption) (T, error) {
+	var zero T
+	opt := &retryOptions{
+		Attempts: 3,
+		Backoff: WithBackoff{
+			Initial: 100 * time.Millisecond,
+			Max:     10 * time.Second,
+			Factor:  2,
+		},
+	}
+	for _, o := range opts {
+		if err := o.applyTo(opt); err != nil {
+			return zero, err
+		}
+	}
+	if opt.Clock == nil {
+		opt.Clock = GetClock()
+	}
+
+	wait := opt.Backoff.Initial
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			if lastErr == nil {
+				return zero, err
+			}
+			return zero, fmt.Errorf("%w after %d attempts: %w", err, attempt-1, lastErr)
+		}
+
+		v, err := fn()
+		if err == nil {
+			return v, nil
+		}
+		lastErr = err
+		if attempt >= opt.Attempts || (opt.RetryIf != nil && !opt.RetryIf(err)) {
+			return zero, fmt.Errorf("failed after %d attempts: %w", attempt, err)
+		}
+
+		d := wait
+		if opt.Jitter > 0 {
+			d = time.Duration(float64(d) * (1 + opt.Jitter*(2*rand.Float64()-1)))
+		}
+		var retryAfter RetryAfterError
+		if errors.As(err, &retryAfter) && retryAfter.RetryAfter() > 0 {
+			d = retryAfter.RetryAfter()
+		}
+		if sleepErr := opt.Clock.Sleep(ctx, d); sleepErr != nil {
+			return zero, fmt.Errorf("%w after %d attempts: %w", sleepErr, attempt, err)
+		}
+
+		wait = time.Duration(float64(wait)*opt.Backoff.Factor) + opt.Backoff.Increment
+		if opt.Backoff.Max > 0 && wait > opt.Backoff.Max {
+			wait = opt.Backoff.Max
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package goutils_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+	"github.com/117503445/goutils/testutil"
+)
+
+func TestRetry(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	backoff := goutils.WithBackoff{Initial: time.Millisecond, Max: 5 * time.Millisecond, Factor: 2}
+
+	// success after 2 failures
+	calls := 0
+	err := goutils.Retry(ctx, func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("flaky")
+		}
+		return nil
+	}, backoff, goutils.WithJitter(0.5))
+	ast.NoError(err)
+	ast.Equal(3, calls)
+
+	// the attempts are exhausted
+	errFlaky := errors.New("flaky")
+	calls = 0
+	err = goutils.Retry(ctx, func() error {
+		calls++
+		return errFlaky
+	}, backoff, goutils.WithAttempts(5))
+	ast.ErrorIs(err, errFlaky)
+	ast.ErrorContains(err, "failed after 5 attempts")
+	ast.Equal(5, calls)
+
+	// permanent errors are returned at once
+	errPermanent := errors.New("permanent")
+	calls = 0
+	err = goutils.Retry(ctx, func() error {
+		calls++
+		return errPermanent
+	}, backoff, goutils.WithRetryIf(func(err error) bool {
+		return !errors.Is(err, errPermanent)
+	}))
+	ast.ErrorIs(err, errPermanent)
+	ast.Equal(1, calls)
+
+	_, err = goutils.RetryV(ctx, func() (int, error) { return 0, nil }, goutils.WithAttempts(0))
+	ast.Error(err)
+}
+
+func TestRetryV(t *testing.T) {
+	ast := assert.New(t)
+
+	calls := 0
+	v, err := goutils.RetryV(context.Background(), func() (string, error) {
+		calls++
+		if calls < 2 {
+			return "", errors.New("flaky")
+		}
+		return "ok", nil
+	}, goutils.WithBackoff{Initial: time.Millisecond, Factor: 1})
+	ast.NoError(err)
+	ast.Equal("ok", v)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	ast := assert.New(t)
+
+	start := time.Date(2024, 9, 15, 22, 12, 19, 0, time.UTC)
+	clock := testutil.NewFakeClock(start)
+
+	calls := make(chan time.Time, 10)
+	errs := make(chan error)
+	go func() {
+		errs <- goutils.Retry(context.Background(), func() error {
+			calls <- clock.Now()
+			return errors.New("flaky")
+		}, goutils.WithClock{Clock: clock}, goutils.WithAttempts(5),
+			goutils.WithBackoff{Initial: 100 * time.Millisecond, Max: 300 * time.Millisecond, Factor: 2})
+	}()
+
+	// the waits double up to Max
+	for _, wait := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond} {
+		clock.BlockUntil(1)
+		clock.Advance(wait - time.Millisecond)
+		ast.Equal(1, clock.Sleepers())
+		clock.Advance(time.Millisecond)
+	}
+	ast.ErrorContains(<-errs, "failed after 5 attempts")
+
+	close(calls)
+	var elapsed []time.Duration
+	for call := range calls {
+		elapsed = append(elapsed, call.Sub(start))
+	}
+	ast.Equal([]time.Duration{0, 100 * time.Millisecond, 300 * time.Millisecond, 600 * time.Millisecond, 900 * time.Millisecond}, elapsed)
+}
+
+func TestRetryCancel(t *testing.T) {
+	ast := assert.New(t)
+
+	// cancelled during the backoff wait
+	clock := testutil.NewFakeClock(time.Now())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errFlaky := errors.New("flaky")
+	calls := 0
+	errs := make(chan error)
+	go func() {
+		errs <- goutils.Retry(ctx, func() error {
+			calls++
+			return errFlaky
+		}, goutils.WithClock{Clock: clock}, goutils.WithBackoff{Initial: time.Hour, Factor: 2})
+	}()
+	clock.BlockUntil(1)
+	cancel()
+	err := <-errs
+	ast.ErrorIs(err, context.Canceled)
+	ast.ErrorIs(err, errFlaky)
+	ast.Equal(1, calls)
+
+	// cancelled before the first attempt
+	calls = 0
+	err = goutils.Retry(ctx, func() error {
+		calls++
+		return nil
+	})
+	ast.ErrorIs(err, context.Canceled)
+	ast.Zero(calls)
+}
+
+type retryAfterError struct {
+	after time.Duration
+}
+
+func (e retryAfterError) Error() string {
+	return "throttled"
+}
+
+func (e retryAfterError) RetryAfter() time.Duration {
+	return e.after
+}
+
+func TestRetryAfter(t *testing.T) {
+	ast := assert.New(t)
+
+	start := time.Date(2024, 9, 15, 22, 12, 19, 0, time.UTC)
+	clock := testutil.NewFakeClock(start)
+
+	calls := make(chan time.Time, 10)
+	errs := make(chan error)
+	go func() {
+		errs <- goutils.Retry(context.Background(), func() error {
+			calls <- clock.Now()
+			if len(calls) == 1 {
+				return fmt.Errorf("request: %w", retryAfterError{after: time.Minute})
+			}
+			return errors.New("flaky")
+		}, goutils.WithClock{Clock: clock}, goutils.WithAttempts(3),
+			goutils.WithBackoff{Initial: 100 * time.Millisecond, Factor: 2})
+	}()
+
+	// the first wait is set by the error, then the backoff goes on
+	for _, wait := range []time.Duration{time.Minute, 200 * time.Millisecond} {
+		clock.BlockUntil(1)
+		clock.Advance(wait)
+	}
+	ast.ErrorContains(<-errs, "failed after 3 attempts")
+
+	close(calls)
+	var elapsed []time.Duration
+	for call := range calls {
+		elapsed = append(elapsed, call.Sub(start))
+	}
+	ast.Equal([]time.Duration{0, time.Minute, time.Minute + 200*time.Millisecond}, elapsed)
+}
+
+func TestRetryLinearBackoff(t *testing.T) {
+	ast := assert.New(t)
+
+	clock := testutil.NewFakeClock(time.Date(2024, 9, 15, 22, 12, 19, 0, time.UTC))
+	errs := make(chan error)
+	go func() {
+		errs <- goutils.Retry(context.Background(), func() error {
+			return errors.New("flaky")
+		}, goutils.WithClock{Clock: clock}, goutils.WithAttempts(5),
+			goutils.WithBackoff{Initial: time.Second, Max: 3500 * time.Millisecond, Factor: 1, Increment: time.Second})
+	}()
+
+	// the waits grow by Increment up to Max
+	for _, wait := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3500 * time.Millisecond} {
+		clock.BlockUntil(1)
+		clock.Advance(wait - time.Millisecond)
+		ast.Equal(1, clock.Sleepers())
+		clock.Advance(time.Millisecond)
+	}
+	ast.ErrorContains(<-errs, "failed after 5 attempts")
+
+	ast.Error(goutils.Retry(context.Background(), func() error { return nil },
+		goutils.WithBackoff{Initial: time.Second, Factor: 1, Increment: -time.Second}))
+}
+
+func TestRetryJitter(t *testing.T) {
+	ast := assert.New(t)
+
+	clock := testutil.NewFakeClock(time.Date(2024, 9, 15, 22, 12, 19, 0, time.UTC))
+	errs := make(chan error)
+	go func() {
+		errs <- goutils.Retry(context.Background(), func() error {
+			return errors.New("flaky")
+		}, goutils.WithClock{Clock: clock}, goutils.WithAttempts(4), goutils.WithJitter(0.25),
+			goutils.WithBackoff{Initial: time.Second, Factor: 2})
+	}()
+
+	// each wait stays within ±25% of the exponential one
+	for _, wait := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		low, high := wait*3/4, wait*5/4
+		clock.BlockUntil(1)
+		clock.Advance(low - time.Millisecond)
+		ast.Equal(1, clock.Sleepers())
+		clock.Advance(high - low + time.Millisecond)
+	}
+	ast.ErrorContains(<-errs, "failed after 4 attempts")
+}
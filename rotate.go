@@ -0,0 +1,340 @@
+package goutils
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotatingFile is an io.Writer appending to Filename, safe for concurrent use.
+// When a write would make the file larger than MaxSize, the file is renamed to a backup
+// named with the rotation time, like app-20240915.221219.123.jsonl for app.jsonl, and a new file is opened.
+// It is used by WithProduction, but can be passed to any logger.
+type RotatingFile struct {
+	Filename string
+	// MaxSize is the size in bytes above which the file is rotated, 0 means no rotation
+	MaxSize int64
+	// MaxAge is the age above which backups are removed on rotation, 0 keeps them
+	MaxAge time.Duration
+	// MaxBackups is the number of backups kept on rotation, 0 keeps them all
+	MaxBackups int
+	// Compress gzips the backups, as app-20240915.221219.123.jsonl.gz
+	Compress bool
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+
+	// background serializes the compression and pruning of the backups, done outside mu
+	// as logging their errors may write to this file
+	background sync.Mutex
+	pending    sync.WaitGroup
+}
+
+// Write writes p to the file, rotating it first if needed. p is never split across files.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.MaxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.MaxSize {
+		backup, err := r.rotate()
+		if err != nil {
+			return 0, err
+		}
+		r.cleanup(backup)
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates the file now, even if it is smaller than MaxSize
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	backup, err := r.rotate()
+	if err != nil {
+		return err
+	}
+	r.cleanup(backup)
+	return nil
+}
+
+// Close waits for the compression and pruning of the backups, and closes the file. A later Write opens it again.
+func (r *RotatingFile) Close() error {
+	r.pending.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.Filename), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(r.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+// rotate renames the file to a backup, returned, and opens a new file, r.mu must be held
+func (r *RotatingFile) rotate() (string, error) {
+	if err := r.file.Close(); err != nil {
+		return "", err
+	}
+	r.file = nil
+
+	ext := filepath.Ext(r.Filename)
+	backup := strings.TrimSuffix(r.Filename, ext) + "-" + TimeStrMilliSec() + ext
+	for PathExists(backup) || PathExists(backup+".gz") {
+		// a rotation was already done in this millisecond
+		time.Sleep(time.Millisecond)
+		backup = strings.TrimSuffix(r.Filename, ext) + "-" + TimeStrMilliSec() + ext
+	}
+	if err := os.Rename(r.Filename, backup); err != nil {
+		return "", err
+	}
+	if err := r.open(); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
+
+// cleanup compresses backup and removes the old backups in the background, without blocking the writes
+func (r *RotatingFile) cleanup(backup string) {
+	r.pending.Add(1)
+	go func() {
+		defer r.pending.Done()
+		r.background.Lock()
+		defer r.background.Unlock()
+
+		if r.Compress {
+			if err := gzipFile(backup); err != nil {
+				Logger.Warn().Err(err).Str("file", backup).Msg("Failed to compress rotated log file")
+			}
+		}
+		if err := r.prune(); err != nil {
+			Logger.Warn().Err(err).Str("file", r.Filename).Msg("Failed to remove old rotated log files")
+		}
+	}()
+}
+
+// rotatedFile is a backup of a RotatingFile
+type rotatedFile struct {
+	path string
+	time time.Time
+}
+
+// rotatedFiles returns the backups of filename, newest first
+func rotatedFiles(filename string) ([]rotatedFile, error) {
+	ext := filepath.Ext(filename)
+	prefix := strings.TrimSuffix(filepath.Base(filename), ext) + "-"
+
+	entries, err := os.ReadDir(filepath.Dir(filename))
+	if err != nil {
+		return nil, err
+	}
+	var files []rotatedFile
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".gz")
+		stamp, ok := strings.CutPrefix(name, prefix)
+		if !ok || e.IsDir() || !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+		t, err := time.ParseInLocation(TimeLayoutMilliSec, strings.TrimSuffix(stamp, ext), time.Local)
+		if err != nil {
+			continue
+		}
+		files = append(files, rotatedFile{path: filepath.Join(filepath.Dir(filename), e.Name()), time: t})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].time.After(files[j].time)
+	})
+	return files, nil
+}
+
+// prune removes the backups beyond MaxBackups or older than MaxAge
+func (r *RotatingFile) prune() error {
+	if r.MaxBackups <= 0 && r.MaxAge <= 0 {
+		return nil
+	}
+
+	files, err := rotatedFiles(r.Filename)
+	if err != nil {
+		return err
+	}
+	for i, f := range files {
+		tooMany := r.MaxBackups > 0 && i >= r.MaxBackups
+		tooOld := r.MaxAge > 0 && time.Since(f.time) > r.MaxAge
+		if tooMany || tooOld {
+			if err := os.Remove(f.path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// gzipFile compresses path to path.gz and removes path
+func gzipFile(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			dst.Close()
+			os.Remove(path + ".gz")
+		}
+	}()
+
+	gw := gzip.NewWriter(dst)
+	if _, err := io.Copy(gw, src); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("failed to close %s.gz: %w", path, err)
+	}
+	src.Close()
+	return os.Remove(path)
+}
+
+// PrunePolicy is the retention policy of PruneLogs, zero fields are disabled
+type PrunePolicy struct {
+	// MaxAge removes the log files modified longer ago
+	MaxAge time.Duration
+	// MaxTotalSize removes the oldest log files until the total size is at most this many bytes
+	MaxTotalSize int64
+	// CompressAfter gzips the log files modified longer ago
+	CompressAfter time.Duration
+}
+
+// logFilePattern matches the names of the log files of WithProduction: the default names from TimeStrSec,
+// their error files and the backups of RotatingFile, possibly gzipped
+var logFilePattern = newLogFilePattern("")
+
+// newLogFilePattern returns logFilePattern also matching the names from name, a regular expression of
+// the file name of WithProduction without extension
+func newLogFilePattern(name string) *regexp.Regexp {
+	names := `\d{8}\.\d{6}(\.error)?|.+-\d{8}\.\d{6}\.\d{3}`
+	if name != "" {
+		names += "|" + name + `(\.error)?`
+	}
+	return regexp.MustCompile(`^(` + names + `)\.(jsonl|log)(\.gz)?$`)
+}
+
+// PruneLogs applies policy to the log files of WithProduction in dir, and returns the paths of the removed files.
+// Only the files named like the package's log files are considered, and the most recently modified log file
+// and error file, which may still be written, are never removed nor compressed.
+func PruneLogs(dir string, policy PrunePolicy) (removed []string, err error) {
+	return pruneLogs(dir, policy, logFilePattern)
+}
+
+// pruneLogs is PruneLogs for the log files whose names match pattern
+func pruneLogs(dir string, policy PrunePolicy, pattern *regexp.Regexp) (removed []string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type logFile struct {
+		path string
+		info os.FileInfo
+	}
+	var files []logFile
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !pattern.MatchString(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, logFile{path: filepath.Join(dir, e.Name()), info: info})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].info.ModTime().After(files[j].info.ModTime())
+	})
+
+	var totalSize int64
+	newest := map[string]bool{}
+	for _, f := range files {
+		totalSize += f.info.Size()
+		// the log files and the error files are written side by side
+		kind := filepath.Ext(strings.TrimSuffix(f.path, ".gz"))
+		if !newest[kind] {
+			newest[kind] = true
+			continue
+		}
+
+		age := time.Since(f.info.ModTime())
+		if (policy.MaxAge > 0 && age > policy.MaxAge) || (policy.MaxTotalSize > 0 && totalSize > policy.MaxTotalSize) {
+			if err := os.Remove(f.path); err != nil {
+				return removed, err
+			}
+			totalSize -= f.info.Size()
+			removed = append(removed, f.path)
+			continue
+		}
+
+		if policy.CompressAfter > 0 && age > policy.CompressAfter && !strings.HasSuffix(f.path, ".gz") {
+			if err := gzipFile(f.path); err != nil {
+				return removed, err
+			}
+			// keep the modification time, so the compressed file keeps aging
+			if err := os.Chtimes(f.path+".gz", f.info.ModTime(), f.info.ModTime()); err != nil {
+				return removed, err
+			}
+			Logger.Debug().Str("file", f.path).Msg("Compressed log file")
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,140 @@
+package goutils_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestRotatingFile(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.jsonl")
+	rf := &goutils.RotatingFile{Filename: filename, MaxSize: 1024, MaxBackups: 3}
+	defer rf.Close()
+	logger := zerolog.New(rf)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				logger.Info().Int("j", j).Msg(strings.Repeat("x", 50))
+			}
+		}()
+	}
+	wg.Wait()
+	logger.Info().Msg("last")
+	// wait for the pruning
+	ast.NoError(rf.Close())
+
+	entries, err := os.ReadDir(dir)
+	ast.NoError(err)
+	ast.Len(entries, 4)
+
+	for _, e := range entries {
+		info, err := e.Info()
+		ast.NoError(err)
+		ast.LessOrEqual(info.Size(), int64(1024))
+
+		// every line is a complete event
+		lines, err := goutils.ReadLines(filepath.Join(dir, e.Name()))
+		ast.NoError(err)
+		for _, line := range lines {
+			ast.True(strings.HasPrefix(line, "{") && strings.HasSuffix(line, "}"), line)
+		}
+	}
+
+	lines, err := goutils.ReadLastNLines(filename, 1)
+	ast.NoError(err)
+	ast.Contains(lines[0], "last")
+}
+
+func TestRotatingFileCompress(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.log")
+	ast.NoError(goutils.WriteText(filepath.Join(dir, "other.log"), ""))
+	rf := &goutils.RotatingFile{Filename: filename, Compress: true}
+	defer rf.Close()
+
+	_, err := rf.Write([]byte("first\n"))
+	ast.NoError(err)
+	ast.NoError(rf.Rotate())
+	_, err = rf.Write([]byte("second\n"))
+	ast.NoError(err)
+	// wait for the compression
+	ast.NoError(rf.Close())
+
+	matches, err := filepath.Glob(filepath.Join(dir, "app-*.log.gz"))
+	ast.NoError(err)
+	ast.Len(matches, 1)
+	content, err := goutils.ReadText(filename)
+	ast.NoError(err)
+	ast.Equal("second\n", content)
+	ast.FileExists(filepath.Join(dir, "other.log"))
+}
+
+func TestPruneLogs(t *testing.T) {
+	ast := assert.New(t)
+
+	dir := t.TempDir()
+	now := time.Now()
+	seed := func(name string, size int, age time.Duration) string {
+		path := filepath.Join(dir, name)
+		ast.NoError(goutils.WriteText(path, strings.Repeat("x", size)))
+		ast.NoError(os.Chtimes(path, now.Add(-age), now.Add(-age)))
+		return path
+	}
+	day := 24 * time.Hour
+
+	current := seed("20240915.221219.jsonl", 100, 0)
+	recent := seed("app-20240914.221219.123.jsonl", 100, day)
+	compressed := seed("app-20240912.221219.123.jsonl", 100, 3*day)
+	oversize := seed("20240911.221219.jsonl", 100, 4*day)
+	old := seed("20240901.221219.jsonl", 10, 14*day)
+	oldGz := seed("app-20240902.221219.123.jsonl.gz", 10, 13*day)
+	unrelated := seed("notes.jsonl", 10, 30*day)
+	unrelatedLog := seed("app.log", 10, 30*day)
+
+	removed, err := goutils.PruneLogs(dir, goutils.PrunePolicy{
+		MaxAge:        7 * day,
+		MaxTotalSize:  300,
+		CompressAfter: 2 * day,
+	})
+	ast.NoError(err)
+	ast.ElementsMatch([]string{oversize, old, oldGz}, removed)
+
+	ast.FileExists(current)
+	ast.FileExists(recent)
+	ast.NoFileExists(compressed)
+	ast.FileExists(compressed + ".gz")
+	ast.FileExists(unrelated)
+	ast.FileExists(unrelatedLog)
+
+	// the newest file is kept even beyond the limits
+	removed, err = goutils.PruneLogs(dir, goutils.PrunePolicy{MaxTotalSize: 1})
+	ast.NoError(err)
+	ast.ElementsMatch([]string{recent, compressed + ".gz"}, removed)
+	ast.FileExists(current)
+
+	// so is the newest error file, which is written only on warnings
+	errorFile := seed("20240915.221219.error.log", 10, 10*day)
+	oldErrorFile := seed("20240901.221219.error-20240902.221219.123.log", 10, 11*day)
+	removed, err = goutils.PruneLogs(dir, goutils.PrunePolicy{MaxAge: 7 * day})
+	ast.NoError(err)
+	ast.Equal([]string{oldErrorFile}, removed)
+	ast.FileExists(errorFile)
+	ast.FileExists(current)
+}
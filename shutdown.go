@@ -0,0 +1,138 @@
+package goutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownTimeout is the timeout of each function of WaitForShutdown
+const DefaultShutdownTimeout = 10 * time.Second
+
+type shutdownHook struct {
+	name string
+	fn   func(context.Context) error
+}
+
+// ShutdownManager runs the registered cleanup hooks of a service when it receives SIGINT or SIGTERM, like
+//
+//	m := goutils.NewShutdownManager()
+//	m.Register("http server", server.Shutdown)
+//	m.Register("database", func(ctx context.Context) error { return db.Close() })
+//	if err := m.Run(5 * time.Second); err != nil {
+//		log.Error().Err(err).Msg("Failed to shut down")
+//	}
+type ShutdownManager struct {
+	mu    sync.Mutex
+	hooks []shutdownHook
+
+	trigger     chan struct{}
+	triggerOnce sync.Once
+}
+
+// NewShutdownManager returns a ShutdownManager without hooks
+func NewShutdownManager() *ShutdownManager {
+	return &ShutdownManager{trigger: make(chan struct{})}
+}
+
+// Register adds a hook, run with a context bounded by the timeout of Run. The hooks run in the reverse order
+// of their registration, like deferred calls, so the resources registered first are released last.
+func (m *ShutdownManager) Register(name string, fn func(context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, shutdownHook{name: name, fn: fn})
+}
+
+// Trigger starts the shutdown as a signal would, e.g. from an admin endpoint
+func (m *ShutdownManager) Trigger() {
+	m.triggerOnce.Do(func() {
+		close(m.trigger)
+	})
+}
+
+// Run waits for SIGINT, SIGTERM or Trigger, then runs the hooks and returns their errors joined.
+// Each hook is given timeout, a hook still running after it is abandoned with an error.
+// A second signal during the shutdown exits the process immediately with the status 1.
+func (m *ShutdownManager) Run(timeout time.Duration) error {
+	return m.run(context.Background(), timeout)
+}
+
+// run is Run, also starting the shutdown when ctx is done, the hooks get a context without its cancellation
+func (m *ShutdownManager) run(ctx context.Context, timeout time.Duration) error {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case sig := <-signals:
+		Logger.Info().Str("signal", sig.String()).Msg("Shutting down")
+	case <-m.trigger:
+		Logger.Info().Msg("Shutting down")
+	case <-ctx.Done():
+		Logger.Info().Err(ctx.Err()).Msg("Shutting down")
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case sig := <-signals:
+			Logger.Warn().Str("signal", sig.String()).Msg("Second signal received, exiting immediately")
+			os.Exit(1)
+		case <-done:
+		}
+	}()
+
+	m.mu.Lock()
+	hooks := append([]shutdownHook{}, m.hooks...)
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := runShutdownHook(context.WithoutCancel(ctx), hooks[i], timeout); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runShutdownHook runs hook bounded by timeout, and logs its duration
+func runShutdownHook(ctx context.Context, hook shutdownHook, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := GetClock().Now()
+	result := make(chan error, 1)
+	go func() {
+		result <- hook.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", DurationToStr(timeout))
+	}
+	elapsed := DurationToStr(GetClock().Since(start))
+	if err != nil {
+		Logger.Error().Err(err).Str("hook", hook.name).Str("elapsed", elapsed).Msg("Shutdown hook failed")
+		return fmt.Errorf("shutdown hook %s: %w", hook.name, err)
+	}
+	Logger.Info().Str("hook", hook.name).Str("elapsed", elapsed).Msg("Shutdown hook done")
+	return nil
+}
+
+// WaitForShutdown waits for SIGINT, SIGTERM or ctx to be done, then runs fns in reverse order, like deferred
+// calls, each bounded by DefaultShutdownTimeout, and returns their errors joined. See ShutdownManager.
+func WaitForShutdown(ctx context.Context, fns ...func(context.Context) error) error {
+	m := NewShutdownManager()
+	for i, fn := range fns {
+		m.Register(fmt.Sprintf("#%d", i), fn)
+	}
+	return m.run(ctx, DefaultShutdownTimeout)
+}
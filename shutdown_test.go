@@ -0,0 +1,89 @@
+package goutils_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+	"github.com/117503445/goutils/testutil"
+)
+
+func TestShutdownManager(t *testing.T) {
+	ast := assert.New(t)
+	logs := testutil.CaptureLogs(t)
+
+	m := goutils.NewShutdownManager()
+	// the abandoned hook may still run
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+	}
+	hook := func(name string, err error) func(context.Context) error {
+		return func(ctx context.Context) error {
+			record(name)
+			return err
+		}
+	}
+	errDB := errors.New("db busy")
+	m.Register("server", hook("server", nil))
+	m.Register("db", hook("db", errDB))
+	m.Register("slow", func(ctx context.Context) error {
+		record("slow")
+		<-ctx.Done()
+		time.Sleep(time.Second)
+		return nil
+	})
+	m.Register("cache", hook("cache", nil))
+
+	m.Trigger()
+	m.Trigger()
+	start := time.Now()
+	err := m.Run(50 * time.Millisecond)
+	ast.Less(time.Since(start), 900*time.Millisecond)
+
+	// reverse order, the slow hook is abandoned at its timeout
+	mu.Lock()
+	ast.Equal([]string{"cache", "slow", "db", "server"}, order)
+	mu.Unlock()
+	ast.ErrorIs(err, errDB)
+	ast.ErrorContains(err, "shutdown hook db: db busy")
+	ast.ErrorContains(err, "shutdown hook slow: timed out after 50ms")
+
+	ast.True(logs.Contains("info", `"hook":"cache"`))
+	ast.Equal(2, logs.Count("error"))
+	for _, entry := range logs.Entries() {
+		if _, ok := entry["hook"]; ok {
+			ast.Contains(entry, "elapsed")
+		}
+	}
+}
+
+func TestWaitForShutdown(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	// a done context starts the shutdown, the functions run in reverse order with a live context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var order []int
+	err := goutils.WaitForShutdown(ctx,
+		func(ctx context.Context) error {
+			order = append(order, 1)
+			return ctx.Err()
+		},
+		func(ctx context.Context) error {
+			order = append(order, 2)
+			return nil
+		},
+	)
+	ast.NoError(err)
+	ast.Equal([]int{2, 1}, order)
+}
@@ -0,0 +1,88 @@
+//go:build linux || darwin
+
+package goutils_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestShutdownManagerSignal(t *testing.T) {
+	ast := assert.New(t)
+
+	if mode := os.Getenv("GOUTILS_TEST_SHUTDOWN"); mode != "" {
+		goutils.InitZeroLog(goutils.WithJSONStdout{})
+		m := goutils.NewShutdownManager()
+		m.Register("first", func(ctx context.Context) error {
+			fmt.Println("hook first")
+			return nil
+		})
+		m.Register("second", func(ctx context.Context) error {
+			fmt.Println("hook second")
+			if mode == "block" {
+				time.Sleep(time.Minute)
+			}
+			return errors.New("failed")
+		})
+		go func() {
+			// give Run the time to subscribe to the signals
+			time.Sleep(100 * time.Millisecond)
+			fmt.Println("ready")
+		}()
+		err := m.Run(time.Minute)
+		fmt.Println("result", err)
+		return
+	}
+
+	start := func(mode string) (*exec.Cmd, *bufio.Scanner) {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestShutdownManagerSignal$")
+		cmd.Env = append(os.Environ(), "GOUTILS_TEST_SHUTDOWN="+mode)
+		stdout, err := cmd.StdoutPipe()
+		ast.NoError(err)
+		ast.NoError(cmd.Start())
+		return cmd, bufio.NewScanner(stdout)
+	}
+	waitLine := func(scanner *bufio.Scanner, prefix string) string {
+		for scanner.Scan() {
+			if strings.HasPrefix(scanner.Text(), prefix) {
+				return scanner.Text()
+			}
+		}
+		ast.Fail("missing line", prefix)
+		return ""
+	}
+
+	// the hooks run in reverse order on SIGTERM
+	cmd, scanner := start("run")
+	waitLine(scanner, "ready")
+	ast.NoError(cmd.Process.Signal(syscall.SIGTERM))
+	waitLine(scanner, "hook second")
+	waitLine(scanner, "hook first")
+	ast.Equal("result shutdown hook second: failed", waitLine(scanner, "result"))
+	ast.NoError(cmd.Wait())
+
+	// a second signal exits immediately
+	cmd, scanner = start("block")
+	waitLine(scanner, "ready")
+	ast.NoError(cmd.Process.Signal(syscall.SIGINT))
+	waitLine(scanner, "hook second")
+	ast.NoError(cmd.Process.Signal(syscall.SIGINT))
+	for scanner.Scan() {
+	}
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	ast.ErrorAs(err, &exitErr)
+	ast.Equal(1, exitErr.ExitCode())
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package goutils
+
+import "github.com/rs/zerolog"
+
+// toggleLevelOnSIGHUP does nothing, there is no SIGHUP on this platform
+func toggleLevelOnSIGHUP(logger zerolog.Logger) (stop func()) {
+	return func() {}
+}
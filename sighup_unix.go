@@ -0,0 +1,39 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package goutils
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog"
+)
+
+// toggleLevelOnSIGHUP switches the level between the configured one and Debug on SIGHUP, logging the changes with
+// logger, until the returned func is called
+func toggleLevelOnSIGHUP(logger zerolog.Logger) (stop func()) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-c:
+			case <-done:
+				return
+			}
+			level := zerolog.DebugLevel
+			if GetLogLevel() == zerolog.DebugLevel {
+				level = zerolog.Level(configuredLevel.Load())
+			}
+			// log before the change, which a caller may wait for to re-init the loggers
+			logger.Log().Str("level", level.String()).Msg("Changing log level on SIGHUP")
+			SetLogLevel(level)
+		}
+	}()
+	return func() {
+		signal.Stop(c)
+		close(done)
+	}
+}
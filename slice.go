@@ -0,0 +1,92 @@
+package goutils
+
+// Chunk splits s into slices of size elements, the last one possibly shorter.
+// It returns nil if size <= 0 or s is empty. The chunks share the array of s.
+//
+//	Chunk([]int{1, 2, 3, 4, 5}, 2) // [[1 2] [3 4] [5]]
+func Chunk[T any](s []T, size int) [][]T {
+	if size <= 0 || len(s) == 0 {
+		return nil
+	}
+	chunks := make([][]T, 0, (len(s)+size-1)/size)
+	for size < len(s) {
+		chunks = append(chunks, s[:size:size])
+		s = s[size:]
+	}
+	return append(chunks, s)
+}
+
+// Unique returns the elements of s without duplicates, in the order of their first occurrences.
+// It returns nil if s is nil.
+//
+//	Unique([]string{"b", "a", "b"}) // [b a]
+func Unique[T comparable](s []T) []T {
+	if s == nil {
+		return nil
+	}
+	seen := make(map[T]struct{}, len(s))
+	result := make([]T, 0, len(s))
+	for _, v := range s {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		result = append(result, v)
+	}
+	return result
+}
+
+// Map returns the results of fn on the elements of s, or nil if s is nil.
+//
+//	Map([]int{1, 2}, strconv.Itoa) // ["1" "2"]
+func Map[T, U any](s []T, fn func(T) U) []U {
+	if s == nil {
+		return nil
+	}
+	result := make([]U, len(s))
+	for i, v := range s {
+		result[i] = fn(v)
+	}
+	return result
+}
+
+// Filter returns the elements of s for which keep returns true, in order, or nil if s is nil.
+//
+//	Filter([]int{1, 2, 3, 4}, func(v int) bool { return v%2 == 0 }) // [2 4]
+func Filter[T any](s []T, keep func(T) bool) []T {
+	if s == nil {
+		return nil
+	}
+	result := make([]T, 0, len(s))
+	for _, v := range s {
+		if keep(v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// Contains returns true if v is an element of s.
+//
+//	Contains([]string{"a", "b"}, "b") // true
+func Contains[T comparable](s []T, v T) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupBy groups the elements of s by the key returned by key, keeping their order in each group.
+// It returns an empty map if s is empty.
+//
+//	GroupBy([]string{"ab", "c", "de"}, func(s string) int { return len(s) }) // map[1:[c] 2:[ab de]]
+func GroupBy[T any, K comparable](s []T, key func(T) K) map[K][]T {
+	groups := make(map[K][]T)
+	for _, v := range s {
+		k := key(v)
+		groups[k] = append(groups[k], v)
+	}
+	return groups
+}
@@ -0,0 +1,114 @@
+package goutils_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestChunk(t *testing.T) {
+	ast := assert.New(t)
+
+	tests := []struct {
+		s        []int
+		size     int
+		expected [][]int
+	}{
+		{nil, 2, nil},
+		{[]int{}, 2, nil},
+		{[]int{1, 2, 3}, 0, nil},
+		{[]int{1, 2, 3}, -1, nil},
+		{[]int{1, 2, 3, 4, 5}, 2, [][]int{{1, 2}, {3, 4}, {5}}},
+		{[]int{1, 2, 3, 4}, 2, [][]int{{1, 2}, {3, 4}}},
+		{[]int{1, 2}, 5, [][]int{{1, 2}}},
+		{[]int{1, 2}, 1, [][]int{{1}, {2}}},
+	}
+	for _, tt := range tests {
+		ast.Equal(tt.expected, goutils.Chunk(tt.s, tt.size), "%v by %d", tt.s, tt.size)
+	}
+
+	// appending to a chunk doesn't overwrite the next one
+	s := []int{1, 2, 3, 4}
+	chunks := goutils.Chunk(s, 2)
+	_ = append(chunks[0], 9)
+	ast.Equal([]int{3, 4}, chunks[1])
+}
+
+func TestUnique(t *testing.T) {
+	ast := assert.New(t)
+
+	tests := []struct {
+		s        []string
+		expected []string
+	}{
+		{nil, nil},
+		{[]string{}, []string{}},
+		{[]string{"a"}, []string{"a"}},
+		{[]string{"b", "a", "b", "c", "a"}, []string{"b", "a", "c"}},
+		{[]string{"a", "a", "a"}, []string{"a"}},
+	}
+	for _, tt := range tests {
+		ast.Equal(tt.expected, goutils.Unique(tt.s), tt.s)
+	}
+}
+
+func TestMap(t *testing.T) {
+	ast := assert.New(t)
+
+	ast.Nil(goutils.Map(nil, strconv.Itoa))
+	ast.Equal([]string{}, goutils.Map([]int{}, strconv.Itoa))
+	ast.Equal([]string{"1", "2", "3"}, goutils.Map([]int{1, 2, 3}, strconv.Itoa))
+	ast.Equal([]int{2, 4}, goutils.Map([]int{1, 2}, func(v int) int { return v * 2 }))
+}
+
+func TestFilter(t *testing.T) {
+	ast := assert.New(t)
+
+	even := func(v int) bool { return v%2 == 0 }
+	tests := []struct {
+		s        []int
+		expected []int
+	}{
+		{nil, nil},
+		{[]int{}, []int{}},
+		{[]int{1, 3}, []int{}},
+		{[]int{1, 2, 3, 4}, []int{2, 4}},
+		{[]int{4, 2}, []int{4, 2}},
+	}
+	for _, tt := range tests {
+		ast.Equal(tt.expected, goutils.Filter(tt.s, even), tt.s)
+	}
+}
+
+func TestContains(t *testing.T) {
+	ast := assert.New(t)
+
+	tests := []struct {
+		s        []string
+		v        string
+		expected bool
+	}{
+		{nil, "a", false},
+		{[]string{}, "", false},
+		{[]string{"a", "b"}, "b", true},
+		{[]string{"a", "b"}, "c", false},
+		{[]string{""}, "", true},
+	}
+	for _, tt := range tests {
+		ast.Equal(tt.expected, goutils.Contains(tt.s, tt.v), "%v %q", tt.s, tt.v)
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	ast := assert.New(t)
+
+	length := func(s string) int { return len(s) }
+	ast.Equal(map[int][]string{}, goutils.GroupBy(nil, length))
+	ast.Equal(map[int][]string{
+		1: {"c"},
+		2: {"ab", "de"},
+	}, goutils.GroupBy([]string{"ab", "c", "de"}, length))
+}
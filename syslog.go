@@ -0,0 +1,23 @@
+package goutils
+
+// WithSyslog is a log option to also send the events as JSON to syslog, or journald through its syslog socket,
+// with the zerolog levels mapped to syslog severities. An empty Network and Addr means the local syslog daemon.
+//
+// Syslog is only supported on Unix. When it can't be reached, a warning is logged and the other outputs are kept.
+// The connection is closed by CloseLogs, or when another logger is installed.
+type WithSyslog struct {
+	Network string
+	Addr    string
+	Tag     string
+}
+
+func (w WithSyslog) applyTo(o *logOptions) error {
+	writer, closer, err := dialSyslog(w)
+	if err != nil {
+		Logger.Warn().Err(err).Str("network", w.Network).Str("addr", w.Addr).Msg("Failed to connect to syslog, skipping it")
+		return nil
+	}
+	o.Writers = append(o.Writers, writer)
+	o.closers = append(o.closers, closer)
+	return nil
+}
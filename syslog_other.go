@@ -0,0 +1,12 @@
+//go:build windows || plan9
+
+package goutils
+
+import (
+	"errors"
+	"io"
+)
+
+func dialSyslog(w WithSyslog) (io.Writer, io.Closer, error) {
+	return nil, nil, errors.ErrUnsupported
+}
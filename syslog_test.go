@@ -0,0 +1,61 @@
+//go:build linux
+
+package goutils_test
+
+import (
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestInitZeroLogWithSyslog(t *testing.T) {
+	ast := assert.New(t)
+	defer goutils.InitZeroLog()
+
+	addr := filepath.Join(t.TempDir(), "syslog.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	ast.NoError(err)
+	defer conn.Close()
+
+	goutils.InitZeroLog(goutils.WithNoColor{}, goutils.WithSyslog{Network: "unixgram", Addr: addr, Tag: "myapp"})
+	log.Error().Msg("disk full")
+	log.Warn().Msg("disk almost full")
+
+	read := func() string {
+		buf := make([]byte, 4096)
+		ast.NoError(conn.SetReadDeadline(time.Now().Add(5 * time.Second)))
+		n, err := conn.Read(buf)
+		ast.NoError(err)
+		return string(buf[:n])
+	}
+	// priority is facility user (8) + severity
+	msg := read()
+	ast.Regexp(`^<11>.* myapp\[\d+\]: .*"message":"disk full"`, msg)
+	msg = read()
+	ast.Regexp(`^<12>.* myapp\[\d+\]: .*"message":"disk almost full"`, msg)
+
+	// CloseLogs closes the connection
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	ast.NoError(err)
+	defer listener.Close()
+	goutils.InitZeroLog(goutils.WithNoColor{}, goutils.WithSyslog{Network: "tcp", Addr: listener.Addr().String(), Tag: "myapp"})
+	accepted, err := listener.Accept()
+	if ast.NoError(err) {
+		defer accepted.Close()
+		ast.NoError(goutils.CloseLogs())
+		ast.NoError(accepted.SetReadDeadline(time.Now().Add(5 * time.Second)))
+		_, err = io.ReadAll(accepted)
+		ast.NoError(err)
+	}
+
+	// an unreachable syslog degrades to the console only
+	goutils.InitZeroLog(goutils.WithSyslog{Network: "unixgram", Addr: filepath.Join(t.TempDir(), "missing.sock")})
+	log.Info().Msg("still logging")
+}
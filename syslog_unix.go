@@ -0,0 +1,19 @@
+//go:build !windows && !plan9
+
+package goutils
+
+import (
+	"io"
+	"log/syslog"
+
+	"github.com/rs/zerolog"
+)
+
+// dialSyslog returns the writer of the events to syslog, and the connection to close
+func dialSyslog(w WithSyslog) (io.Writer, io.Closer, error) {
+	writer, err := syslog.Dial(w.Network, w.Addr, syslog.LOG_INFO|syslog.LOG_USER, w.Tag)
+	if err != nil {
+		return nil, nil, err
+	}
+	return zerolog.SyslogLevelWriter(writer), writer, nil
+}
@@ -0,0 +1,123 @@
+package goutils
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// TemplateOption is an option for RenderTemplate and RenderTemplateFile
+type TemplateOption interface {
+	applyToTemplate(*templateOptions) error
+}
+
+type templateOptions struct {
+	AllowMissingKeys bool
+	Funcs            template.FuncMap
+}
+
+// WithAllowMissingKeys is a template option to render the missing keys of a map as "<no value>",
+// instead of failing
+type WithAllowMissingKeys struct {
+}
+
+func (w WithAllowMissingKeys) applyToTemplate(o *templateOptions) error {
+	o.AllowMissingKeys = true
+	return nil
+}
+
+// WithTemplateFuncs is a template option to add funcs to the ones of RenderTemplate, or replace them
+type WithTemplateFuncs template.FuncMap
+
+func (w WithTemplateFuncs) applyToTemplate(o *templateOptions) error {
+	for name, fn := range w {
+		o.Funcs[name] = fn
+	}
+	return nil
+}
+
+// templateFuncs returns the funcs of RenderTemplate
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"duration": DurationToStr,
+		"bytes": func(n any) (string, error) {
+			switch n := n.(type) {
+			case int:
+				return BytesToStr(int64(n)), nil
+			case int32:
+				return BytesToStr(int64(n)), nil
+			case int64:
+				return BytesToStr(n), nil
+			case uint32:
+				return BytesToStr(int64(n)), nil
+			case uint64:
+				return BytesToStr(int64(n)), nil
+			case float64:
+				return BytesToStr(int64(n)), nil
+			default:
+				return "", fmt.Errorf("bytes of %T", n)
+			}
+		},
+		"truncate": func(n int, s string) string {
+			if len(s) <= n {
+				return s
+			}
+			for n > 0 && !utf8.RuneStart(s[n]) {
+				n--
+			}
+			return s[:n] + "…"
+		},
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"now":   TimeStrSec,
+		"since": func(t time.Time) string { return DurationToStr(GetClock().Since(t)) },
+		"md":    mdEscaper.Replace,
+	}
+}
+
+// RenderTemplate executes the text/template text with data, like an alert format defined by the SREs.
+// A missing key of a map is an error, unless WithAllowMissingKeys is set. The errors tell the name and the line.
+//
+// Besides the builtin ones, the funcs are:
+//   - duration, the DurationToStr of a time.Duration, like 1m30s
+//   - bytes, the BytesToStr of a number, like 1.5MiB
+//   - truncate, the first bytes of a string, with "…" if truncated, like {{ .Output | truncate 100 }}
+//   - upper and lower
+//   - now, the current time by TimeStrSec
+//   - since, the DurationToStr of the time elapsed since a time.Time
+//   - md, the string escaped for MDBuilder Markdown
+func RenderTemplate(name, text string, data any, opts ...TemplateOption) (string, error) {
+	opt := &templateOptions{Funcs: templateFuncs()}
+	for _, o := range opts {
+		if err := o.applyToTemplate(opt); err != nil {
+			return "", err
+		}
+	}
+
+	tmpl := template.New(name).Funcs(opt.Funcs)
+	if !opt.AllowMissingKeys {
+		tmpl = tmpl.Option("missingkey=error")
+	}
+	tmpl, err := tmpl.Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// RenderTemplateFile is like RenderTemplate, with the template read from a file by ReadText,
+// named by the file name
+func RenderTemplateFile(path string, data any, opts ...TemplateOption) (string, error) {
+	text, err := ReadText(path)
+	if err != nil {
+		return "", err
+	}
+	return RenderTemplate(filepath.Base(path), text, data, opts...)
+}
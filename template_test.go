@@ -0,0 +1,98 @@
+package goutils_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+type deployment struct {
+	Service  string
+	Took     time.Duration
+	Size     int64
+	Output   string
+	Failures []string
+}
+
+func TestRenderTemplate(t *testing.T) {
+	ast := assert.New(t)
+
+	const text = `### {{ .Service | upper }} deployed
+- took {{ duration .Took }}, {{ bytes .Size }}
+- output: {{ .Output | truncate 8 }}
+{{ range .Failures }}- failed: {{ md . }}
+{{ end }}`
+	out, err := goutils.RenderTemplate("deploy", text, deployment{
+		Service:  "api",
+		Took:     90 * time.Second,
+		Size:     1536,
+		Output:   "ok ok ok ok ok",
+		Failures: []string{"web_1"},
+	})
+	ast.NoError(err)
+	ast.Equal("### API deployed\n- took 1m30s, 1.5KiB\n- output: ok ok ok…\n- failed: web\\_1\n", out)
+
+	// a map
+	out, err = goutils.RenderTemplate("map", `{{ .host | lower }}: {{ bytes .free }} free at {{ now }}`, map[string]any{
+		"host": "WEB1",
+		"free": 1024,
+	})
+	ast.NoError(err)
+	ast.Regexp(`^web1: 1KiB free at \d{8}\.\d{6}$`, out)
+
+	// the missing keys of a map are errors, unless allowed
+	_, err = goutils.RenderTemplate("map", `{{ .hots }}`, map[string]any{"host": "web1"})
+	ast.ErrorContains(err, `template: map:1:3: executing "map" at <.hots>: map has no entry for key "hots"`)
+	out, err = goutils.RenderTemplate("map", `{{ .hots }}`, map[string]any{"host": "web1"}, goutils.WithAllowMissingKeys{})
+	ast.NoError(err)
+	ast.Equal("<no value>", out)
+
+	// a typoed field
+	_, err = goutils.RenderTemplate("deploy", "### title\n{{ .Servce }}", deployment{})
+	ast.ErrorContains(err, "failed to render template")
+	ast.ErrorContains(err, "deploy:2:3")
+	ast.ErrorContains(err, "can't evaluate field Servce")
+
+	_, err = goutils.RenderTemplate("broken", "{{ if .Service }}", deployment{})
+	ast.ErrorContains(err, "failed to parse template: template: broken:1")
+
+	out, err = goutils.RenderTemplate("funcs", `{{ shout .Service }}`, deployment{Service: "api"},
+		goutils.WithTemplateFuncs{"shout": func(s string) string { return strings.ToUpper(s) + "!" }})
+	ast.NoError(err)
+	ast.Equal("API!", out)
+}
+
+func TestRenderTemplateFile(t *testing.T) {
+	ast := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "disk.md.tmpl")
+	ast.NoError(goutils.WriteText(path, "disk of {{ .Host }} is full\n{{ .Hots }}"))
+	_, err := goutils.RenderTemplateFile(path, struct{ Host string }{"web1"})
+	ast.ErrorContains(err, "disk.md.tmpl:2:3")
+
+	ast.NoError(goutils.WriteText(path, "disk of {{ .Host }} is full"))
+	out, err := goutils.RenderTemplateFile(path, struct{ Host string }{"web1"})
+	ast.NoError(err)
+	ast.Equal("disk of web1 is full", out)
+
+	_, err = goutils.RenderTemplateFile(filepath.Join(t.TempDir(), "missing"), nil)
+	ast.Error(err)
+
+	// alerts
+	var sent []string
+	hook, err := goutils.NewAlertHook(func(title, markdown string) error {
+		sent = append(sent, markdown)
+		return nil
+	}, zerolog.ErrorLevel, goutils.WithMinInterval(0), goutils.WithRateLimit(0))
+	ast.NoError(err)
+	ast.NoError(hook.AlertTemplate("disk", "disk of {{ .Host }} is full", struct{ Host string }{"web1"}))
+	ast.Error(hook.AlertTemplate("disk", "disk of {{ .Hots }} is full", struct{ Host string }{"web1"}))
+	hook.Close()
+	ast.Equal([]string{"disk of web1 is full"}, sent)
+}
@@ -0,0 +1,119 @@
+// Package testutil provides helpers for the tests of the code using goutils
+package testutil
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FakeClock is a goutils.Clock whose time only moves by Advance, for deterministic tests:
+//
+//	clock := testutil.NewFakeClock(time.Date(2024, 9, 15, 22, 12, 19, 0, time.UTC))
+//	defer goutils.SetClock(goutils.SetClock(clock))
+type FakeClock struct {
+	mu       sync.Mutex
+	now      time.Time
+	sleepers []*sleeper
+	// changed is closed and replaced when the sleepers change, for BlockUntil
+	changed chan struct{}
+}
+
+type sleeper struct {
+	until time.Time
+	done  chan struct{}
+}
+
+// NewFakeClock returns a FakeClock at now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now, changed: make(chan struct{})}
+}
+
+// Now returns the time of the clock
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Since returns the time elapsed on the clock since t
+func (c *FakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Sleep blocks until the clock is advanced by d, or until ctx is done and then returns ctx.Err()
+func (c *FakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.mu.Lock()
+	if d <= 0 {
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+	s := &sleeper{until: c.now.Add(d), done: make(chan struct{})}
+	c.sleepers = append(c.sleepers, s)
+	c.notifyLocked()
+	c.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		for i, other := range c.sleepers {
+			if other == s {
+				c.sleepers = append(c.sleepers[:i], c.sleepers[i+1:]...)
+				c.notifyLocked()
+				break
+			}
+		}
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Advance moves the clock by d, waking up the sleepers whose time has come, earliest first
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.sleepers, func(i, j int) bool {
+		return c.sleepers[i].until.Before(c.sleepers[j].until)
+	})
+	remaining := c.sleepers[:0]
+	for _, s := range c.sleepers {
+		if s.until.After(c.now) {
+			remaining = append(remaining, s)
+		} else {
+			close(s.done)
+		}
+	}
+	c.sleepers = remaining
+	c.notifyLocked()
+}
+
+// Sleepers returns the number of goroutines blocked in Sleep
+func (c *FakeClock) Sleepers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.sleepers)
+}
+
+// BlockUntil waits until n goroutines are blocked in Sleep, to Advance the clock once the code under test waits
+func (c *FakeClock) BlockUntil(n int) {
+	for {
+		c.mu.Lock()
+		if len(c.sleepers) == n {
+			c.mu.Unlock()
+			return
+		}
+		changed := c.changed
+		c.mu.Unlock()
+		<-changed
+	}
+}
+
+func (c *FakeClock) notifyLocked() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
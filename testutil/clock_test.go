@@ -0,0 +1,67 @@
+package testutil_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+	"github.com/117503445/goutils/testutil"
+)
+
+var _ goutils.Clock = (*testutil.FakeClock)(nil)
+
+func TestFakeClock(t *testing.T) {
+	ast := assert.New(t)
+
+	start := time.Date(2024, 9, 15, 22, 12, 19, 0, time.UTC)
+	clock := testutil.NewFakeClock(start)
+	ast.Equal(start, clock.Now())
+
+	clock.Advance(time.Minute)
+	ast.Equal(start.Add(time.Minute), clock.Now())
+	ast.Equal(time.Minute, clock.Since(start))
+
+	// the sleepers wake up once the clock passes their time
+	done := make(chan time.Duration, 2)
+	for _, d := range []time.Duration{time.Second, 3 * time.Second} {
+		go func() {
+			ast.NoError(clock.Sleep(context.Background(), d))
+			done <- d
+		}()
+	}
+	clock.BlockUntil(2)
+	clock.Advance(2 * time.Second)
+	ast.Equal(time.Second, <-done)
+	ast.Equal(1, clock.Sleepers())
+	clock.Advance(time.Second)
+	ast.Equal(3*time.Second, <-done)
+	ast.Zero(clock.Sleepers())
+
+	// a cancelled sleep returns the error of the context
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() {
+		errs <- clock.Sleep(ctx, time.Hour)
+	}()
+	clock.BlockUntil(1)
+	cancel()
+	ast.ErrorIs(<-errs, context.Canceled)
+	clock.BlockUntil(0)
+
+	ast.NoError(clock.Sleep(context.Background(), 0))
+}
+
+func TestFakeClockSetClock(t *testing.T) {
+	ast := assert.New(t)
+
+	clock := testutil.NewFakeClock(time.Date(2024, 9, 15, 22, 12, 19, 0, time.Local))
+	defer goutils.SetClock(goutils.SetClock(clock))
+
+	ast.Equal("20240915.221219", goutils.TimeStrSec())
+	ast.Equal("20240915.221219.000", goutils.TimeStrMilliSec())
+	clock.Advance(1500 * time.Millisecond)
+	ast.Equal("20240915.221220.500", goutils.TimeStrMilliSec())
+}
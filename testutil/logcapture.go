@@ -0,0 +1,94 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/117503445/goutils"
+)
+
+// LogCapture holds the events logged while installed by CaptureLogs
+type LogCapture struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (c *LogCapture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Write(p)
+}
+
+// CaptureLogs replaces the global zerolog logger, goutils.Logger and goutils.CommandLogger with a logger writing JSON
+// to the returned LogCapture, at Trace level, and restores them when the test ends.
+//
+// As the loggers are globals, the tests using it must not run in parallel with other tests logging.
+func CaptureLogs(t testing.TB) *LogCapture {
+	t.Helper()
+
+	c := &LogCapture{}
+	origLog, origLogger, origCommandLogger := log.Logger, goutils.Logger, goutils.CommandLogger
+	t.Cleanup(func() {
+		log.Logger, goutils.Logger, goutils.CommandLogger = origLog, origLogger, origCommandLogger
+	})
+
+	logger := zerolog.New(c).Level(zerolog.TraceLevel).With().Timestamp().Logger()
+	log.Logger = logger
+	goutils.Logger = logger.With().Str("module", "goutils").Logger()
+	goutils.CommandLogger = logger.With().Str("module", "goutils.command").Logger()
+	return c
+}
+
+// Entries returns the captured events, in order. Lines which are not JSON objects are skipped.
+func (c *LogCapture) Entries() []map[string]any {
+	var entries []map[string]any
+	for _, e := range c.events("") {
+		entries = append(entries, e.fields)
+	}
+	return entries
+}
+
+// Contains returns true if an event of level, like "info", has substr in its JSON line, e.g. in its message.
+// An empty level matches all levels.
+func (c *LogCapture) Contains(level, substr string) bool {
+	for _, e := range c.events(level) {
+		if strings.Contains(e.line, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of events of level, or of all events if level is empty
+func (c *LogCapture) Count(level string) int {
+	return len(c.events(level))
+}
+
+type capturedEvent struct {
+	line   string
+	fields map[string]any
+}
+
+// events returns the captured events of level, or all events if level is empty
+func (c *LogCapture) events(level string) []capturedEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var events []capturedEvent
+	for _, line := range strings.Split(c.buf.String(), "\n") {
+		var fields map[string]any
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			continue
+		}
+		if level == "" || fields[zerolog.LevelFieldName] == level {
+			events = append(events, capturedEvent{line: line, fields: fields})
+		}
+	}
+	return events
+}
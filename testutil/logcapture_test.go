@@ -0,0 +1,44 @@
+package testutil_test
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+	"github.com/117503445/goutils/testutil"
+)
+
+func TestCaptureLogs(t *testing.T) {
+	ast := assert.New(t)
+
+	orig := goutils.Logger
+	t.Run("capture", func(t *testing.T) {
+		logs := testutil.CaptureLogs(t)
+
+		log.Trace().Msg("trace event")
+		log.Info().Str("key", "value").Msg("info event")
+		goutils.Logger.Error().Msg("error event")
+		goutils.CommandLogger.Debug().Msg("command event")
+
+		entries := logs.Entries()
+		ast.Len(entries, 4)
+		ast.Equal("info event", entries[1]["message"])
+		ast.Equal("value", entries[1]["key"])
+		ast.Equal("goutils", entries[2]["module"])
+		ast.Equal("goutils.command", entries[3]["module"])
+
+		ast.True(logs.Contains("info", "info event"))
+		ast.True(logs.Contains("info", `"key":"value"`))
+		ast.True(logs.Contains("", "error event"))
+		ast.False(logs.Contains("info", "error event"))
+
+		ast.Equal(1, logs.Count("error"))
+		ast.Equal(4, logs.Count(""))
+		ast.Zero(logs.Count("warn"))
+	})
+
+	// the loggers are restored after the test
+	ast.Equal(orig, goutils.Logger)
+}
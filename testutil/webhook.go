@@ -0,0 +1,155 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// WebhookRecorder is a test webhook server recording the requests it receives, to test the code sending alerts,
+// like a goutils.AlertHook with Send as its send func:
+//
+//	webhook := testutil.NewWebhookRecorder(t, nil)
+//	hook, err := goutils.NewAlertHook(webhook.Send, zerolog.ErrorLevel)
+//	...
+//	hook.Close()
+//	assert.Equal(t, []string{"disk full"}, webhook.Markdowns())
+//
+// It replies with the responses queued by Respond, one per request, then with the handler, or 200 OK without one.
+type WebhookRecorder struct {
+	// URL is the URL of the server
+	URL string
+
+	handler   http.HandlerFunc
+	mu        sync.Mutex
+	requests  []WebhookRequest
+	responses []WebhookResponse
+}
+
+// WebhookRequest is a request received by a WebhookRecorder
+type WebhookRequest struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+	Body   []byte
+}
+
+// Decode decodes the JSON body of the request into v
+func (r WebhookRequest) Decode(v any) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// WebhookResponse is a canned response of a WebhookRecorder, like an error of the alerting API
+type WebhookResponse struct {
+	// Status is the HTTP status, 200 if 0
+	Status int
+	Body   string
+}
+
+// webhookPayload is the JSON body posted by WebhookRecorder.Send
+type webhookPayload struct {
+	Title    string `json:"title"`
+	Markdown string `json:"markdown"`
+}
+
+// NewWebhookRecorder returns a started WebhookRecorder replying with handler, if not nil, closed at the end of the
+// test
+func NewWebhookRecorder(t testing.TB, handler http.HandlerFunc) *WebhookRecorder {
+	r := &WebhookRecorder{handler: handler}
+	server := httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+	t.Cleanup(server.Close)
+	r.URL = server.URL
+	return r
+}
+
+func (r *WebhookRecorder) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.mu.Lock()
+	r.requests = append(r.requests, WebhookRequest{Method: req.Method, URL: req.URL, Header: req.Header.Clone(), Body: body})
+	var response *WebhookResponse
+	if len(r.responses) > 0 {
+		response = &r.responses[0]
+		r.responses = r.responses[1:]
+	}
+	r.mu.Unlock()
+
+	switch {
+	case response != nil:
+		if response.Status != 0 {
+			w.WriteHeader(response.Status)
+		}
+		io.WriteString(w, response.Body)
+	case r.handler != nil:
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		r.handler(w, req)
+	}
+}
+
+// Respond queues responses for the next requests, one per request, like an error then a success
+func (r *WebhookRecorder) Respond(responses ...WebhookResponse) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responses = append(r.responses, responses...)
+}
+
+// Requests returns the requests received so far
+func (r *WebhookRecorder) Requests() []WebhookRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]WebhookRequest(nil), r.requests...)
+}
+
+// Send posts an alert to the server as a JSON {"title": ..., "markdown": ...}. It is a send func for
+// goutils.NewAlertHook, failing on the non-2xx responses.
+func (r *WebhookRecorder) Send(title, markdown string) error {
+	body, err := json.Marshal(webhookPayload{Title: title, Markdown: markdown})
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(r.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+// Markdowns returns the Markdown of the alerts posted by Send so far, including the failed ones
+func (r *WebhookRecorder) Markdowns() []string {
+	var markdowns []string
+	for _, req := range r.Requests() {
+		var payload webhookPayload
+		if req.Decode(&payload) == nil {
+			markdowns = append(markdowns, payload.Markdown)
+		}
+	}
+	return markdowns
+}
+
+// Titles returns the titles of the alerts posted by Send so far, including the failed ones
+func (r *WebhookRecorder) Titles() []string {
+	var titles []string
+	for _, req := range r.Requests() {
+		var payload webhookPayload
+		if req.Decode(&payload) == nil {
+			titles = append(titles, payload.Title)
+		}
+	}
+	return titles
+}
@@ -0,0 +1,55 @@
+package testutil_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+	"github.com/117503445/goutils/testutil"
+)
+
+func TestWebhookRecorder(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	webhook := testutil.NewWebhookRecorder(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errcode":0}`))
+	})
+
+	// the canned responses come first, one per request
+	webhook.Respond(testutil.WebhookResponse{Status: http.StatusTooManyRequests, Body: `{"errcode":130101}`})
+	err := webhook.Send("svc", "disk full")
+	ast.EqualError(err, `webhook: 429 Too Many Requests: {"errcode":130101}`)
+	ast.NoError(webhook.Send("svc", "disk still full"))
+
+	resp, err := http.Post(webhook.URL+"/robot/send?access_token=abc", "text/plain", strings.NewReader("raw"))
+	ast.NoError(err)
+	resp.Body.Close()
+
+	requests := webhook.Requests()
+	if ast.Len(requests, 3) {
+		ast.Equal(http.MethodPost, requests[0].Method)
+		ast.Equal("application/json", requests[0].Header.Get("Content-Type"))
+		var payload map[string]string
+		ast.NoError(requests[1].Decode(&payload))
+		ast.Equal(map[string]string{"title": "svc", "markdown": "disk still full"}, payload)
+		ast.Equal("abc", requests[2].URL.Query().Get("access_token"))
+		ast.Equal("raw", string(requests[2].Body))
+	}
+	ast.Equal([]string{"disk full", "disk still full"}, webhook.Markdowns())
+	ast.Equal([]string{"svc", "svc"}, webhook.Titles())
+
+	// as the send func of an AlertHook
+	webhook = testutil.NewWebhookRecorder(t, nil)
+	webhook.Respond(testutil.WebhookResponse{Status: http.StatusServiceUnavailable})
+	hook, err := goutils.NewAlertHook(webhook.Send, zerolog.ErrorLevel, goutils.WithMinInterval(0),
+		goutils.WithSendRetry{goutils.WithAttempts(2), goutils.WithBackoff{Factor: 1}})
+	ast.NoError(err)
+	ast.NoError(hook.Alert("disk full"))
+	hook.Close()
+	ast.Equal([]string{"disk full", "disk full"}, webhook.Markdowns())
+}
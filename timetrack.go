@@ -0,0 +1,69 @@
+package goutils
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// TimeTrackOption is an option for TimeTrack
+type TimeTrackOption interface {
+	applyToTimeTrack(*timeTrackOptions) error
+}
+
+type timeTrackOptions struct {
+	Logger    *zerolog.Logger
+	Level     zerolog.Level
+	Threshold time.Duration
+}
+
+func (w WithLogger) applyToTimeTrack(o *timeTrackOptions) error {
+	o.Logger = w.Logger
+	return nil
+}
+
+func (w WithLevel) applyToTimeTrack(o *timeTrackOptions) error {
+	o.Level = zerolog.Level(w)
+	return nil
+}
+
+// TimeTrackThreshold is a TimeTrack option to log only when the elapsed time exceeds it
+type TimeTrackThreshold time.Duration
+
+func (w TimeTrackThreshold) applyToTimeTrack(o *timeTrackOptions) error {
+	o.Threshold = time.Duration(w)
+	return nil
+}
+
+// TimeTrack returns a func logging the time elapsed since TimeTrack was called, with DurationToStr, to use as
+//
+//	defer goutils.TimeTrack("rebuild index")()
+//
+// The event is logged at Debug on Logger, see WithLevel and WithLogger, e.g. with the logger of a context
+// by WithLogger{Logger: LoggerFromContext(ctx)}. Its caller is the function deferring the call.
+func TimeTrack(name string, opts ...TimeTrackOption) func() {
+	start := GetClock().Now()
+	opt := &timeTrackOptions{
+		Level: zerolog.DebugLevel,
+	}
+	for _, o := range opts {
+		if err := o.applyToTimeTrack(opt); err != nil {
+			Logger.Warn().Err(err).Str("name", name).Msg("Failed to apply time track option")
+		}
+	}
+	if opt.Logger == nil {
+		opt.Logger = &Logger
+	}
+
+	return func() {
+		elapsed := GetClock().Since(start)
+		if elapsed < opt.Threshold {
+			return
+		}
+		opt.Logger.WithLevel(opt.Level).
+			CallerSkipFrame(1).
+			Str("name", name).
+			Str("elapsed", DurationToStr(elapsed)).
+			Msg("Time track")
+	}
+}
@@ -0,0 +1,80 @@
+package goutils_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+	"github.com/117503445/goutils/testutil"
+)
+
+func rebuildIndex() {
+	defer goutils.TimeTrack("rebuild index")()
+	time.Sleep(20 * time.Millisecond)
+}
+
+func TestTimeTrack(t *testing.T) {
+	ast := assert.New(t)
+	logs := testutil.CaptureLogs(t)
+
+	rebuildIndex()
+	entries := logs.Entries()
+	ast.Len(entries, 1)
+	ast.Equal("debug", entries[0]["level"])
+	ast.Equal("rebuild index", entries[0]["name"])
+	elapsed, err := time.ParseDuration(entries[0]["elapsed"].(string))
+	ast.NoError(err)
+	ast.GreaterOrEqual(elapsed, 20*time.Millisecond)
+
+	// below the threshold, nothing is logged
+	func() {
+		defer goutils.TimeTrack("fast", goutils.TimeTrackThreshold(time.Hour))()
+	}()
+	ast.Equal(1, logs.Count(""))
+
+	func() {
+		defer goutils.TimeTrack("slow", goutils.TimeTrackThreshold(time.Millisecond), goutils.WithLevel(zerolog.InfoLevel))()
+		time.Sleep(5 * time.Millisecond)
+	}()
+	ast.True(logs.Contains("info", `"name":"slow"`))
+}
+
+func TestTimeTrackFakeClock(t *testing.T) {
+	ast := assert.New(t)
+	logs := testutil.CaptureLogs(t)
+
+	clock := testutil.NewFakeClock(time.Now())
+	defer goutils.SetClock(goutils.SetClock(clock))
+
+	func() {
+		defer goutils.TimeTrack("import")()
+		clock.Advance(26*time.Hour + 3*time.Minute + 1500*time.Millisecond)
+	}()
+	ast.True(logs.Contains("debug", `"elapsed":"1d2h3m"`))
+
+	func() {
+		defer goutils.TimeTrack("threshold", goutils.TimeTrackThreshold(time.Second))()
+		clock.Advance(999 * time.Millisecond)
+	}()
+	ast.False(logs.Contains("", "threshold"))
+}
+
+func TestTimeTrackCaller(t *testing.T) {
+	ast := assert.New(t)
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf).With().Caller().Logger()
+	func() {
+		defer goutils.TimeTrack("caller", goutils.WithLogger{Logger: &logger})()
+	}()
+
+	var event map[string]string
+	ast.NoError(json.Unmarshal(buf.Bytes(), &event))
+	ast.True(strings.Contains(event["caller"], "timetrack_test.go:"), event["caller"])
+}
@@ -0,0 +1,216 @@
+package goutils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// uploadResultBodySize is the maximum size of UploadResult.Body
+const uploadResultBodySize = 1024 * 1024
+
+// UploadOption is an option for Upload. WithHeader, WithBasicAuth, WithBearerToken, WithCookieJar,
+// WithUserAgent, WithRetries, WithRetryBackoff, WithProxyFromEnv, WithProxyURL, WithTLSConfig, WithCAFile,
+// WithInsecureSkipVerify, WithMaxRedirects, WithHTTPTimeout, WithHTTPClient and WithTransport are upload options too.
+type UploadOption interface {
+	applyToUpload(*uploadOptions) error
+}
+
+type uploadOptions struct {
+	// request holds the options shared with Download
+	request   downloadOptions
+	Method    string
+	Multipart *WithMultipartField
+	Progress  WithUploadProgress
+}
+
+// WithMethod is an upload option to set the HTTP method, PUT by default, or POST with WithMultipartField
+type WithMethod string
+
+func (w WithMethod) applyToUpload(o *uploadOptions) error {
+	if w == "" {
+		return errors.New("empty method")
+	}
+	o.Method = string(w)
+	return nil
+}
+
+// WithMultipartField is an upload option to send the file as the field Name of a multipart/form-data body,
+// with the file name FileName, the base name of the file by default
+type WithMultipartField struct {
+	Name     string
+	FileName string
+}
+
+func (w WithMultipartField) applyToUpload(o *uploadOptions) error {
+	if w.Name == "" {
+		return errors.New("empty multipart field name")
+	}
+	o.Multipart = &w
+	return nil
+}
+
+// WithUploadProgress is an upload option to receive the number of bytes of the body sent so far, out of total.
+// A retry starts over from 0.
+type WithUploadProgress func(sent, total int64)
+
+func (w WithUploadProgress) applyToUpload(o *uploadOptions) error {
+	o.Progress = w
+	return nil
+}
+
+func (w WithHeader) applyToUpload(o *uploadOptions) error       { return w.applyToDownload(&o.request) }
+func (w WithBasicAuth) applyToUpload(o *uploadOptions) error    { return w.applyToDownload(&o.request) }
+func (w WithBearerToken) applyToUpload(o *uploadOptions) error  { return w.applyToDownload(&o.request) }
+func (w WithCookieJar) applyToUpload(o *uploadOptions) error    { return w.applyToDownload(&o.request) }
+func (w WithUserAgent) applyToUpload(o *uploadOptions) error    { return w.applyToDownload(&o.request) }
+func (w WithRetries) applyToUpload(o *uploadOptions) error      { return w.applyToDownload(&o.request) }
+func (w WithRetryBackoff) applyToUpload(o *uploadOptions) error { return w.applyToDownload(&o.request) }
+func (w WithProxyFromEnv) applyToUpload(o *uploadOptions) error { return w.applyToDownload(&o.request) }
+func (w WithProxyURL) applyToUpload(o *uploadOptions) error     { return w.applyToDownload(&o.request) }
+func (w WithHTTPClient) applyToUpload(o *uploadOptions) error   { return w.applyToDownload(&o.request) }
+func (w WithTransport) applyToUpload(o *uploadOptions) error    { return w.applyToDownload(&o.request) }
+func (w WithTLSConfig) applyToUpload(o *uploadOptions) error    { return w.applyToDownload(&o.request) }
+func (w WithCAFile) applyToUpload(o *uploadOptions) error       { return w.applyToDownload(&o.request) }
+func (w WithMaxRedirects) applyToUpload(o *uploadOptions) error { return w.applyToDownload(&o.request) }
+func (w WithHTTPTimeout) applyToUpload(o *uploadOptions) error  { return w.applyToDownload(&o.request) }
+func (w WithInsecureSkipVerify) applyToUpload(o *uploadOptions) error {
+	return w.applyToDownload(&o.request)
+}
+
+// UploadResult is the response of Upload
+type UploadResult struct {
+	StatusCode int
+	Header     http.Header
+	// Body is the response body, up to 1 MiB
+	Body []byte
+	// Attempts is the number of requests sent, with WithRetries
+	Attempts int
+	// Duration is the time from the first request to the last response, including the retry waits
+	Duration time.Duration
+	// URL is the URL of the last response, after the redirects, redacted by RedactURL
+	URL string
+}
+
+// Upload sends the file at filePath to url, streamed from the disk, as the body of a PUT request,
+// or as a multipart/form-data body with WithMultipartField. The path is expanded by ExpandPath.
+// The Content-Type of the file is detected from its extension.
+//
+// A non-2xx response is an error, returned with its UploadResult.
+func Upload(ctx context.Context, url, filePath string, opts ...UploadOption) (*UploadResult, error) {
+	opt := &uploadOptions{request: *newDownloadOptions()}
+	for _, o := range opts {
+		if err := o.applyToUpload(opt); err != nil {
+			return nil, err
+		}
+	}
+	if err := opt.request.setup(ctx); err != nil {
+		return nil, err
+	}
+	if opt.request.Transport != nil {
+		defer opt.request.Transport.CloseIdleConnections()
+	}
+	if opt.Method == "" {
+		opt.Method = http.MethodPut
+		if opt.Multipart != nil {
+			opt.Method = http.MethodPost
+		}
+	}
+
+	filePath, err := ExpandPath(filePath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(filePath))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	// the multipart body is the file between a header and a trailer, so that its length is known
+	var head, tail []byte
+	if opt.Multipart != nil {
+		fileName := opt.Multipart.FileName
+		if fileName == "" {
+			fileName = filepath.Base(filePath)
+		}
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{
+			"name":     opt.Multipart.Name,
+			"filename": fileName,
+		}))
+		header.Set("Content-Type", contentType)
+		if _, err := w.CreatePart(header); err != nil {
+			return nil, err
+		}
+		head = bytes.Clone(buf.Bytes())
+		buf.Reset()
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		tail = buf.Bytes()
+		contentType = w.FormDataContentType()
+	}
+	total := int64(len(head)) + info.Size() + int64(len(tail))
+
+	var (
+		result   *UploadResult
+		attempts int
+	)
+	start := GetClock().Now()
+	err = opt.request.retry(ctx, "Upload failed, retrying", url, func() error {
+		f, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		attempts++
+
+		body := newProgressCounter(opt.Progress, 0, total).reader(io.MultiReader(bytes.NewReader(head), f, bytes.NewReader(tail)))
+		req, err := http.NewRequestWithContext(ctx, opt.Method, url, body)
+		if err != nil {
+			return err
+		}
+		req.ContentLength = total
+		req.Header.Set("Content-Type", contentType)
+
+		resp, err := opt.request.send(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(io.LimitReader(resp.Body, uploadResultBodySize))
+		if err != nil {
+			return err
+		}
+		result = &UploadResult{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       data,
+			URL:        RedactURL(resp.Request.URL.String()),
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			resp.Body = io.NopCloser(bytes.NewReader(data))
+			return newHTTPStatusError("upload", url, resp)
+		}
+		return nil
+	})
+	if result != nil {
+		result.Attempts = attempts
+		result.Duration = GetClock().Since(start)
+	}
+	return result, err
+}
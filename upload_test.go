@@ -0,0 +1,162 @@
+package goutils_test
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+	"github.com/117503445/goutils/testutil"
+)
+
+func TestUpload(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.json")
+	content := strings.Repeat(`{"ok":true}`, 10000)
+	ast.NoError(goutils.WriteText(path, content))
+
+	type received struct {
+		method, contentType, auth string
+		length                    int64
+		body                      string
+	}
+	var got atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got.Store(received{r.Method, r.Header.Get("Content-Type"), r.Header.Get("Authorization"), r.ContentLength, string(body)})
+		if r.URL.Path == "/forbidden" {
+			http.Error(w, "access denied", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("stored"))
+	}))
+	defer server.Close()
+
+	var sent, total int64
+	result, err := goutils.Upload(context.Background(), server.URL+"/upload", path, goutils.WithBearerToken("token"),
+		goutils.WithUploadProgress(func(s, t int64) { sent, total = s, t }))
+	ast.NoError(err)
+	ast.Equal(http.StatusOK, result.StatusCode)
+	ast.Equal("stored", string(result.Body))
+	r := got.Load().(received)
+	ast.Equal(http.MethodPut, r.method)
+	ast.Equal("application/json", r.contentType)
+	ast.Equal("Bearer token", r.auth)
+	ast.EqualValues(len(content), r.length)
+	ast.Equal(content, r.body)
+	ast.EqualValues(len(content), sent)
+	ast.EqualValues(len(content), total)
+
+	_, err = goutils.Upload(context.Background(), server.URL+"/upload", path, goutils.WithMethod(http.MethodPost))
+	ast.NoError(err)
+	ast.Equal(http.MethodPost, got.Load().(received).method)
+
+	// non-2xx
+	result, err = goutils.Upload(context.Background(), server.URL+"/forbidden", path)
+	ast.ErrorContains(err, "403 Forbidden")
+	ast.ErrorContains(err, "access denied")
+	ast.Equal(http.StatusForbidden, result.StatusCode)
+	ast.Equal("access denied\n", string(result.Body))
+
+	_, err = goutils.Upload(context.Background(), server.URL+"/upload", filepath.Join(dir, "missing"))
+	ast.Error(err)
+}
+
+func TestUploadMultipart(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.pdf")
+	ast.NoError(goutils.WriteText(path, "document"))
+	clock := testutil.NewFakeClock(time.Date(2024, 9, 15, 22, 12, 19, 0, time.UTC))
+	defer goutils.SetClock(goutils.SetClock(clock))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clock.Advance(2 * time.Second)
+		ast.Equal(http.MethodPost, r.Method)
+		ast.Positive(r.ContentLength)
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		ast.NoError(err)
+		ast.Equal("multipart/form-data", mediaType)
+
+		reader, err := r.MultipartReader()
+		ast.NoError(err)
+		part, err := reader.NextPart()
+		ast.NoError(err)
+		ast.Equal("artifact", part.FormName())
+		ast.Equal("report-1.0.pdf", part.FileName())
+		ast.Equal("application/pdf", part.Header.Get("Content-Type"))
+		data, err := io.ReadAll(part)
+		ast.NoError(err)
+		ast.Equal("document", string(data))
+		_, err = reader.NextPart()
+		ast.ErrorIs(err, io.EOF)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	result, err := goutils.Upload(context.Background(), server.URL, path,
+		goutils.WithMultipartField{Name: "artifact", FileName: "report-1.0.pdf"})
+	ast.NoError(err)
+	ast.Equal(http.StatusCreated, result.StatusCode)
+	ast.Equal(2*time.Second, result.Duration)
+
+	_, err = goutils.Upload(context.Background(), server.URL, path, goutils.WithMultipartField{})
+	ast.Error(err)
+}
+
+func TestUploadRetries(t *testing.T) {
+	ast := assert.New(t)
+	logs := testutil.CaptureLogs(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	ast.NoError(goutils.WriteText(path, "content"))
+
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ast.Equal("content", string(body))
+		if requests.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	result, err := goutils.Upload(context.Background(), server.URL+"/robot/send?access_token=abc&timestamp=1&sign=xyz",
+		path, goutils.WithRetries(2), goutils.WithRetryBackoff{Base: time.Millisecond})
+	ast.NoError(err)
+	ast.EqualValues(3, requests.Load())
+	ast.Equal(3, result.Attempts)
+	ast.Greater(result.Duration, time.Duration(0))
+	ast.Equal(server.URL+"/robot/send?access_token=***&sign=***&timestamp=1", result.URL)
+	ast.Equal(2, logs.Count("debug"))
+	ast.True(logs.Contains("debug", "Upload failed, retrying"))
+	// the signature is redacted from the retry logs and the errors
+	ast.True(logs.Contains("debug", "access_token=***"))
+	ast.False(logs.Contains("", "xyz"))
+	requests.Store(0)
+	_, err = goutils.Upload(context.Background(), server.URL+"/robot/send?access_token=abc&sign=xyz", path)
+	ast.ErrorContains(err, "sign=***")
+	ast.NotContains(err.Error(), "xyz")
+
+	requests.Store(0)
+	result, err = goutils.Upload(context.Background(), server.URL, path)
+	ast.ErrorContains(err, "503 Service Unavailable")
+	ast.EqualValues(1, requests.Load())
+	ast.Equal(1, result.Attempts)
+	ast.Equal(http.StatusServiceUnavailable, result.StatusCode)
+}
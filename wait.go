@@ -0,0 +1,163 @@
+package goutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// waitBodySize is the size of the start of the body searched by WithBodyContains
+const waitBodySize = 1024 * 1024
+
+// WaitOption is an option for WaitForURL and WaitForTCP. WithHeader, WithBasicAuth, WithBearerToken,
+// WithHTTPTimeout, WithCAFile, WithInsecureSkipVerify, WithHTTPClient and WithTransport are wait options too.
+type WaitOption interface {
+	applyToWait(*waitOptions) error
+}
+
+type waitOptions struct {
+	// request holds the options shared with Download, its Timeout is the one of each probe
+	request      downloadOptions
+	Interval     time.Duration
+	Status       []int
+	BodyContains string
+}
+
+// WithPollInterval is a wait option to set the interval between the probes, 500ms by default
+type WithPollInterval time.Duration
+
+func (w WithPollInterval) applyToWait(o *waitOptions) error {
+	if w <= 0 {
+		return fmt.Errorf("invalid poll interval: %v", time.Duration(w))
+	}
+	o.Interval = time.Duration(w)
+	return nil
+}
+
+// WithExpectStatus is a wait option to expect one of these response status codes instead of any 2xx
+type WithExpectStatus []int
+
+func (w WithExpectStatus) applyToWait(o *waitOptions) error {
+	if len(w) == 0 {
+		return errors.New("no expected status")
+	}
+	o.Status = w
+	return nil
+}
+
+// WithBodyContains is a wait option to also expect the response body to contain this string
+type WithBodyContains string
+
+func (w WithBodyContains) applyToWait(o *waitOptions) error {
+	o.BodyContains = string(w)
+	return nil
+}
+
+func (w WithHeader) applyToWait(o *waitOptions) error      { return w.applyToDownload(&o.request) }
+func (w WithBasicAuth) applyToWait(o *waitOptions) error   { return w.applyToDownload(&o.request) }
+func (w WithBearerToken) applyToWait(o *waitOptions) error { return w.applyToDownload(&o.request) }
+func (w WithHTTPTimeout) applyToWait(o *waitOptions) error { return w.applyToDownload(&o.request) }
+func (w WithCAFile) applyToWait(o *waitOptions) error      { return w.applyToDownload(&o.request) }
+func (w WithInsecureSkipVerify) applyToWait(o *waitOptions) error {
+	return w.applyToDownload(&o.request)
+}
+func (w WithHTTPClient) applyToWait(o *waitOptions) error { return w.applyToDownload(&o.request) }
+func (w WithTransport) applyToWait(o *waitOptions) error  { return w.applyToDownload(&o.request) }
+
+func newWaitOptions(opts []WaitOption) (*waitOptions, error) {
+	opt := &waitOptions{request: *newDownloadOptions(), Interval: 500 * time.Millisecond}
+	opt.request.Timeout = 5 * time.Second
+	for _, o := range opts {
+		if err := o.applyToWait(opt); err != nil {
+			return nil, err
+		}
+	}
+	return opt, nil
+}
+
+// WaitForURL polls url with GET requests until the response has the expected status, any 2xx by default,
+// and body, see the wait options. Each request times out after 5s by default, see WithHTTPTimeout.
+//
+// The maximum wait is the one of ctx, e.g. with context.WithTimeout. When ctx is done, the error wraps both
+// ctx.Err() and the error of the last probe, with the time waited.
+func WaitForURL(ctx context.Context, url string, opts ...WaitOption) error {
+	opt, err := newWaitOptions(opts)
+	if err != nil {
+		return err
+	}
+	if err := opt.request.setup(ctx); err != nil {
+		return err
+	}
+	if opt.request.Transport != nil {
+		defer opt.request.Transport.CloseIdleConnections()
+	}
+
+	return poll(ctx, RedactURL(url), opt.Interval, func() error {
+		resp, err := opt.request.do(ctx, http.MethodGet, url, "")
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if opt.Status == nil && (resp.StatusCode < 200 || resp.StatusCode > 299) ||
+			opt.Status != nil && !slices.Contains(opt.Status, resp.StatusCode) {
+			return newHTTPStatusError("wait for", url, resp)
+		}
+		if opt.BodyContains != "" {
+			body, err := io.ReadAll(io.LimitReader(resp.Body, waitBodySize))
+			if err != nil {
+				return err
+			}
+			if !strings.Contains(string(body), opt.BodyContains) {
+				return fmt.Errorf("wait for %s: body without %q", RedactURL(url), opt.BodyContains)
+			}
+		}
+		return nil
+	})
+}
+
+// WaitForTCP polls addr, like "localhost:5432", until it accepts a TCP connection.
+// Only WithPollInterval and WithHTTPTimeout, for the timeout of each connection, apply. See WaitForURL.
+func WaitForTCP(ctx context.Context, addr string, opts ...WaitOption) error {
+	opt, err := newWaitOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	dialer := &net.Dialer{Timeout: opt.request.Timeout}
+	return poll(ctx, addr, opt.Interval, func() error {
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+}
+
+// poll calls probe every interval until it succeeds or ctx is done
+func poll(ctx context.Context, target string, interval time.Duration, probe func() error) error {
+	start := GetClock().Now()
+	var lastErr error
+	for {
+		err := probe()
+		if err == nil {
+			LoggerFromContext(ctx).Debug().Str("target", target).Str("waited", DurationToStr(GetClock().Since(start))).Msg("Ready")
+			return nil
+		}
+		// a probe cut by ctx says less than the previous one
+		if ctx.Err() == nil || lastErr == nil {
+			lastErr = err
+		}
+		LoggerFromContext(ctx).Debug().Err(err).Str("target", target).Msg("Not ready")
+
+		if sleepErr := GetClock().Sleep(ctx, interval); sleepErr != nil {
+			return fmt.Errorf("%w waiting for %s for %s: %w", sleepErr, target, DurationToStr(GetClock().Since(start)), lastErr)
+		}
+	}
+}
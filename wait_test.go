@@ -0,0 +1,129 @@
+package goutils_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+	"github.com/117503445/goutils/testutil"
+)
+
+// serveLater serves handler on a free local address after delay, and returns the address
+func serveLater(t *testing.T, delay time.Duration, handler http.Handler) string {
+	t.Helper()
+	port, err := goutils.GetFreePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+
+	server := &http.Server{Handler: handler}
+	t.Cleanup(func() { server.Close() })
+	go func() {
+		time.Sleep(delay)
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		server.Serve(l)
+	}()
+	return addr
+}
+
+func TestWaitForURL(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// the server starts late
+	addr := serveLater(t, 100*time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	start := time.Now()
+	ast.NoError(goutils.WaitForURL(ctx, "http://"+addr+"/health", goutils.WithPollInterval(10*time.Millisecond)))
+	ast.GreaterOrEqual(time.Since(start), 100*time.Millisecond)
+
+	// the status and the body
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch n := requests.Add(1); {
+		case n <= 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case n <= 4:
+			w.Write([]byte(`{"status":"starting"}`))
+		default:
+			w.Write([]byte(`{"status":"ready"}`))
+		}
+	}))
+	defer server.Close()
+	ast.NoError(goutils.WaitForURL(ctx, server.URL, goutils.WithPollInterval(time.Millisecond),
+		goutils.WithBodyContains(`"ready"`), goutils.WithHeader{Key: "X-Token", Value: "token"}))
+	ast.EqualValues(5, requests.Load())
+
+	ast.NoError(goutils.WaitForURL(ctx, server.URL, goutils.WithExpectStatus{http.StatusUnauthorized}))
+
+	ast.Error(goutils.WaitForURL(ctx, server.URL, goutils.WithExpectStatus{}))
+	ast.Error(goutils.WaitForURL(ctx, server.URL, goutils.WithPollInterval(0)))
+}
+
+func TestWaitForURLTimeout(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	// the server never appears
+	port, err := goutils.GetFreePort()
+	ast.NoError(err)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = goutils.WaitForURL(ctx, fmt.Sprintf("http://127.0.0.1:%d", port), goutils.WithPollInterval(20*time.Millisecond))
+	ast.Less(time.Since(start), 2*time.Second)
+	// the text of the last dial error depends on the OS, only the target is checked
+	ast.ErrorIs(err, context.DeadlineExceeded)
+	ast.ErrorContains(err, fmt.Sprintf("waiting for http://127.0.0.1:%d", port))
+
+	// the last status is reported
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "migrating", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = goutils.WaitForURL(ctx, server.URL, goutils.WithPollInterval(20*time.Millisecond))
+	ast.ErrorIs(err, context.DeadlineExceeded)
+	ast.ErrorContains(err, "503 Service Unavailable")
+	ast.ErrorContains(err, "migrating")
+}
+
+func TestWaitForTCP(t *testing.T) {
+	ast := assert.New(t)
+	testutil.CaptureLogs(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	addr := serveLater(t, 100*time.Millisecond, http.NotFoundHandler())
+	ast.NoError(goutils.WaitForTCP(ctx, addr, goutils.WithPollInterval(10*time.Millisecond)))
+
+	port, err := goutils.GetFreePort()
+	ast.NoError(err)
+	ctx, cancel = context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	err = goutils.WaitForTCP(ctx, fmt.Sprintf("127.0.0.1:%d", port), goutils.WithPollInterval(10*time.Millisecond))
+	ast.ErrorIs(err, context.DeadlineExceeded)
+	ast.ErrorContains(err, fmt.Sprintf("waiting for 127.0.0.1:%d", port))
+}
@@ -0,0 +1,244 @@
+package goutils
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// WalkOption is an option for the helpers walking a directory tree, like WalkFiles and DirSize
+type WalkOption interface {
+	applyToWalk(*walkOptions) error
+}
+
+type walkOptions struct {
+	Exclude        []string
+	IgnoreFiles    []string
+	IgnorePatterns []string
+}
+
+func (w WithExclude) applyToWalk(o *walkOptions) error {
+	o.Exclude = append(o.Exclude, w...)
+	return nil
+}
+
+// WithIgnoreFile is a walk option to honor the ignore files with this name, like ".gitignore",
+// found in every walked directory. The patterns of nested files override those of their parents, as git does.
+type WithIgnoreFile string
+
+func (w WithIgnoreFile) applyToWalk(o *walkOptions) error {
+	o.IgnoreFiles = append(o.IgnoreFiles, string(w))
+	return nil
+}
+
+// WithIgnorePatterns is a walk option to ignore paths with gitignore-style patterns, relative to the walked root
+type WithIgnorePatterns []string
+
+func (w WithIgnorePatterns) applyToWalk(o *walkOptions) error {
+	o.IgnorePatterns = append(o.IgnorePatterns, w...)
+	return nil
+}
+
+// ignoreRule is a parsed gitignore pattern
+type ignoreRule struct {
+	// base is the slash-separated directory of the ignore file, relative to the walked root
+	base    string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// parseIgnoreRule parses a line of an ignore file, it returns false for blank and comment lines.
+//
+// The gitignore syntax is supported: "*", "?", "[...]", "**", a leading "/" or a middle "/" to anchor the pattern
+// to base, a trailing "/" to match directories only, and a leading "!" to negate the pattern.
+func parseIgnoreRule(base, line string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		// a pattern without slash matches at any depth
+		sb.WriteString("(?:.*/)?")
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case strings.HasPrefix(line[i:], "**/") && (i == 0 || line[i-1] == '/'):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(line[i:], "**") && i+2 == len(line) && (i == 0 || line[i-1] == '/'):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(line[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			class := line[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, "\\", "\\\\") + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(line):
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(line[i])))
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// match returns true if the rule matches the slash-separated path relative to the walked root
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		sub, ok := strings.CutPrefix(rel, r.base+"/")
+		if !ok {
+			return false
+		}
+		rel = sub
+	}
+	return r.re.MatchString(rel)
+}
+
+// walkMatcher decides which paths of a walk are skipped
+type walkMatcher struct {
+	root  string
+	opt   *walkOptions
+	rules []ignoreRule
+}
+
+func newWalkMatcher(root string, opts []WalkOption) (*walkMatcher, error) {
+	opt := &walkOptions{}
+	for _, o := range opts {
+		if err := o.applyToWalk(opt); err != nil {
+			return nil, err
+		}
+	}
+
+	m := &walkMatcher{root: root, opt: opt}
+	for _, p := range opt.IgnorePatterns {
+		if rule, ok := parseIgnoreRule("", p); ok {
+			m.rules = append(m.rules, rule)
+		}
+	}
+	return m, nil
+}
+
+// loadIgnoreFiles loads the ignore files of the directory rel, "" for the root.
+// Rules are only appended, since those of a directory only match below it and must override its parents.
+func (m *walkMatcher) loadIgnoreFiles(rel string) error {
+	for _, name := range m.opt.IgnoreFiles {
+		path := filepath.Join(m.root, filepath.FromSlash(rel), name)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			if rule, ok := parseIgnoreRule(rel, line); ok {
+				m.rules = append(m.rules, rule)
+			}
+		}
+	}
+	return nil
+}
+
+// skip returns true if the slash-separated path relative to the root is excluded or ignored
+func (m *walkMatcher) skip(rel string, isDir bool) bool {
+	if matchExclude(m.opt.Exclude, rel) {
+		return true
+	}
+
+	// the last matching rule wins
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.match(rel, isDir) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// WalkFiles is like filepath.Walk, but skips the paths matched by WithExclude, WithIgnorePatterns
+// and the ignore files of WithIgnoreFile. Ignored directories are pruned without being read.
+func WalkFiles(root string, fn filepath.WalkFunc, opts ...WalkOption) error {
+	m, err := newWalkMatcher(root, opts)
+	if err != nil {
+		return err
+	}
+
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fn(path, info, err)
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			rel = ""
+		}
+
+		if rel != "" && m.skip(rel, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			if err := m.loadIgnoreFiles(rel); err != nil {
+				return err
+			}
+		}
+
+		return fn(path, info, nil)
+	})
+}
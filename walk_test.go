@@ -0,0 +1,95 @@
+package goutils_test
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/117503445/goutils"
+)
+
+func TestWalkFiles(t *testing.T) {
+	ast := assert.New(t)
+
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":          "# comment\n*.log\n!keep.log\nbuild/\n/top.txt\ndocs/**/*.tmp\n",
+		"a.log":               "",
+		"keep.log":            "",
+		"top.txt":             "",
+		"main.go":             "",
+		"build/out.bin":       "",
+		"docs/c.tmp":          "",
+		"docs/a/b/c.tmp":      "",
+		"docs/a/b/readme.md":  "",
+		"sub/.gitignore":      "!a.log\n*.txt\n",
+		"sub/a.log":           "",
+		"sub/b.log":           "",
+		"sub/top.txt":         "",
+		"sub/other.md":        "",
+		"sub/build":           "", // a file, not matched by "build/"
+		"sub/deep/nested.txt": "",
+	}
+	for name, content := range files {
+		ast.NoError(goutils.WriteText(filepath.Join(root, name), content))
+	}
+
+	walk := func(opts ...goutils.WalkOption) []string {
+		var visited []string
+		err := goutils.WalkFiles(root, func(path string, info os.FileInfo, err error) error {
+			ast.NoError(err)
+			rel, err := filepath.Rel(root, path)
+			ast.NoError(err)
+			visited = append(visited, filepath.ToSlash(rel))
+			return nil
+		}, opts...)
+		ast.NoError(err)
+		sort.Strings(visited)
+		return visited
+	}
+
+	ast.Equal([]string{
+		".",
+		".gitignore",
+		"docs",
+		"docs/a",
+		"docs/a/b",
+		"docs/a/b/readme.md",
+		"keep.log",
+		"main.go",
+		"sub",
+		"sub/.gitignore",
+		"sub/a.log",
+		"sub/build",
+		"sub/deep",
+		"sub/other.md",
+	}, walk(goutils.WithIgnoreFile(".gitignore")))
+
+	ast.Equal([]string{
+		".",
+		".gitignore",
+		"a.log",
+		"build",
+		"build/out.bin",
+		"keep.log",
+		"main.go",
+		"sub",
+		"sub/.gitignore",
+		"sub/a.log",
+		"sub/b.log",
+		"sub/build",
+		"sub/deep",
+		"sub/deep/nested.txt",
+		"sub/top.txt",
+		"top.txt",
+	}, walk(goutils.WithIgnorePatterns{"docs/", "*.md"}))
+
+	// DirSize and CountFiles honor the same options
+	files2, dirs, err := goutils.CountFiles(root, goutils.WithIgnoreFile(".gitignore"))
+	ast.NoError(err)
+	ast.Equal(8, files2)
+	ast.Equal(5, dirs)
+}